@@ -0,0 +1,162 @@
+package http
+
+import (
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/micro/go-micro/v2/broker"
+	"github.com/micro/go-micro/v2/logger"
+	"github.com/micro/go-micro/v2/registry"
+)
+
+var (
+	// DefaultDeliveryAttempts is the number of times a message is sent to a subscriber
+	DefaultDeliveryAttempts = 5
+	// DefaultRetryBackoff is the delay before the first retry, doubled for every retry after
+	DefaultRetryBackoff = time.Millisecond * 100
+	// DefaultMaxRetryBackoff caps the delay between retries
+	DefaultMaxRetryBackoff = time.Second * 10
+)
+
+const (
+	// DeadLetterTopicHeader is set to the original topic on dead lettered messages
+	DeadLetterTopicHeader = "Micro-Dead-Letter-Topic"
+	// DeliveryAttemptsHeader is set to the number of failed attempts on dead lettered messages
+	DeliveryAttemptsHeader = "Micro-Delivery-Attempts"
+)
+
+// delivery tracks the attempts to deliver a message to one subscriber
+type delivery struct {
+	msg   *InboxMessage
+	nodes []*registry.Node
+	// queue deliveries go to any one node of the queue, broadcast
+	// deliveries have a delivery per node
+	queue    string
+	attempts int
+}
+
+// node returns the node for the next attempt
+func (d *delivery) node() *registry.Node {
+	if len(d.queue) == 0 {
+		return d.nodes[0]
+	}
+	return d.nodes[rand.Int()%len(d.nodes)]
+}
+
+// retained returns the message to save for a later attempt, for the
+// subscriber it failed to reach only
+func (d *delivery) retained() *InboxMessage {
+	msg := *d.msg
+	msg.Node, msg.Queue = "", ""
+	if len(d.queue) > 0 {
+		msg.Queue = d.queue
+	} else {
+		msg.Node = d.nodes[0].Id
+	}
+	return &msg
+}
+
+// retryOptions returns the configured attempts and backoff
+func (h *httpBroker) retryOptions() (int, time.Duration, time.Duration) {
+	attempts := DefaultDeliveryAttempts
+	backoff := DefaultRetryBackoff
+	maxBackoff := DefaultMaxRetryBackoff
+
+	if n, ok := h.opts.Context.Value(deliveryAttemptsKey{}).(int); ok && n > 0 {
+		attempts = n
+	}
+	if b, ok := h.opts.Context.Value(retryBackoffKey{}).(time.Duration); ok && b > 0 {
+		backoff = b
+	}
+	if b, ok := h.opts.Context.Value(maxRetryBackoffKey{}).(time.Duration); ok && b > 0 {
+		maxBackoff = b
+	}
+
+	return attempts, backoff, maxBackoff
+}
+
+// send delivers the message, retrying with exponential backoff until the
+// subscriber acknowledges it, the attempts run out or exit is closed
+func (h *httpBroker) send(d *delivery, exit chan bool) {
+	// first attempt inline
+	if err := h.publish(d.node(), d.msg.Body); err == nil {
+		return
+	}
+	d.attempts++
+
+	attempts, backoff, maxBackoff := h.retryOptions()
+	if d.attempts >= attempts {
+		h.deadLetter(d)
+		return
+	}
+
+	go func() {
+		for {
+			// 2^(attempts-1) * backoff
+			delay := backoff << uint(d.attempts-1)
+			if delay > maxBackoff || delay <= 0 {
+				delay = maxBackoff
+			}
+
+			select {
+			case <-exit:
+				// shutting down, keep the message for replay
+				h.saveMessage(d.retained())
+				return
+			case <-time.After(delay):
+			}
+
+			err := h.publish(d.node(), d.msg.Body)
+			if err == nil {
+				return
+			}
+			d.attempts++
+
+			if logger.V(logger.DebugLevel, logger.DefaultLogger) {
+				logger.Debugf("Delivery attempt %d for topic %s failed: %v", d.attempts, d.msg.Topic, err)
+			}
+
+			if d.attempts >= attempts {
+				h.deadLetter(d)
+				return
+			}
+		}
+	}()
+}
+
+// deadLetter publishes a message which ran out of attempts to the dead letter
+// topic, or saves it to the inbox for replay to the subscriber if there isn't one
+func (h *httpBroker) deadLetter(d *delivery) {
+	topic, ok := h.opts.Context.Value(deadLetterTopicKey{}).(string)
+	if !ok || len(topic) == 0 {
+		h.saveMessage(d.retained())
+		return
+	}
+
+	var m *broker.Message
+	if err := h.opts.Codec.Unmarshal(d.msg.Body, &m); err != nil {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("Error decoding message for dead letter topic %s: %v", topic, err)
+		}
+		return
+	}
+
+	// don't dead letter the dead letter topic
+	if _, ok := m.Header[DeadLetterTopicHeader]; ok {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("Dropping dead letter for topic %s after %d attempts", d.msg.Topic, d.attempts)
+		}
+		return
+	}
+
+	delete(m.Header, ":topic")
+	m.Header[DeadLetterTopicHeader] = d.msg.Topic
+	m.Header[DeliveryAttemptsHeader] = strconv.Itoa(d.attempts)
+
+	if err := h.Publish(topic, m); err != nil {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("Error publishing to dead letter topic %s: %v", topic, err)
+		}
+	}
+}
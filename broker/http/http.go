@@ -15,6 +15,7 @@ import (
 	"net/url"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,6 +23,7 @@ import (
 	"github.com/micro/go-micro/v2/codec/json"
 	"github.com/micro/go-micro/v2/config/cmd"
 	merr "github.com/micro/go-micro/v2/errors"
+	"github.com/micro/go-micro/v2/logger"
 	"github.com/micro/go-micro/v2/registry"
 	"github.com/micro/go-micro/v2/registry/cache"
 	maddr "github.com/micro/go-micro/v2/util/addr"
@@ -49,10 +51,13 @@ type httpBroker struct {
 	subscribers map[string][]*httpSubscriber
 	running     bool
 	exit        chan chan error
+	// closed on disconnect to stop retries
+	retryExit chan bool
+	// set while the inbox is replayed
+	replaying int32
 
 	// offline message inbox
-	mtx   sync.RWMutex
-	inbox map[string][][]byte
+	inbox Inbox
 }
 
 type httpSubscriber struct {
@@ -65,9 +70,11 @@ type httpSubscriber struct {
 }
 
 type httpEvent struct {
-	m   *broker.Message
-	t   string
-	err error
+	sync.Mutex
+	m     *broker.Message
+	t     string
+	err   error
+	acked bool
 }
 
 var (
@@ -76,6 +83,9 @@ var (
 	broadcastVersion = "ff.http.broadcast"
 	registerTTL      = time.Minute
 	registerInterval = time.Second * 30
+
+	// DefaultReplayInterval is how often undelivered messages are retried
+	DefaultReplayInterval = time.Second * 10
 )
 
 func init() {
@@ -138,7 +148,11 @@ func newHttpBroker(opts ...broker.Option) broker.Broker {
 		subscribers: make(map[string][]*httpSubscriber),
 		exit:        make(chan chan error),
 		mux:         http.NewServeMux(),
-		inbox:       make(map[string][][]byte),
+		inbox:       NewMemoryInbox(),
+	}
+
+	if inbox, ok := options.Context.Value(inboxKey{}).(Inbox); ok {
+		h.inbox = inbox
 	}
 
 	// specify the message handler
@@ -157,10 +171,20 @@ func newHttpBroker(opts ...broker.Option) broker.Broker {
 	return h
 }
 
+// Ack acknowledges the message, unacknowledged messages are redelivered
 func (h *httpEvent) Ack() error {
+	h.Lock()
+	h.acked = true
+	h.Unlock()
 	return nil
 }
 
+func (h *httpEvent) isAcked() bool {
+	h.Lock()
+	defer h.Unlock()
+	return h.acked
+}
+
 func (h *httpEvent) Error() error {
 	return h.err
 }
@@ -185,47 +209,22 @@ func (h *httpSubscriber) Unsubscribe() error {
 	return h.hb.unsubscribe(h)
 }
 
-func (h *httpBroker) saveMessage(topic string, msg []byte) {
-	h.mtx.Lock()
-	defer h.mtx.Unlock()
-
-	// get messages
-	c := h.inbox[topic]
-
-	// save message
-	c = append(c, msg)
-
-	// max length 64
-	if len(c) > 64 {
-		c = c[:64]
+func (h *httpBroker) saveMessage(msg *InboxMessage) {
+	if err := h.inbox.Save(msg); err != nil {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("Error saving message for topic %s: %v", msg.Topic, err)
+		}
 	}
-
-	// save inbox
-	h.inbox[topic] = c
 }
 
-func (h *httpBroker) getMessage(topic string, num int) [][]byte {
-	h.mtx.Lock()
-	defer h.mtx.Unlock()
-
-	// get messages
-	c, ok := h.inbox[topic]
-	if !ok {
-		return nil
-	}
-
-	// more message than requests
-	if len(c) >= num {
-		msg := c[:num]
-		h.inbox[topic] = c[num:]
-		return msg
+func (h *httpBroker) getMessage(topic string, num int) []*InboxMessage {
+	msgs, err := h.inbox.Take(topic, num)
+	if err != nil {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("Error reading messages for topic %s: %v", topic, err)
+		}
 	}
-
-	// reset inbox
-	h.inbox[topic] = nil
-
-	// return all messages
-	return c
+	return msgs
 }
 
 func (h *httpBroker) subscribe(s *httpSubscriber) error {
@@ -264,12 +263,29 @@ func (h *httpBroker) unsubscribe(s *httpSubscriber) error {
 	return nil
 }
 
-func (h *httpBroker) run(l net.Listener) {
+func (h *httpBroker) run(l net.Listener, reg registry.Registry, exit chan bool) {
 	t := time.NewTicker(registerInterval)
 	defer t.Stop()
 
+	interval := DefaultReplayInterval
+	if d, ok := h.opts.Context.Value(replayIntervalKey{}).(time.Duration); ok && d > 0 {
+		interval = d
+	}
+
+	r := time.NewTicker(interval)
+	defer r.Stop()
+
 	for {
 		select {
+		// retry undelivered messages in the background so heartbeats
+		// and disconnecting aren't held up, skipping if still replaying
+		case <-r.C:
+			if atomic.CompareAndSwapInt32(&h.replaying, 0, 1) {
+				go func() {
+					defer atomic.StoreInt32(&h.replaying, 0)
+					h.replay(reg, exit)
+				}()
+			}
 		// heartbeat for each subscriber
 		case <-t.C:
 			h.RLock()
@@ -334,20 +350,37 @@ func (h *httpBroker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	id := req.Form.Get("id")
 
 	//nolint:prealloc
-	var subs []broker.Handler
+	var subs []*httpSubscriber
 
 	h.RLock()
 	for _, subscriber := range h.subscribers[topic] {
 		if id != subscriber.id {
 			continue
 		}
-		subs = append(subs, subscriber.fn)
+		subs = append(subs, subscriber)
 	}
 	h.RUnlock()
 
 	// execute the handler
-	for _, fn := range subs {
-		p.err = fn(p)
+	for _, sub := range subs {
+		p.err = sub.fn(p)
+		if p.err == nil && sub.opts.AutoAck {
+			p.Ack()
+		}
+	}
+
+	// the publisher retries until the message is acknowledged
+	if !p.isAcked() {
+		code := http.StatusInternalServerError
+		errr := merr.InternalServerError("go.micro.broker", "Message not acknowledged")
+		if p.err != nil {
+			errr = merr.InternalServerError("go.micro.broker", "Error handling message: %v", p.err)
+		} else if len(subs) == 0 {
+			code = http.StatusNotFound
+			errr = merr.NotFound("go.micro.broker", "Subscriber not found")
+		}
+		w.WriteHeader(code)
+		w.Write([]byte(errr.Error()))
 	}
 }
 
@@ -412,15 +445,7 @@ func (h *httpBroker) Connect() error {
 
 	addr := h.address
 	h.address = l.Addr().String()
-
-	go http.Serve(l, h.mux)
-	go func() {
-		h.run(l)
-		h.Lock()
-		h.opts.Addrs = []string{addr}
-		h.address = addr
-		h.Unlock()
-	}()
+	h.retryExit = make(chan bool)
 
 	// get registry
 	reg := h.opts.Registry
@@ -430,6 +455,15 @@ func (h *httpBroker) Connect() error {
 	// set cache
 	h.r = cache.New(reg)
 
+	go http.Serve(l, h.mux)
+	go func(r registry.Registry, exit chan bool) {
+		h.run(l, r, exit)
+		h.Lock()
+		h.opts.Addrs = []string{addr}
+		h.address = addr
+		h.Unlock()
+	}(h.r, h.retryExit)
+
 	// set running
 	h.running = true
 	return nil
@@ -452,6 +486,9 @@ func (h *httpBroker) Disconnect() error {
 		rc.Stop()
 	}
 
+	// stop retrying
+	close(h.retryExit)
+
 	// exit and return err
 	ch := make(chan error)
 	h.exit <- ch
@@ -477,6 +514,10 @@ func (h *httpBroker) Init(opts ...broker.Option) error {
 		o(&h.opts)
 	}
 
+	if inbox, ok := h.opts.Context.Value(inboxKey{}).(Inbox); ok {
+		h.inbox = inbox
+	}
+
 	if len(h.opts.Addrs) > 0 && len(h.opts.Addrs[0]) > 0 {
 		h.address = h.opts.Addrs[0]
 	}
@@ -533,112 +574,188 @@ func (h *httpBroker) Publish(topic string, msg *broker.Message, opts ...broker.P
 	}
 
 	// save the message
-	h.saveMessage(topic, b)
+	h.saveMessage(&InboxMessage{
+		Topic:   topic,
+		Body:    b,
+		Created: time.Now(),
+	})
 
 	// now attempt to get the service
 	h.RLock()
 	s, err := h.r.GetService(serviceName)
-	if err != nil {
+	exit := h.retryExit
+	if err == registry.ErrNotFound {
+		// no subscribers yet, the message is replayed when one appears
+		h.RUnlock()
+		return nil
+	} else if err != nil {
 		h.RUnlock()
 		return err
 	}
 	h.RUnlock()
 
-	pub := func(node *registry.Node, t string, b []byte) error {
-		scheme := "http"
+	// do the rest async
+	go h.flush(s, topic, exit)
+
+	return nil
+}
+
+// flush delivers a batch of saved messages for a topic until exit is closed
+func (h *httpBroker) flush(s []*registry.Service, topic string, exit chan bool) {
+	// get a third of the backlog
+	messages := h.getMessage(topic, 8)
+	delay := (len(messages) > 1)
+
+	// publish all the messages
+	for i, msg := range messages {
+		// serialize here
+		h.deliver(s, msg, exit)
+
+		// sending a backlog of messages
+		if !delay {
+			continue
+		}
+
+		select {
+		case <-exit:
+			// shutting down, keep the rest for replay
+			for _, m := range messages[i+1:] {
+				h.saveMessage(m)
+			}
+			return
+		case <-time.After(time.Millisecond * 100):
+		}
+	}
+}
 
-		// check if secure is added in metadata
-		if node.Metadata["secure"] == "true" {
-			scheme = "https"
+// replay retries delivery of the messages left in the inbox until exit is
+// closed. It runs outside the run loop so it mustn't take the broker lock.
+func (h *httpBroker) replay(reg registry.Registry, exit chan bool) {
+	topics, err := h.inbox.Topics()
+	if err != nil {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("Error listing inbox topics: %v", err)
 		}
+		return
+	}
 
-		vals := url.Values{}
-		vals.Add("id", node.Id)
+	if len(topics) == 0 {
+		return
+	}
 
-		uri := fmt.Sprintf("%s://%s%s?%s", scheme, node.Address, DefaultSubPath, vals.Encode())
-		r, err := h.c.Post(uri, "application/json", bytes.NewReader(b))
-		if err != nil {
-			return err
+	s, err := reg.GetService(serviceName)
+	if err != nil {
+		return
+	}
+
+	for _, topic := range topics {
+		select {
+		case <-exit:
+			return
+		default:
 		}
+		h.flush(s, topic, exit)
+	}
+}
 
-		// discard response body
-		io.Copy(ioutil.Discard, r.Body)
-		r.Body.Close()
-		return nil
+// publish sends an encoded message to a subscriber node
+func (h *httpBroker) publish(node *registry.Node, b []byte) error {
+	scheme := "http"
+
+	// check if secure is added in metadata
+	if node.Metadata["secure"] == "true" {
+		scheme = "https"
 	}
 
-	srv := func(s []*registry.Service, b []byte) {
-		for _, service := range s {
-			var nodes []*registry.Node
+	vals := url.Values{}
+	vals.Add("id", node.Id)
 
-			for _, node := range service.Nodes {
-				// only use nodes tagged with broker http
-				if node.Metadata["broker"] != "http" {
-					continue
-				}
+	uri := fmt.Sprintf("%s://%s%s?%s", scheme, node.Address, DefaultSubPath, vals.Encode())
+	r, err := h.c.Post(uri, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
 
-				// look for nodes for the topic
-				if node.Metadata["topic"] != topic {
-					continue
-				}
+	// anything but a 2xx means the message wasn't acknowledged
+	if r.StatusCode < 200 || r.StatusCode >= 300 {
+		rsp, _ := ioutil.ReadAll(r.Body)
+		return merr.Parse(string(rsp))
+	}
+
+	// discard response body
+	io.Copy(ioutil.Discard, r.Body)
+	return nil
+}
+
+// deliver sends a message to the subscribers of its topic, or only to the
+// subscriber it was saved for. The message is saved to the inbox for replay
+// if it couldn't be delivered to any of them.
+func (h *httpBroker) deliver(s []*registry.Service, msg *InboxMessage, exit chan bool) {
+	var subscribed bool
+
+	for _, service := range s {
+		broadcast := service.Version == broadcastVersion
 
-				nodes = append(nodes, node)
+		// skip the subscribers the message isn't saved for
+		if len(msg.Node) > 0 && !broadcast {
+			continue
+		}
+		if len(msg.Queue) > 0 && service.Version != msg.Queue {
+			continue
+		}
+
+		var nodes []*registry.Node
+
+		for _, node := range service.Nodes {
+			if len(msg.Node) > 0 && node.Id != msg.Node {
+				continue
 			}
 
-			// only process if we have nodes
-			if len(nodes) == 0 {
+			// only use nodes tagged with broker http
+			if node.Metadata["broker"] != "http" {
 				continue
 			}
 
-			switch service.Version {
-			// broadcast version means broadcast to all nodes
-			case broadcastVersion:
-				var success bool
+			// look for nodes for the topic
+			if node.Metadata["topic"] != msg.Topic {
+				continue
+			}
 
-				// publish to all nodes
-				for _, node := range nodes {
-					// publish async
-					if err := pub(node, topic, b); err == nil {
-						success = true
-					}
-				}
+			nodes = append(nodes, node)
+		}
 
-				// save if it failed to publish at least once
-				if !success {
-					h.saveMessage(topic, b)
-				}
-			default:
-				// select node to publish to
-				node := nodes[rand.Int()%len(nodes)]
-
-				// publish async to one node
-				if err := pub(node, topic, b); err != nil {
-					// if failed save it
-					h.saveMessage(topic, b)
-				}
-			}
+		// only process if we have nodes
+		if len(nodes) == 0 {
+			continue
 		}
-	}
 
-	// do the rest async
-	go func() {
-		// get a third of the backlog
-		messages := h.getMessage(topic, 8)
-		delay := (len(messages) > 1)
-
-		// publish all the messages
-		for _, msg := range messages {
-			// serialize here
-			srv(s, msg)
-
-			// sending a backlog of messages
-			if delay {
-				time.Sleep(time.Millisecond * 100)
+		subscribed = true
+
+		// broadcast version means broadcast to all nodes
+		if broadcast {
+			// publish to all nodes, tracking each one
+			for _, node := range nodes {
+				h.send(&delivery{msg: msg, nodes: []*registry.Node{node}}, exit)
 			}
+			continue
 		}
-	}()
 
-	return nil
+		// publish to one node of the queue
+		h.send(&delivery{msg: msg, nodes: nodes, queue: service.Version}, exit)
+	}
+
+	switch {
+	case subscribed:
+	// the broadcast subscriber unsubscribed, its id isn't reused
+	case len(msg.Node) > 0:
+		if logger.V(logger.DebugLevel, logger.DefaultLogger) {
+			logger.Debugf("Dropping message for topic %s, subscriber %s is gone", msg.Topic, msg.Node)
+		}
+	// keep the message until a subscriber comes back
+	default:
+		h.saveMessage(msg)
+	}
 }
 
 func (h *httpBroker) Subscribe(topic string, handler broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
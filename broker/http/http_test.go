@@ -1,6 +1,7 @@
 package http
 
 import (
+	"errors"
 	"sync"
 	"testing"
 	"time"
@@ -9,6 +10,8 @@ import (
 	"github.com/micro/go-micro/v2/broker"
 	"github.com/micro/go-micro/v2/registry"
 	"github.com/micro/go-micro/v2/registry/memory"
+	"github.com/micro/go-micro/v2/store"
+	smemory "github.com/micro/go-micro/v2/store/memory"
 )
 
 var (
@@ -234,6 +237,404 @@ func TestBroker(t *testing.T) {
 	}
 }
 
+func TestInbox(t *testing.T) {
+	// the store is shared with other keys
+	s := smemory.NewStore()
+	if err := s.Write(&store.Record{Key: "users/1", Value: []byte("user")}); err != nil {
+		t.Fatal(err)
+	}
+
+	inboxes := map[string]Inbox{
+		"memory": NewMemoryInbox(InboxMaxMessages(3)),
+		"store":  NewStoreInbox(s, InboxMaxMessages(3)),
+	}
+
+	for name, inbox := range inboxes {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+
+			for i := 0; i < 5; i++ {
+				msg := &InboxMessage{
+					Topic:   "test",
+					Body:    []byte{byte(i)},
+					Created: now.Add(time.Duration(i) * time.Millisecond),
+				}
+				if err := inbox.Save(msg); err != nil {
+					t.Fatalf("Unexpected save error: %v", err)
+				}
+			}
+
+			topics, err := inbox.Topics()
+			if err != nil || len(topics) != 1 || topics[0] != "test" {
+				t.Fatalf("Unexpected topics %v: %v", topics, err)
+			}
+
+			// the oldest messages are dropped
+			msgs, err := inbox.Take("test", 2)
+			if err != nil {
+				t.Fatalf("Unexpected take error: %v", err)
+			}
+			if len(msgs) != 2 || msgs[0].Body[0] != 2 || msgs[1].Body[0] != 3 {
+				t.Fatalf("Unexpected messages %v", msgs)
+			}
+
+			msgs, _ = inbox.Take("test", 2)
+			if len(msgs) != 1 || msgs[0].Body[0] != 4 {
+				t.Fatalf("Unexpected messages %v", msgs)
+			}
+		})
+	}
+}
+
+func TestInboxMaxAge(t *testing.T) {
+	inboxes := map[string]Inbox{
+		"memory": NewMemoryInbox(InboxMaxAge(time.Minute)),
+		"store":  NewStoreInbox(smemory.NewStore(), InboxMaxAge(time.Minute)),
+	}
+
+	for name, inbox := range inboxes {
+		t.Run(name, func(t *testing.T) {
+			inbox.Save(&InboxMessage{Topic: "test", Body: []byte("old"), Created: time.Now().Add(-time.Hour)})
+			inbox.Save(&InboxMessage{Topic: "test", Body: []byte("new"), Created: time.Now()})
+
+			msgs, err := inbox.Take("test", 8)
+			if err != nil {
+				t.Fatalf("Unexpected take error: %v", err)
+			}
+			if len(msgs) != 1 || string(msgs[0].Body) != "new" {
+				t.Fatalf("Unexpected messages %v", msgs)
+			}
+		})
+	}
+}
+
+func TestInboxTarget(t *testing.T) {
+	inboxes := map[string]Inbox{
+		"memory": NewMemoryInbox(),
+		"store":  NewStoreInbox(smemory.NewStore()),
+	}
+
+	for name, inbox := range inboxes {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+			saved := []*InboxMessage{
+				{Topic: "test", Body: []byte("all"), Created: now},
+				{Topic: "test", Body: []byte("node"), Created: now.Add(time.Millisecond), Node: "node/1"},
+				{Topic: "test", Body: []byte("queue"), Created: now.Add(time.Millisecond * 2), Queue: "queue?1"},
+			}
+			for _, msg := range saved {
+				if err := inbox.Save(msg); err != nil {
+					t.Fatalf("Unexpected save error: %v", err)
+				}
+			}
+
+			topics, err := inbox.Topics()
+			if err != nil || len(topics) != 1 || topics[0] != "test" {
+				t.Fatalf("Unexpected topics %v: %v", topics, err)
+			}
+
+			msgs, err := inbox.Take("test", 8)
+			if err != nil {
+				t.Fatalf("Unexpected take error: %v", err)
+			}
+			if len(msgs) != len(saved) {
+				t.Fatalf("Expected %d messages got %d", len(saved), len(msgs))
+			}
+			for i, msg := range msgs {
+				if string(msg.Body) != string(saved[i].Body) || msg.Node != saved[i].Node || msg.Queue != saved[i].Queue {
+					t.Fatalf("Expected %+v got %+v", saved[i], msg)
+				}
+			}
+		})
+	}
+}
+
+func TestReplay(t *testing.T) {
+	m := newTestRegistry()
+	inbox := NewStoreInbox(smemory.NewStore())
+	b := NewBroker(broker.Registry(m), WithInbox(inbox), ReplayInterval(time.Millisecond*100))
+
+	if err := b.Init(); err != nil {
+		t.Fatalf("Unexpected init error: %v", err)
+	}
+
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error: %v", err)
+	}
+
+	msg := &broker.Message{
+		Header: map[string]string{
+			"Content-Type": "application/json",
+		},
+		Body: []byte(`{"message": "Hello World"}`),
+	}
+
+	// publish before anyone subscribes
+	if err := b.Publish("replay", msg); err != nil {
+		t.Fatalf("Unexpected publish error: %v", err)
+	}
+
+	done := make(chan bool)
+
+	sub, err := b.Subscribe("replay", func(p broker.Event) error {
+		if string(p.Message().Body) != string(msg.Body) {
+			t.Errorf("Unexpected msg %s, expected %s", string(p.Message().Body), string(msg.Body))
+		}
+		close(done)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected subscribe error: %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for replayed message")
+	}
+	sub.Unsubscribe()
+
+	if err := b.Disconnect(); err != nil {
+		t.Fatalf("Unexpected disconnect error: %v", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	m := newTestRegistry()
+	b := NewBroker(
+		broker.Registry(m),
+		DeliveryAttempts(3),
+		RetryBackoff(time.Millisecond*10),
+		DeadLetterTopic("dead"),
+	)
+
+	if err := b.Init(); err != nil {
+		t.Fatalf("Unexpected init error: %v", err)
+	}
+
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error: %v", err)
+	}
+
+	msg := &broker.Message{
+		Header: map[string]string{
+			"Content-Type": "application/json",
+		},
+		Body: []byte(`{"message": "Hello World"}`),
+	}
+
+	var mtx sync.Mutex
+	calls := make(map[string]int)
+	done := make(chan bool)
+	dead := make(chan *broker.Message)
+
+	// fails once then acknowledges manually
+	retry, err := b.Subscribe("retry", func(p broker.Event) error {
+		mtx.Lock()
+		defer mtx.Unlock()
+		calls["retry"]++
+		if calls["retry"] == 1 {
+			return errors.New("failed")
+		}
+		p.Ack()
+		close(done)
+		return nil
+	}, broker.DisableAutoAck())
+	if err != nil {
+		t.Fatalf("Unexpected subscribe error: %v", err)
+	}
+
+	// never acknowledges
+	fail, err := b.Subscribe("fail", func(p broker.Event) error {
+		mtx.Lock()
+		calls["fail"]++
+		mtx.Unlock()
+		return nil
+	}, broker.DisableAutoAck())
+	if err != nil {
+		t.Fatalf("Unexpected subscribe error: %v", err)
+	}
+
+	dl, err := b.Subscribe("dead", func(p broker.Event) error {
+		dead <- p.Message()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected subscribe error: %v", err)
+	}
+
+	if err := b.Publish("retry", msg); err != nil {
+		t.Fatalf("Unexpected publish error: %v", err)
+	}
+	if err := b.Publish("fail", msg); err != nil {
+		t.Fatalf("Unexpected publish error: %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for retry")
+	}
+
+	select {
+	case m := <-dead:
+		if m.Header[DeadLetterTopicHeader] != "fail" || m.Header[DeliveryAttemptsHeader] != "3" {
+			t.Fatalf("Unexpected dead letter headers %v", m.Header)
+		}
+		if string(m.Body) != string(msg.Body) {
+			t.Fatalf("Unexpected msg %s, expected %s", string(m.Body), string(msg.Body))
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for dead letter")
+	}
+
+	mtx.Lock()
+	if calls["retry"] != 2 || calls["fail"] != 3 {
+		t.Fatalf("Unexpected delivery attempts %v", calls)
+	}
+	mtx.Unlock()
+
+	retry.Unsubscribe()
+	fail.Unsubscribe()
+	dl.Unsubscribe()
+
+	if err := b.Disconnect(); err != nil {
+		t.Fatalf("Unexpected disconnect error: %v", err)
+	}
+}
+
+func TestRetryBroadcast(t *testing.T) {
+	m := newTestRegistry()
+	inbox := NewMemoryInbox()
+	b := NewBroker(
+		broker.Registry(m),
+		WithInbox(inbox),
+		DeliveryAttempts(2),
+		RetryBackoff(time.Millisecond*10),
+		ReplayInterval(time.Millisecond*50),
+	)
+	// each broker is a node of the broadcast
+	f := NewBroker(broker.Registry(m))
+
+	for _, br := range []broker.Broker{b, f} {
+		if err := br.Init(); err != nil {
+			t.Fatalf("Unexpected init error: %v", err)
+		}
+		if err := br.Connect(); err != nil {
+			t.Fatalf("Unexpected connect error: %v", err)
+		}
+	}
+
+	var mtx sync.Mutex
+	calls := make(map[string]int)
+
+	_, err := b.Subscribe("broadcast", func(p broker.Event) error {
+		mtx.Lock()
+		calls["ok"]++
+		mtx.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected subscribe error: %v", err)
+	}
+
+	// never acknowledges
+	fail, err := f.Subscribe("broadcast", func(p broker.Event) error {
+		mtx.Lock()
+		calls["fail"]++
+		mtx.Unlock()
+		return nil
+	}, broker.DisableAutoAck())
+	if err != nil {
+		t.Fatalf("Unexpected subscribe error: %v", err)
+	}
+
+	if err := b.Publish("broadcast", &broker.Message{Body: []byte(`{"message": "Hello World"}`)}); err != nil {
+		t.Fatalf("Unexpected publish error: %v", err)
+	}
+
+	// the message is replayed a few times
+	time.Sleep(time.Millisecond * 500)
+
+	// stop replaying while the failing subscriber is still there
+	if err := b.Disconnect(); err != nil {
+		t.Fatalf("Unexpected disconnect error: %v", err)
+	}
+	fail.Unsubscribe()
+	if err := f.Disconnect(); err != nil {
+		t.Fatalf("Unexpected disconnect error: %v", err)
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	// only the failing node gets the message again
+	if calls["ok"] != 1 || calls["fail"] <= 2 {
+		t.Fatalf("Unexpected delivery attempts %v", calls)
+	}
+
+	// and the inbox holds a single copy for it
+	node := fail.(*httpSubscriber).svc.Nodes[0].Id
+	msgs, _ := inbox.Take("broadcast", 8)
+	if len(msgs) != 1 || msgs[0].Node != node {
+		t.Fatalf("Expected 1 message for node %s got %+v", node, msgs)
+	}
+}
+
+func TestDisconnectReplay(t *testing.T) {
+	b := NewBroker(
+		broker.Registry(newTestRegistry()),
+		ReplayInterval(time.Millisecond*10),
+	)
+
+	if err := b.Init(); err != nil {
+		t.Fatalf("Unexpected init error: %v", err)
+	}
+
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error: %v", err)
+	}
+
+	// publish before anyone subscribes so the message is replayed
+	if err := b.Publish("block", &broker.Message{Body: []byte(`{"message": "Hello World"}`)}); err != nil {
+		t.Fatalf("Unexpected publish error: %v", err)
+	}
+
+	received := make(chan bool)
+	release := make(chan bool)
+	defer close(release)
+
+	// holds up the replay
+	_, err := b.Subscribe("block", func(p broker.Event) error {
+		close(received)
+		<-release
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected subscribe error: %v", err)
+	}
+
+	select {
+	case <-received:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for replayed message")
+	}
+
+	disconnected := make(chan error, 1)
+	go func() {
+		disconnected <- b.Disconnect()
+	}()
+
+	select {
+	case err := <-disconnected:
+		if err != nil {
+			t.Fatalf("Unexpected disconnect error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected disconnect not to wait for the replay")
+	}
+}
+
 func TestConcurrentSubBroker(t *testing.T) {
 	m := newTestRegistry()
 	b := NewBroker(broker.Registry(m))
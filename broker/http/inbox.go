@@ -0,0 +1,376 @@
+package http
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/micro/go-micro/v2/store"
+)
+
+var (
+	// DefaultInboxMaxMessages is the number of undelivered messages kept per topic
+	DefaultInboxMaxMessages = 64
+	// DefaultInboxPrefix is the prefix of the keys of a store inbox
+	DefaultInboxPrefix = "micro/broker/inbox/"
+)
+
+// Inbox holds published messages until they're delivered
+type Inbox interface {
+	// Save a message, dropping the oldest messages over the retention limits
+	Save(m *InboxMessage) error
+	// Take removes and returns up to num of the oldest messages for a topic
+	Take(topic string, num int) ([]*InboxMessage, error)
+	// Topics returns the topics with undelivered messages
+	Topics() ([]string, error)
+}
+
+// InboxMessage is an encoded message waiting to be delivered
+type InboxMessage struct {
+	Topic string
+	Body  []byte
+	// Created is when the message was first published
+	Created time.Time
+	// Node is the id of the broadcast subscriber and Queue the queue a
+	// message is saved for after failing to reach them, so subscribers
+	// which acknowledged it don't get it again. Messages without either
+	// are delivered to every subscriber of the topic.
+	Node  string
+	Queue string
+}
+
+// InboxOptions configures the retention of an inbox
+type InboxOptions struct {
+	// MaxMessages is the number of messages kept per topic
+	MaxMessages int
+	// MaxAge is how long a message is kept, zero keeps messages forever
+	MaxAge time.Duration
+	// Prefix of the keys of a store inbox, which only lists its own keys
+	// so the store may be shared
+	Prefix string
+}
+
+// InboxOption sets values in InboxOptions
+type InboxOption func(o *InboxOptions)
+
+// InboxMaxMessages sets the number of messages kept per topic
+func InboxMaxMessages(n int) InboxOption {
+	return func(o *InboxOptions) {
+		o.MaxMessages = n
+	}
+}
+
+// InboxMaxAge sets how long undelivered messages are kept
+func InboxMaxAge(d time.Duration) InboxOption {
+	return func(o *InboxOptions) {
+		o.MaxAge = d
+	}
+}
+
+// InboxPrefix sets the prefix of the keys of a store inbox
+func InboxPrefix(p string) InboxOption {
+	return func(o *InboxOptions) {
+		o.Prefix = p
+	}
+}
+
+func newInboxOptions(opts ...InboxOption) InboxOptions {
+	options := InboxOptions{
+		MaxMessages: DefaultInboxMaxMessages,
+		Prefix:      DefaultInboxPrefix,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
+
+type memoryInbox struct {
+	opts InboxOptions
+
+	sync.Mutex
+	messages map[string][]*InboxMessage
+}
+
+// NewMemoryInbox returns an inbox which keeps messages in memory
+func NewMemoryInbox(opts ...InboxOption) Inbox {
+	return &memoryInbox{
+		opts:     newInboxOptions(opts...),
+		messages: make(map[string][]*InboxMessage),
+	}
+}
+
+// expire drops messages older than MaxAge, must be called with the lock held
+func (m *memoryInbox) expire(topic string) []*InboxMessage {
+	c := m.messages[topic]
+	if m.opts.MaxAge <= 0 {
+		return c
+	}
+
+	var i int
+	for i < len(c) && time.Since(c[i].Created) > m.opts.MaxAge {
+		i++
+	}
+	return c[i:]
+}
+
+func (m *memoryInbox) Save(msg *InboxMessage) error {
+	m.Lock()
+	defer m.Unlock()
+
+	// get messages
+	c := m.expire(msg.Topic)
+
+	// save message, keeping messages saved again after
+	// a failed delivery in the order they were created
+	c = append(c, msg)
+	sort.SliceStable(c, func(i, j int) bool {
+		return c[i].Created.Before(c[j].Created)
+	})
+
+	// keep the newest messages
+	if max := m.opts.MaxMessages; max > 0 && len(c) > max {
+		c = c[len(c)-max:]
+	}
+
+	// save inbox
+	m.messages[msg.Topic] = c
+	return nil
+}
+
+func (m *memoryInbox) Take(topic string, num int) ([]*InboxMessage, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	// get messages
+	c := m.expire(topic)
+	if len(c) == 0 {
+		delete(m.messages, topic)
+		return nil, nil
+	}
+
+	if num > len(c) {
+		num = len(c)
+	}
+
+	msgs := make([]*InboxMessage, num)
+	copy(msgs, c)
+
+	if num == len(c) {
+		delete(m.messages, topic)
+	} else {
+		m.messages[topic] = c[num:]
+	}
+
+	return msgs, nil
+}
+
+func (m *memoryInbox) Topics() ([]string, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	topics := make([]string, 0, len(m.messages))
+	for topic := range m.messages {
+		topics = append(topics, topic)
+	}
+	return topics, nil
+}
+
+type storeInbox struct {
+	opts  InboxOptions
+	store store.Store
+
+	// serialises the read-modify-write of each topic
+	sync.Mutex
+}
+
+// NewStoreInbox returns an inbox which persists messages in a store so
+// they survive a restart. Messages are keyed by the inbox prefix, topic,
+// creation time and the subscriber they're saved for.
+func NewStoreInbox(s store.Store, opts ...InboxOption) Inbox {
+	return &storeInbox{
+		opts:  newInboxOptions(opts...),
+		store: s,
+	}
+}
+
+// prefix returns the key prefix for a topic
+func (s *storeInbox) prefix(topic string) string {
+	return s.opts.Prefix + url.PathEscape(topic) + "/"
+}
+
+// key returns a key which sorts in creation order, followed by the
+// subscriber the message is for as a query string
+func (s *storeInbox) key(msg *InboxMessage) string {
+	key := fmt.Sprintf("%s%020d-%s", s.prefix(msg.Topic), msg.Created.UnixNano(), uuid.New().String())
+
+	vals := url.Values{}
+	if len(msg.Node) > 0 {
+		vals.Set("node", msg.Node)
+	}
+	if len(msg.Queue) > 0 {
+		vals.Set("queue", msg.Queue)
+	}
+	if len(vals) > 0 {
+		key += "?" + vals.Encode()
+	}
+
+	return key
+}
+
+// parseKey returns the creation time and the subscriber encoded in a message key
+func (s *storeInbox) parseKey(key string) (*InboxMessage, error) {
+	name := key[strings.LastIndex(key, "/")+1:]
+
+	var vals url.Values
+	if i := strings.Index(name, "?"); i >= 0 {
+		v, err := url.ParseQuery(name[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		name, vals = name[:i], v
+	}
+
+	parts := strings.SplitN(name, "-", 2)
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InboxMessage{
+		Created: time.Unix(0, nanos),
+		Node:    vals.Get("node"),
+		Queue:   vals.Get("queue"),
+	}, nil
+}
+
+// keys returns the sorted keys for a topic
+func (s *storeInbox) keys(topic string) ([]string, error) {
+	keys, err := s.store.List(store.ListPrefix(s.prefix(topic)))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *storeInbox) Save(msg *InboxMessage) error {
+	s.Lock()
+	defer s.Unlock()
+
+	var expiry time.Duration
+	if s.opts.MaxAge > 0 {
+		expiry = s.opts.MaxAge - time.Since(msg.Created)
+		if expiry <= 0 {
+			return nil
+		}
+	}
+
+	if err := s.store.Write(&store.Record{
+		Key:    s.key(msg),
+		Value:  msg.Body,
+		Expiry: expiry,
+	}); err != nil {
+		return err
+	}
+
+	if s.opts.MaxMessages <= 0 {
+		return nil
+	}
+
+	keys, err := s.keys(msg.Topic)
+	if err != nil {
+		return err
+	}
+
+	// drop the oldest messages
+	for len(keys) > s.opts.MaxMessages {
+		if err := s.store.Delete(keys[0]); err != nil {
+			return err
+		}
+		keys = keys[1:]
+	}
+
+	return nil
+}
+
+func (s *storeInbox) Take(topic string, num int) ([]*InboxMessage, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	keys, err := s.keys(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	var msgs []*InboxMessage
+
+	for _, key := range keys {
+		if len(msgs) >= num {
+			break
+		}
+
+		msg, err := s.parseKey(key)
+		if err != nil || (s.opts.MaxAge > 0 && time.Since(msg.Created) > s.opts.MaxAge) {
+			s.store.Delete(key)
+			continue
+		}
+
+		records, err := s.store.Read(key)
+		if err == store.ErrNotFound {
+			continue
+		} else if err != nil {
+			return msgs, err
+		}
+
+		if err := s.store.Delete(key); err != nil {
+			return msgs, err
+		}
+
+		for _, r := range records {
+			msgs = append(msgs, &InboxMessage{
+				Topic:   topic,
+				Body:    r.Value,
+				Created: msg.Created,
+				Node:    msg.Node,
+				Queue:   msg.Queue,
+			})
+		}
+	}
+
+	return msgs, nil
+}
+
+func (s *storeInbox) Topics() ([]string, error) {
+	keys, err := s.store.List(store.ListPrefix(s.opts.Prefix))
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var topics []string
+
+	for _, key := range keys {
+		if !strings.HasPrefix(key, s.opts.Prefix) {
+			continue
+		}
+		key = key[len(s.opts.Prefix):]
+		i := strings.LastIndex(key, "/")
+		if i < 0 {
+			continue
+		}
+		topic, err := url.PathUnescape(key[:i])
+		if err != nil || seen[topic] {
+			continue
+		}
+		seen[topic] = true
+		topics = append(topics, topic)
+	}
+
+	return topics, nil
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"context"
+	"time"
+
+	"github.com/micro/go-micro/v2/broker"
+)
+
+type inboxKey struct{}
+type replayIntervalKey struct{}
+type deliveryAttemptsKey struct{}
+type retryBackoffKey struct{}
+type maxRetryBackoffKey struct{}
+type deadLetterTopicKey struct{}
+
+// WithInbox sets the inbox holding undelivered messages, defaults to an in memory inbox.
+// Use NewStoreInbox to keep messages across restarts.
+func WithInbox(i Inbox) broker.Option {
+	return setBrokerOption(inboxKey{}, i)
+}
+
+// ReplayInterval sets how often undelivered messages are retried
+func ReplayInterval(d time.Duration) broker.Option {
+	return setBrokerOption(replayIntervalKey{}, d)
+}
+
+// DeliveryAttempts sets the number of times a message is sent to a subscriber
+// before it's dead lettered
+func DeliveryAttempts(n int) broker.Option {
+	return setBrokerOption(deliveryAttemptsKey{}, n)
+}
+
+// RetryBackoff sets the delay before the first retry, it's doubled for every retry after
+func RetryBackoff(d time.Duration) broker.Option {
+	return setBrokerOption(retryBackoffKey{}, d)
+}
+
+// MaxRetryBackoff caps the delay between retries
+func MaxRetryBackoff(d time.Duration) broker.Option {
+	return setBrokerOption(maxRetryBackoffKey{}, d)
+}
+
+// DeadLetterTopic sets the topic messages are published to once they run out
+// of delivery attempts. Without it they're kept in the inbox for replay.
+func DeadLetterTopic(topic string) broker.Option {
+	return setBrokerOption(deadLetterTopicKey{}, topic)
+}
+
+// setBrokerOption returns a function to setup a context with given value
+func setBrokerOption(k, v interface{}) broker.Option {
+	return func(o *broker.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
@@ -3,7 +3,7 @@ module github.com/micro/go-plugins/broker/kafka/v2
 go 1.13
 
 require (
-	github.com/Shopify/sarama v1.25.0
+	github.com/Shopify/sarama v1.27.2
 	github.com/google/uuid v1.1.1
 	github.com/micro/go-micro/v2 v2.9.1
 )
@@ -0,0 +1,342 @@
+package kafka
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/micro/go-micro/v2/broker"
+	log "github.com/micro/go-micro/v2/logger"
+)
+
+const (
+	// OriginalTopicHeader is set to the subscribed topic on retried and dead lettered messages
+	OriginalTopicHeader = "Micro-Original-Topic"
+	// RetryAttemptHeader is set to the number of failed attempts to handle a message
+	RetryAttemptHeader = "Micro-Retry-Attempt"
+	// RetryAtHeader is set to when a retried message is due, in unix nanoseconds
+	RetryAtHeader = "Micro-Retry-At"
+	// ErrorHeader is set to the last error returned by the handler
+	ErrorHeader = "Micro-Error"
+)
+
+// RetryTopic returns the name of the retry topic for the nth delay of a
+// subscription. Topics are per queue so other consumer groups of the
+// topic don't see the retries.
+func RetryTopic(topic, queue string, n int) string {
+	return fmt.Sprintf("%s.%s.retry.%d", topic, queue, n)
+}
+
+// consumerGroupHandler is the implementation of sarama.ConsumerGroupHandler
+type consumerGroupHandler struct {
+	k       *kBroker
+	handler broker.Handler
+	subopts broker.SubscribeOptions
+	kopts   broker.Options
+	cg      sarama.ConsumerGroup
+	sess    sarama.ConsumerGroupSession
+
+	topic        string
+	batchSize    int
+	batchLinger  time.Duration
+	batchHandler BatchHandler
+	commit       CommitMode
+	retryDelays  []time.Duration
+	deadLetter   string
+
+	rebalance func(RebalanceEvent)
+	setup     func(sarama.ConsumerGroupSession) error
+	cleanup   func(sarama.ConsumerGroupSession) error
+}
+
+func newConsumerGroupHandler(k *kBroker, topic string, handler broker.Handler, opts broker.SubscribeOptions, cg sarama.ConsumerGroup) *consumerGroupHandler {
+	h := &consumerGroupHandler{
+		k:           k,
+		handler:     handler,
+		subopts:     opts,
+		kopts:       k.opts,
+		cg:          cg,
+		topic:       topic,
+		batchSize:   1,
+		batchLinger: DefaultBatchLinger,
+	}
+
+	if opts.Context == nil {
+		return h
+	}
+	if n, ok := opts.Context.Value(batchSizeKey{}).(int); ok && n > 0 {
+		h.batchSize = n
+	}
+	if d, ok := opts.Context.Value(batchLingerKey{}).(time.Duration); ok && d > 0 {
+		h.batchLinger = d
+	}
+	if bh, ok := opts.Context.Value(batchHandlerKey{}).(BatchHandler); ok {
+		h.batchHandler = bh
+	}
+	if m, ok := opts.Context.Value(commitModeKey{}).(CommitMode); ok {
+		h.commit = m
+	}
+	if d, ok := opts.Context.Value(retryDelaysKey{}).([]time.Duration); ok {
+		h.retryDelays = d
+	}
+	if t, ok := opts.Context.Value(deadLetterTopicKey{}).(string); ok {
+		h.deadLetter = t
+	}
+	if fn, ok := opts.Context.Value(rebalanceHandlerKey{}).(func(RebalanceEvent)); ok {
+		h.rebalance = fn
+	}
+	if fn, ok := opts.Context.Value(setupHookKey{}).(func(sarama.ConsumerGroupSession) error); ok {
+		h.setup = fn
+	}
+	if fn, ok := opts.Context.Value(cleanupHookKey{}).(func(sarama.ConsumerGroupSession) error); ok {
+		h.cleanup = fn
+	}
+	return h
+}
+
+// topics returns the subscribed topic followed by its retry topics
+func (h *consumerGroupHandler) topics() []string {
+	topics := []string{h.topic}
+	for i := range h.retryDelays {
+		topics = append(topics, RetryTopic(h.topic, h.subopts.Queue, i+1))
+	}
+	return topics
+}
+
+// event passes a rebalance to the rebalance handler
+func (h *consumerGroupHandler) event(t RebalanceType, sess sarama.ConsumerGroupSession) {
+	if h.rebalance == nil {
+		return
+	}
+	h.rebalance(RebalanceEvent{
+		Type:         t,
+		Topic:        h.topic,
+		Queue:        h.subopts.Queue,
+		MemberID:     sess.MemberID(),
+		GenerationID: sess.GenerationID(),
+		Claims:       sess.Claims(),
+	})
+}
+
+func (h *consumerGroupHandler) Setup(sess sarama.ConsumerGroupSession) error {
+	h.event(RebalanceAssigned, sess)
+	if h.setup != nil {
+		return h.setup(sess)
+	}
+	return nil
+}
+
+func (h *consumerGroupHandler) Cleanup(sess sarama.ConsumerGroupSession) error {
+	var err error
+	if h.cleanup != nil {
+		err = h.cleanup(sess)
+	}
+	h.event(RebalanceRevoked, sess)
+	return err
+}
+
+func (h *consumerGroupHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	var batch []*sarama.ConsumerMessage
+	var linger *time.Timer
+	var lingerC <-chan time.Time
+
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				// rebalancing, handle what we have before the claim is released
+				if linger != nil {
+					linger.Stop()
+				}
+				h.handle(sess, batch)
+				return nil
+			}
+			batch = append(batch, msg)
+			if len(batch) < h.batchSize {
+				if len(batch) == 1 {
+					linger = time.NewTimer(h.batchLinger)
+					lingerC = linger.C
+				}
+				continue
+			}
+			if linger != nil {
+				linger.Stop()
+			}
+		case <-lingerC:
+		}
+
+		h.handle(sess, batch)
+		batch = nil
+		linger = nil
+		lingerC = nil
+	}
+}
+
+// handle processes a batch of messages from one partition
+func (h *consumerGroupHandler) handle(sess sarama.ConsumerGroupSession, msgs []*sarama.ConsumerMessage) {
+	if len(msgs) == 0 {
+		return
+	}
+
+	pubs := make([]*publication, 0, len(msgs))
+	for _, msg := range msgs {
+		p := h.decode(sess, msg)
+		if p == nil {
+			continue
+		}
+		// the session ended while waiting, leave the rest to be redelivered
+		if !h.wait(sess, p) {
+			return
+		}
+		pubs = append(pubs, p)
+	}
+
+	if h.batchHandler != nil {
+		events := make([]broker.Event, len(pubs))
+		for i, p := range pubs {
+			events[i] = p
+		}
+		if err := h.batchHandler(events); err != nil {
+			for _, p := range pubs {
+				p.err = err
+				h.fail(p)
+			}
+		} else if h.subopts.AutoAck {
+			for _, p := range pubs {
+				p.Ack()
+			}
+		}
+	} else {
+		for _, p := range pubs {
+			if err := h.handler(p); err != nil {
+				p.err = err
+				h.fail(p)
+			} else if h.subopts.AutoAck {
+				p.Ack()
+			}
+		}
+	}
+
+	if h.commit == CommitBatch {
+		sess.Commit()
+	}
+}
+
+// decode returns the publication for a message, or nil if it can't be decoded
+func (h *consumerGroupHandler) decode(sess sarama.ConsumerGroupSession, msg *sarama.ConsumerMessage) *publication {
+	var m broker.Message
+	p := &publication{m: &m, t: msg.Topic, km: msg, cg: h.cg, sess: sess, commit: h.commit}
+
+	if err := h.kopts.Codec.Unmarshal(msg.Value, &m); err != nil {
+		p.err = err
+		p.m.Body = msg.Value
+		h.error(p, "failed to unmarshal")
+
+		// it will never decode, move it out of the way as is
+		if len(h.deadLetter) > 0 {
+			if _, _, err := h.k.p.SendMessage(&sarama.ProducerMessage{
+				Topic: h.deadLetter,
+				Value: sarama.ByteEncoder(msg.Value),
+			}); err != nil {
+				log.Errorf("[kafka]: failed to dead letter message: %v", err)
+				return nil
+			}
+			p.Ack()
+		}
+		return nil
+	}
+
+	// retried messages report the topic they were published to
+	if msg.Topic != h.topic {
+		if t, ok := m.Header[OriginalTopicHeader]; ok {
+			p.t = t
+		}
+	}
+
+	return p
+}
+
+// wait blocks until a retried message is due, returning false if
+// the session ends first
+func (h *consumerGroupHandler) wait(sess sarama.ConsumerGroupSession, p *publication) bool {
+	at, err := strconv.ParseInt(p.m.Header[RetryAtHeader], 10, 64)
+	if err != nil {
+		return true
+	}
+
+	d := time.Until(time.Unix(0, at))
+	if d <= 0 {
+		return true
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-sess.Context().Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// error calls the error handler or logs the error
+func (h *consumerGroupHandler) error(p *publication, msg string) {
+	if eh := h.kopts.ErrorHandler; eh != nil {
+		eh(p)
+		return
+	}
+	log.Errorf("[kafka]: %s: %v", msg, p.err)
+}
+
+// fail routes a message the handler failed on to the next retry topic
+// or the dead letter topic, so it doesn't hold up the partition
+func (h *consumerGroupHandler) fail(p *publication) {
+	h.error(p, "subscriber error")
+
+	if len(h.retryDelays) == 0 && len(h.deadLetter) == 0 {
+		return
+	}
+
+	attempt, _ := strconv.Atoi(p.m.Header[RetryAttemptHeader])
+
+	var err error
+	switch {
+	case attempt < len(h.retryDelays):
+		topic := RetryTopic(h.topic, h.subopts.Queue, attempt+1)
+		err = h.forward(topic, p, attempt+1, h.retryDelays[attempt])
+	case len(h.deadLetter) > 0:
+		err = h.forward(h.deadLetter, p, attempt+1, 0)
+	default:
+		log.Errorf("[kafka]: dropping message for topic %s after %d attempts", p.t, attempt+1)
+	}
+
+	if err != nil {
+		log.Errorf("[kafka]: failed to retry message: %v", err)
+		return
+	}
+
+	p.Ack()
+}
+
+// forward publishes a failed message to a retry or dead letter topic
+func (h *consumerGroupHandler) forward(topic string, p *publication, attempt int, delay time.Duration) error {
+	header := make(map[string]string, len(p.m.Header)+4)
+	for k, v := range p.m.Header {
+		header[k] = v
+	}
+
+	header[OriginalTopicHeader] = p.t
+	header[RetryAttemptHeader] = strconv.Itoa(attempt)
+	header[ErrorHeader] = p.err.Error()
+	delete(header, RetryAtHeader)
+	if delay > 0 {
+		header[RetryAtHeader] = strconv.FormatInt(time.Now().Add(delay).UnixNano(), 10)
+	}
+
+	return h.k.Publish(topic, &broker.Message{
+		Header: header,
+		Body:   p.m.Body,
+	})
+}
@@ -0,0 +1,255 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/micro/go-micro/v2/broker"
+	mjson "github.com/micro/go-micro/v2/codec/json"
+)
+
+type testSession struct {
+	ctx context.Context
+
+	sync.Mutex
+	marked  []int64
+	commits int
+}
+
+func newTestSession() *testSession {
+	return &testSession{ctx: context.Background()}
+}
+
+func (s *testSession) Claims() map[string][]int32 { return nil }
+func (s *testSession) MemberID() string           { return "member" }
+func (s *testSession) GenerationID() int32        { return 1 }
+func (s *testSession) Context() context.Context   { return s.ctx }
+
+func (s *testSession) MarkOffset(topic string, partition int32, offset int64, metadata string)  {}
+func (s *testSession) ResetOffset(topic string, partition int32, offset int64, metadata string) {}
+
+func (s *testSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.Lock()
+	s.marked = append(s.marked, msg.Offset)
+	s.Unlock()
+}
+
+func (s *testSession) Commit() {
+	s.Lock()
+	s.commits++
+	s.Unlock()
+}
+
+func (s *testSession) state() ([]int64, int) {
+	s.Lock()
+	defer s.Unlock()
+	return s.marked, s.commits
+}
+
+type testClaim struct {
+	msgs chan *sarama.ConsumerMessage
+}
+
+func newTestClaim() *testClaim {
+	return &testClaim{msgs: make(chan *sarama.ConsumerMessage)}
+}
+
+func (c *testClaim) Topic() string                            { return "test" }
+func (c *testClaim) Partition() int32                         { return 0 }
+func (c *testClaim) InitialOffset() int64                     { return 0 }
+func (c *testClaim) HighWaterMarkOffset() int64               { return 0 }
+func (c *testClaim) Messages() <-chan *sarama.ConsumerMessage { return c.msgs }
+
+func testMessage(t *testing.T, topic string, offset int64, header map[string]string) *sarama.ConsumerMessage {
+	b, err := mjson.Marshaler{}.Marshal(&broker.Message{
+		Header: header,
+		Body:   []byte(fmt.Sprintf("message %d", offset)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &sarama.ConsumerMessage{Topic: topic, Offset: offset, Value: b}
+}
+
+func testBroker(p sarama.SyncProducer) *kBroker {
+	return &kBroker{
+		opts: broker.Options{Codec: mjson.Marshaler{}},
+		p:    p,
+	}
+}
+
+func testOptions(opts ...broker.SubscribeOption) broker.SubscribeOptions {
+	options := broker.SubscribeOptions{
+		AutoAck: true,
+		Queue:   "queue",
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
+
+// consume runs ConsumeClaim until the claim is closed
+func consume(h *consumerGroupHandler, sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- h.ConsumeClaim(sess, claim)
+	}()
+	return done
+}
+
+func expectBatch(t *testing.T, batches <-chan int, size int) {
+	t.Helper()
+	select {
+	case n := <-batches:
+		if n != size {
+			t.Fatalf("expected a batch of %d messages got %d", size, n)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected a batch of %d messages", size)
+	}
+}
+
+func TestBatchSize(t *testing.T) {
+	batches := make(chan int, 4)
+	opts := testOptions(
+		BatchSize(3),
+		BatchLinger(time.Hour),
+		Commit(CommitBatch),
+		SubscribeBatchHandler(func(events []broker.Event) error {
+			batches <- len(events)
+			return nil
+		}),
+	)
+
+	h := newConsumerGroupHandler(testBroker(nil), "test", nil, opts, nil)
+	sess, claim := newTestSession(), newTestClaim()
+	done := consume(h, sess, claim)
+
+	for i := 0; i < 3; i++ {
+		claim.msgs <- testMessage(t, "test", int64(i), nil)
+	}
+	expectBatch(t, batches, 3)
+
+	close(claim.msgs)
+	<-done
+
+	marked, commits := sess.state()
+	if !reflect.DeepEqual(marked, []int64{0, 1, 2}) || commits != 1 {
+		t.Fatalf("expected 3 messages marked and 1 commit got %v and %d", marked, commits)
+	}
+}
+
+func TestBatchLinger(t *testing.T) {
+	batches := make(chan int, 4)
+	opts := testOptions(
+		BatchSize(10),
+		BatchLinger(time.Millisecond*20),
+		SubscribeBatchHandler(func(events []broker.Event) error {
+			batches <- len(events)
+			return nil
+		}),
+	)
+
+	h := newConsumerGroupHandler(testBroker(nil), "test", nil, opts, nil)
+	sess, claim := newTestSession(), newTestClaim()
+	done := consume(h, sess, claim)
+
+	// a partial batch is handled once the linger expires
+	claim.msgs <- testMessage(t, "test", 0, nil)
+	claim.msgs <- testMessage(t, "test", 1, nil)
+	expectBatch(t, batches, 2)
+
+	// and when the claim is released
+	claim.msgs <- testMessage(t, "test", 2, nil)
+	close(claim.msgs)
+	expectBatch(t, batches, 1)
+	<-done
+
+	marked, commits := sess.state()
+	if !reflect.DeepEqual(marked, []int64{0, 1, 2}) || commits != 0 {
+		t.Fatalf("expected 3 messages marked and no commits got %v and %d", marked, commits)
+	}
+}
+
+// checkRetry checks a message forwarded to a retry or dead letter topic
+func checkRetry(attempt string, delayed bool) mocks.ValueChecker {
+	return func(val []byte) error {
+		var m broker.Message
+		if err := json.Unmarshal(val, &m); err != nil {
+			return err
+		}
+		if m.Header[OriginalTopicHeader] != "test" {
+			return fmt.Errorf("expected original topic test got %q", m.Header[OriginalTopicHeader])
+		}
+		if m.Header[RetryAttemptHeader] != attempt {
+			return fmt.Errorf("expected attempt %s got %q", attempt, m.Header[RetryAttemptHeader])
+		}
+		if m.Header[ErrorHeader] != "failed" {
+			return fmt.Errorf("expected the handler error got %q", m.Header[ErrorHeader])
+		}
+		if _, ok := m.Header[RetryAtHeader]; ok != delayed {
+			return fmt.Errorf("expected retry at to be set %v got %v", delayed, ok)
+		}
+		return nil
+	}
+}
+
+func TestRetryDeadLetter(t *testing.T) {
+	p := mocks.NewSyncProducer(t, nil)
+	// the first failure is retried after the delay
+	p.ExpectSendMessageWithCheckerFunctionAndSucceed(checkRetry("1", true))
+	// the failed retry is dead lettered
+	p.ExpectSendMessageWithCheckerFunctionAndSucceed(checkRetry("2", false))
+	// a message which can't be decoded is dead lettered as is
+	p.ExpectSendMessageWithCheckerFunctionAndSucceed(func(val []byte) error {
+		if string(val) != "invalid" {
+			return fmt.Errorf("expected the raw message got %q", val)
+		}
+		return nil
+	})
+
+	opts := testOptions(
+		RetryTopics(time.Minute),
+		DeadLetterTopic("test.dlq"),
+	)
+
+	handler := func(broker.Event) error {
+		return errors.New("failed")
+	}
+
+	h := newConsumerGroupHandler(testBroker(p), "test", handler, opts, nil)
+	if topics := h.topics(); !reflect.DeepEqual(topics, []string{"test", "test.queue.retry.1"}) {
+		t.Fatalf("expected the topic and its retry topic got %v", topics)
+	}
+
+	sess, claim := newTestSession(), newTestClaim()
+	done := consume(h, sess, claim)
+
+	claim.msgs <- testMessage(t, "test", 0, nil)
+	claim.msgs <- testMessage(t, RetryTopic("test", "queue", 1), 1, map[string]string{
+		OriginalTopicHeader: "test",
+		RetryAttemptHeader:  "1",
+	})
+	claim.msgs <- &sarama.ConsumerMessage{Topic: "test", Offset: 2, Value: []byte("invalid")}
+	close(claim.msgs)
+	<-done
+
+	// the messages are out of the way once forwarded
+	marked, _ := sess.state()
+	if !reflect.DeepEqual(marked, []int64{0, 1, 2}) {
+		t.Fatalf("expected 3 messages marked got %v", marked)
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -4,6 +4,7 @@ package kafka
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/google/uuid"
@@ -19,7 +20,8 @@ type kBroker struct {
 	c sarama.Client
 	p sarama.SyncProducer
 
-	sc []sarama.Client
+	sc   []sarama.Client
+	subs map[*subscriber]bool
 
 	connected bool
 	scMutex   sync.Mutex
@@ -27,9 +29,17 @@ type kBroker struct {
 }
 
 type subscriber struct {
+	k    *kBroker
+	c    sarama.Client
 	cg   sarama.ConsumerGroup
 	t    string
 	opts broker.SubscribeOptions
+
+	cancel context.CancelFunc
+	// closed when the consume loop has exited
+	done chan struct{}
+	once sync.Once
+	err  error
 }
 
 type publication struct {
@@ -39,6 +49,8 @@ type publication struct {
 	km   *sarama.ConsumerMessage
 	m    *broker.Message
 	sess sarama.ConsumerGroupSession
+
+	commit CommitMode
 }
 
 func init() {
@@ -55,6 +67,9 @@ func (p *publication) Message() *broker.Message {
 
 func (p *publication) Ack() error {
 	p.sess.MarkMessage(p.km, "")
+	if p.commit == CommitAck {
+		p.sess.Commit()
+	}
 	return nil
 }
 
@@ -70,8 +85,31 @@ func (s *subscriber) Topic() string {
 	return s.t
 }
 
+// Unsubscribe stops consuming and waits for the messages already received
+// to be handled and their offsets committed before leaving the group
 func (s *subscriber) Unsubscribe() error {
-	return s.cg.Close()
+	s.once.Do(func() {
+		s.cancel()
+		<-s.done
+
+		if err := s.cg.Close(); err != nil {
+			s.err = err
+		}
+		if err := s.c.Close(); err != nil && s.err == nil {
+			s.err = err
+		}
+
+		s.k.scMutex.Lock()
+		delete(s.k.subs, s)
+		for i, c := range s.k.sc {
+			if c == s.c {
+				s.k.sc = append(s.k.sc[:i], s.k.sc[i+1:]...)
+				break
+			}
+		}
+		s.k.scMutex.Unlock()
+	})
+	return s.err
 }
 
 func (k *kBroker) Address() string {
@@ -114,6 +152,7 @@ func (k *kBroker) Connect() error {
 	k.c = c
 	k.p = p
 	k.sc = make([]sarama.Client, 0)
+	k.subs = make(map[*subscriber]bool)
 	k.connected = true
 	defer k.scMutex.Unlock()
 
@@ -121,6 +160,20 @@ func (k *kBroker) Connect() error {
 }
 
 func (k *kBroker) Disconnect() error {
+	// drain the subscribers before closing their clients
+	k.scMutex.Lock()
+	subs := make([]*subscriber, 0, len(k.subs))
+	for s := range k.subs {
+		subs = append(subs, s)
+	}
+	k.scMutex.Unlock()
+
+	for _, s := range subs {
+		if err := s.Unsubscribe(); err != nil {
+			log.Errorf("[kafka]: failed to unsubscribe from %s: %v", s.t, err)
+		}
+	}
+
 	k.scMutex.Lock()
 	defer k.scMutex.Unlock()
 	for _, client := range k.sc {
@@ -198,35 +251,77 @@ func (k *kBroker) Subscribe(topic string, handler broker.Handler, opts ...broker
 	if err != nil {
 		return nil, err
 	}
-	h := &consumerGroupHandler{
-		handler: handler,
-		subopts: opt,
-		kopts:   k.opts,
-		cg:      cg,
+	h := newConsumerGroupHandler(k, topic, handler, opt, cg)
+
+	return k.subscribe(topic, h, opt, c, cg), nil
+}
+
+// subscribe starts consuming with the consumer group of a subscription
+func (k *kBroker) subscribe(topic string, h *consumerGroupHandler, opt broker.SubscribeOptions, c sarama.Client, cg sarama.ConsumerGroup) *subscriber {
+	parent := context.Background()
+	if opt.Context != nil {
+		if ctx, ok := opt.Context.Value(subscribeContextKey{}).(context.Context); ok && ctx != nil {
+			parent = ctx
+		}
+	}
+	ctx, cancel := context.WithCancel(parent)
+
+	sub := &subscriber{
+		k:      k,
+		c:      c,
+		cg:     cg,
+		opts:   opt,
+		t:      topic,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	k.scMutex.Lock()
+	if k.subs != nil {
+		k.subs[sub] = true
 	}
-	ctx := context.Background()
-	topics := []string{topic}
+	k.scMutex.Unlock()
+
+	// the errors channel is closed when the consumer group is closed
 	go func() {
-		for {
-			select {
-			case err := <-cg.Errors():
-				if err != nil {
-					log.Errorf("consumer error:", err)
-				}
-			default:
-				err := cg.Consume(ctx, topics, h)
-				switch err {
-				case sarama.ErrClosedConsumerGroup:
-					return
-				case nil:
-					continue
-				default:
-					log.Error(err)
-				}
-			}
+		for err := range cg.Errors() {
+			log.Errorf("[kafka]: consumer error: %v", err)
 		}
 	}()
-	return &subscriber{cg: cg, opts: opt, t: topic}, nil
+
+	go sub.consume(ctx, h)
+
+	return sub
+}
+
+// consume joins the consumer group until the context is cancelled or the
+// group is closed, rejoining after every rebalance
+func (s *subscriber) consume(ctx context.Context, h *consumerGroupHandler) {
+	defer close(s.done)
+
+	topics := h.topics()
+	for {
+		// blocks for the length of a session, returning when the
+		// group rebalances or the context is cancelled
+		err := s.cg.Consume(ctx, topics, h)
+		switch err {
+		case sarama.ErrClosedConsumerGroup:
+			return
+		case nil:
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		log.Errorf("[kafka]: failed to consume %s: %v", s.t, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(DefaultConsumeBackoff):
+		}
+	}
 }
 
 func (k *kBroker) String() string {
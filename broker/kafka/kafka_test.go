@@ -0,0 +1,194 @@
+package kafka
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/micro/go-micro/v2/broker"
+)
+
+// testGroup is a consumer group with a single claim per session. Like
+// sarama the messages of the claim are closed when the context is done.
+type testGroup struct {
+	msgs   chan *sarama.ConsumerMessage
+	errs   chan error
+	closed chan struct{}
+	once   sync.Once
+
+	sync.Mutex
+	sessions []*testSession
+}
+
+func newTestGroup() *testGroup {
+	return &testGroup{
+		msgs:   make(chan *sarama.ConsumerMessage),
+		errs:   make(chan error),
+		closed: make(chan struct{}),
+	}
+}
+
+func (g *testGroup) Consume(ctx context.Context, topics []string, handler sarama.ConsumerGroupHandler) error {
+	select {
+	case <-g.closed:
+		return sarama.ErrClosedConsumerGroup
+	default:
+	}
+
+	sess := &testSession{ctx: ctx}
+	g.Lock()
+	g.sessions = append(g.sessions, sess)
+	g.Unlock()
+
+	if err := handler.Setup(sess); err != nil {
+		return err
+	}
+
+	claim := newTestClaim()
+	go func() {
+		defer close(claim.msgs)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-g.msgs:
+				select {
+				case claim.msgs <- msg:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	err := handler.ConsumeClaim(sess, claim)
+	if cerr := handler.Cleanup(sess); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (g *testGroup) Errors() <-chan error {
+	return g.errs
+}
+
+func (g *testGroup) Close() error {
+	g.once.Do(func() {
+		close(g.closed)
+		close(g.errs)
+	})
+	return nil
+}
+
+func (g *testGroup) marked() []int64 {
+	g.Lock()
+	defer g.Unlock()
+
+	var marked []int64
+	for _, s := range g.sessions {
+		m, _ := s.state()
+		marked = append(marked, m...)
+	}
+	return marked
+}
+
+type testClient struct {
+	sarama.Client
+}
+
+func (c *testClient) Close() error {
+	return nil
+}
+
+func TestUnsubscribeDrain(t *testing.T) {
+	started := make(chan bool)
+	release := make(chan bool)
+
+	var mtx sync.Mutex
+	var events []RebalanceType
+
+	opts := testOptions(RebalanceHandler(func(e RebalanceEvent) {
+		mtx.Lock()
+		events = append(events, e.Type)
+		mtx.Unlock()
+	}))
+
+	handler := func(broker.Event) error {
+		started <- true
+		<-release
+		return nil
+	}
+
+	k := testBroker(nil)
+	k.subs = make(map[*subscriber]bool)
+
+	g := newTestGroup()
+	h := newConsumerGroupHandler(k, "test", handler, opts, g)
+	sub := k.subscribe("test", h, opts, &testClient{}, g)
+
+	g.msgs <- testMessage(t, "test", 0, nil)
+	<-started
+
+	unsubscribed := make(chan error, 1)
+	go func() {
+		unsubscribed <- sub.Unsubscribe()
+	}()
+
+	// the message being handled holds up the unsubscribe
+	select {
+	case <-unsubscribed:
+		t.Fatal("expected unsubscribe to wait for the message to be handled")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	close(release)
+
+	select {
+	case err := <-unsubscribed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected unsubscribe to return once the message was handled")
+	}
+
+	if marked := g.marked(); !reflect.DeepEqual(marked, []int64{0}) {
+		t.Fatalf("expected the message to be marked got %v", marked)
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if !reflect.DeepEqual(events, []RebalanceType{RebalanceAssigned, RebalanceRevoked}) {
+		t.Fatalf("expected the partitions to be assigned and revoked got %v", events)
+	}
+
+	if len(k.subs) != 0 {
+		t.Fatalf("expected the subscriber to be removed got %d", len(k.subs))
+	}
+}
+
+func TestSubscribeContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	opts := testOptions(SubscribeContext(ctx))
+
+	k := testBroker(nil)
+	g := newTestGroup()
+	h := newConsumerGroupHandler(k, "test", func(broker.Event) error { return nil }, opts, g)
+	sub := k.subscribe("test", h, opts, &testClient{}, g)
+
+	cancel()
+
+	// the consume loop ends with the context
+	select {
+	case <-sub.done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the consume loop to end when the context is cancelled")
+	}
+
+	if err := sub.Unsubscribe(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,15 +2,21 @@ package kafka
 
 import (
 	"context"
+	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/micro/go-micro/v2/broker"
-	log "github.com/micro/go-micro/v2/logger"
 )
 
 var (
 	DefaultBrokerConfig  = sarama.NewConfig()
 	DefaultClusterConfig = sarama.NewConfig()
+
+	// DefaultBatchLinger is how long a partial batch waits for more messages
+	DefaultBatchLinger = time.Millisecond * 100
+	// DefaultConsumeBackoff is how long to wait before rejoining the
+	// consumer group after an error
+	DefaultConsumeBackoff = time.Second
 )
 
 type brokerConfigKey struct{}
@@ -37,45 +43,126 @@ func SubscribeConfig(c *sarama.Config) broker.SubscribeOption {
 	return setSubscribeOption(subscribeConfigKey{}, c)
 }
 
-// consumerGroupHandler is the implementation of sarama.ConsumerGroupHandler
-type consumerGroupHandler struct {
-	handler broker.Handler
-	subopts broker.SubscribeOptions
-	kopts   broker.Options
-	cg      sarama.ConsumerGroup
-	sess    sarama.ConsumerGroupSession
+type batchSizeKey struct{}
+
+// BatchSize sets the number of messages handled together. Offsets
+// are marked once the whole batch has been handled.
+func BatchSize(n int) broker.SubscribeOption {
+	return setSubscribeOption(batchSizeKey{}, n)
+}
+
+type batchLingerKey struct{}
+
+// BatchLinger sets how long to wait for a batch to fill up before
+// handling the messages received so far
+func BatchLinger(d time.Duration) broker.SubscribeOption {
+	return setSubscribeOption(batchLingerKey{}, d)
+}
+
+// BatchHandler is used to process a batch of messages
+type BatchHandler func([]broker.Event) error
+
+type batchHandlerKey struct{}
+
+// SubscribeBatchHandler handles messages a batch at a time instead of
+// calling the handler passed to Subscribe for every message. If it
+// returns an error every message in the batch is retried.
+func SubscribeBatchHandler(h BatchHandler) broker.SubscribeOption {
+	return setSubscribeOption(batchHandlerKey{}, h)
+}
+
+// CommitMode controls when marked offsets are committed
+type CommitMode int
+
+const (
+	// CommitInterval commits marked offsets every Consumer.Offsets.CommitInterval
+	CommitInterval CommitMode = iota
+	// CommitBatch commits marked offsets after every batch
+	CommitBatch
+	// CommitAck commits the offset of every message as it's acked. Use with
+	// broker.DisableAutoAck to only commit when the handler calls Ack.
+	CommitAck
+)
+
+type commitModeKey struct{}
+
+// Commit sets when offsets are committed. Batch and ack commits are
+// synchronous, offsets are still committed on the interval as well.
+func Commit(m CommitMode) broker.SubscribeOption {
+	return setSubscribeOption(commitModeKey{}, m)
+}
+
+type retryDelaysKey struct{}
+
+// RetryTopics routes messages which fail to be handled to a retry topic per
+// delay, where they're handled again once the delay has passed. Retry topics
+// are named by RetryTopic and must exist or be auto created by the cluster.
+func RetryTopics(delays ...time.Duration) broker.SubscribeOption {
+	return setSubscribeOption(retryDelaysKey{}, delays)
 }
 
-func (*consumerGroupHandler) Setup(_ sarama.ConsumerGroupSession) error   { return nil }
-func (*consumerGroupHandler) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
-func (h *consumerGroupHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
-	for msg := range claim.Messages() {
-		var m broker.Message
-		p := &publication{m: &m, t: msg.Topic, km: msg, cg: h.cg, sess: sess}
-		eh := h.kopts.ErrorHandler
-
-		if err := h.kopts.Codec.Unmarshal(msg.Value, &m); err != nil {
-			p.err = err
-			p.m.Body = msg.Value
-			if eh != nil {
-				eh(p)
-			} else {
-				log.Errorf("[kafka]: failed to unmarshal: %v", err)
-			}
-			continue
-		}
-
-		err := h.handler(p)
-		if err == nil && h.subopts.AutoAck {
-			sess.MarkMessage(msg, "")
-		} else if err != nil {
-			p.err = err
-			if eh != nil {
-				eh(p)
-			} else {
-				log.Errorf("[kafka]: subscriber error: %v", err)
-			}
-		}
+type deadLetterTopicKey struct{}
+
+// DeadLetterTopic sets the topic for messages which can't be decoded or
+// have failed every retry. Without one they're dropped after the error
+// handler is called.
+func DeadLetterTopic(topic string) broker.SubscribeOption {
+	return setSubscribeOption(deadLetterTopicKey{}, topic)
+}
+
+// RebalanceType is the stage of a consumer group rebalance
+type RebalanceType int
+
+const (
+	// RebalanceAssigned is when a session starts with newly claimed partitions
+	RebalanceAssigned RebalanceType = iota
+	// RebalanceRevoked is when a session ends and its partitions are released
+	RebalanceRevoked
+)
+
+func (t RebalanceType) String() string {
+	switch t {
+	case RebalanceAssigned:
+		return "assigned"
+	case RebalanceRevoked:
+		return "revoked"
+	default:
+		return "unknown"
 	}
-	return nil
+}
+
+// RebalanceEvent describes the partitions claimed by a subscriber
+// when they're assigned or revoked
+type RebalanceEvent struct {
+	Type         RebalanceType
+	Topic        string
+	Queue        string
+	MemberID     string
+	GenerationID int32
+	// Claims are the claimed partitions by topic
+	Claims map[string][]int32
+}
+
+type rebalanceHandlerKey struct{}
+
+// RebalanceHandler is called with an event whenever the subscriber's
+// partitions are assigned or revoked
+func RebalanceHandler(fn func(RebalanceEvent)) broker.SubscribeOption {
+	return setSubscribeOption(rebalanceHandlerKey{}, fn)
+}
+
+type setupHookKey struct{}
+
+// SetupHook is called at the start of every consumer group session,
+// before any messages are handled. An error ends the session.
+func SetupHook(fn func(sarama.ConsumerGroupSession) error) broker.SubscribeOption {
+	return setSubscribeOption(setupHookKey{}, fn)
+}
+
+type cleanupHookKey struct{}
+
+// CleanupHook is called at the end of every consumer group session,
+// once all messages have been handled and before offsets are committed
+func CleanupHook(fn func(sarama.ConsumerGroupSession) error) broker.SubscribeOption {
+	return setSubscribeOption(cleanupHookKey{}, fn)
 }
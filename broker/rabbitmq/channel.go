@@ -6,6 +6,7 @@ package rabbitmq
 
 import (
 	"errors"
+	"sync"
 
 	"github.com/google/uuid"
 	"github.com/streadway/amqp"
@@ -15,6 +16,11 @@ type rabbitMQChannel struct {
 	uuid       string
 	connection *amqp.Connection
 	channel    *amqp.Channel
+
+	// publishes are serialised to number them once in confirm mode
+	mtx        sync.Mutex
+	confirming bool
+	tracker    *confirmTracker
 }
 
 func newRabbitChannel(conn *amqp.Connection, prefetchCount int, prefetchGlobal bool) (*rabbitMQChannel, error) {
@@ -53,11 +59,59 @@ func (r *rabbitMQChannel) Close() error {
 	return r.channel.Close()
 }
 
-func (r *rabbitMQChannel) Publish(exchange, key string, message amqp.Publishing) error {
+// Track listens for the confirms and returned messages of the publishes
+// on the channel, onReturn is called with the returned messages
+func (r *rabbitMQChannel) Track(onReturn func(amqp.Return)) {
+	r.tracker = newConfirmTracker()
+
+	// unbuffered so a return is handled before the confirm which follows it
+	returns := r.channel.NotifyReturn(make(chan amqp.Return))
+	confirms := r.channel.NotifyPublish(make(chan amqp.Confirmation))
+
+	go r.tracker.listen(returns, confirms, onReturn)
+}
+
+// Publish publishes a message. If confirm is set the channel is put in
+// confirm mode and the broker's confirm, or the return of a mandatory
+// message, is sent on the channel returned.
+func (r *rabbitMQChannel) Publish(exchange, key string, mandatory, confirm bool, message amqp.Publishing) (<-chan error, error) {
 	if r.channel == nil {
-		return errors.New("Channel is nil")
+		return nil, errors.New("Channel is nil")
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if confirm && r.tracker == nil {
+		return nil, errors.New("Channel doesn't track confirms")
+	}
+
+	if confirm && !r.confirming {
+		if err := r.channel.Confirm(false); err != nil {
+			return nil, err
+		}
+		r.confirming = true
+	}
+
+	if !r.confirming {
+		return nil, r.channel.Publish(exchange, key, mandatory, false, message)
+	}
+
+	// returned messages are matched by id
+	if confirm && mandatory && len(message.MessageId) == 0 {
+		message.MessageId = uuid.New().String()
 	}
-	return r.channel.Publish(exchange, key, false, false, message)
+
+	// the publish is tracked before it's sent in case the confirm
+	// arrives before publish returns
+	tag, done := r.tracker.next(message.MessageId, confirm)
+
+	if err := r.channel.Publish(exchange, key, mandatory, false, message); err != nil {
+		r.tracker.cancel(tag)
+		return nil, err
+	}
+
+	return done, nil
 }
 
 func (r *rabbitMQChannel) DeclareExchange(exchange string) error {
@@ -84,6 +138,18 @@ func (r *rabbitMQChannel) DeclareDurableExchange(exchange string) error {
 	)
 }
 
+func (r *rabbitMQChannel) DeclareDeadLetterExchange(exchange string) error {
+	return r.channel.ExchangeDeclare(
+		exchange, // name
+		"direct", // kind
+		true,     // durable
+		false,    // autoDelete
+		false,    // internal
+		false,    // noWait
+		nil,      // args
+	)
+}
+
 func (r *rabbitMQChannel) DeclareQueue(queue string, args amqp.Table) error {
 	_, err := r.channel.QueueDeclare(
 		queue, // name
@@ -0,0 +1,179 @@
+package rabbitmq
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+var (
+	// ErrNack is returned when the broker doesn't acknowledge a publish
+	ErrNack = errors.New("publish not acknowledged by the broker")
+	// ErrConfirmTimeout is returned when a publish isn't confirmed in time
+	ErrConfirmTimeout = errors.New("timed out waiting for the publish to be confirmed")
+	// ErrConfirmClosed is returned when the channel closes before a publish is confirmed
+	ErrConfirmClosed = errors.New("channel closed before the publish was confirmed")
+)
+
+// ReturnError is returned for a mandatory message the broker couldn't route
+type ReturnError struct {
+	Code   uint16
+	Reason string
+}
+
+func (r *ReturnError) Error() string {
+	return fmt.Sprintf("message returned by the broker: %d %s", r.Code, r.Reason)
+}
+
+// wait waits for the confirm of a publish
+func wait(done <-chan error, timeout time.Duration) error {
+	if done == nil {
+		return nil
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return ErrConfirmTimeout
+	}
+}
+
+// pendingPublish is a publish waiting for its confirm
+type pendingPublish struct {
+	id   string
+	err  error
+	done chan error
+}
+
+// confirmTracker matches the confirms of a channel in confirm mode to the
+// publishes. Confirms are numbered from 1 in the order of the publishes.
+type confirmTracker struct {
+	sync.Mutex
+	tag     uint64
+	pending map[uint64]*pendingPublish
+	ids     map[string]*pendingPublish
+	closed  bool
+}
+
+func newConfirmTracker() *confirmTracker {
+	return &confirmTracker{
+		pending: make(map[uint64]*pendingPublish),
+		ids:     make(map[string]*pendingPublish),
+	}
+}
+
+// next numbers a publish, the result is sent on the channel returned if
+// the publish is waiting for its confirm
+func (c *confirmTracker) next(id string, wait bool) (uint64, <-chan error) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.tag++
+
+	if !wait {
+		return c.tag, nil
+	}
+
+	p := &pendingPublish{id: id, done: make(chan error, 1)}
+
+	if c.closed {
+		p.done <- ErrConfirmClosed
+		return c.tag, p.done
+	}
+
+	c.pending[c.tag] = p
+	if len(id) > 0 {
+		c.ids[id] = p
+	}
+
+	return c.tag, p.done
+}
+
+// cancel forgets the last publish when it failed to be sent
+func (c *confirmTracker) cancel(tag uint64) {
+	c.Lock()
+	defer c.Unlock()
+
+	if p, ok := c.pending[tag]; ok {
+		delete(c.pending, tag)
+		delete(c.ids, p.id)
+	}
+
+	if tag == c.tag {
+		c.tag--
+	}
+}
+
+func (c *confirmTracker) returned(ret amqp.Return) {
+	c.Lock()
+	defer c.Unlock()
+
+	if p, ok := c.ids[ret.MessageId]; ok && len(ret.MessageId) > 0 {
+		p.err = &ReturnError{Code: ret.ReplyCode, Reason: ret.ReplyText}
+	}
+}
+
+func (c *confirmTracker) confirm(conf amqp.Confirmation) {
+	c.Lock()
+	defer c.Unlock()
+
+	p, ok := c.pending[conf.DeliveryTag]
+	if !ok {
+		return
+	}
+
+	delete(c.pending, conf.DeliveryTag)
+	delete(c.ids, p.id)
+
+	if !conf.Ack {
+		p.done <- ErrNack
+		return
+	}
+
+	p.done <- p.err
+}
+
+// close fails the publishes still waiting for a confirm
+func (c *confirmTracker) close() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.closed = true
+
+	for tag, p := range c.pending {
+		p.done <- ErrConfirmClosed
+		delete(c.pending, tag)
+	}
+	c.ids = make(map[string]*pendingPublish)
+}
+
+// listen handles the returns and confirms until the channel is closed.
+// The broker sends the return of a message before its confirm so both are
+// handled here in order.
+func (c *confirmTracker) listen(returns <-chan amqp.Return, confirms <-chan amqp.Confirmation, onReturn func(amqp.Return)) {
+	defer c.close()
+
+	for returns != nil || confirms != nil {
+		select {
+		case ret, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			c.returned(ret)
+			if onReturn != nil {
+				go onReturn(ret)
+			}
+		case conf, ok := <-confirms:
+			if !ok {
+				confirms = nil
+				continue
+			}
+			c.confirm(conf)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package rabbitmq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+func TestConfirmTracker(t *testing.T) {
+	returns := make(chan amqp.Return)
+	confirms := make(chan amqp.Confirmation)
+
+	c := newConfirmTracker()
+	go c.listen(returns, confirms, nil)
+
+	tag1, done1 := c.next("", true)
+	tag2, done2 := c.next("", false)
+	tag3, done3 := c.next("message-3", true)
+	tag4, done4 := c.next("", true)
+
+	if tag1 != 1 || tag2 != 2 || tag3 != 3 || tag4 != 4 {
+		t.Fatalf("expected tags 1 to 4 got %d %d %d %d", tag1, tag2, tag3, tag4)
+	}
+	if done2 != nil {
+		t.Fatal("expected no confirm for a publish which doesn't wait")
+	}
+
+	confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+	confirms <- amqp.Confirmation{DeliveryTag: 2, Ack: true}
+	returns <- amqp.Return{MessageId: "message-3", ReplyCode: 312, ReplyText: "NO_ROUTE"}
+	confirms <- amqp.Confirmation{DeliveryTag: 3, Ack: true}
+	confirms <- amqp.Confirmation{DeliveryTag: 4, Ack: false}
+
+	if err := wait(done1, time.Second); err != nil {
+		t.Fatalf("expected ack got %v", err)
+	}
+	if err, ok := wait(done3, time.Second).(*ReturnError); !ok || err.Code != 312 {
+		t.Fatalf("expected the message to be returned got %v", err)
+	}
+	if err := wait(done4, time.Second); err != ErrNack {
+		t.Fatalf("expected nack got %v", err)
+	}
+
+	_, done5 := c.next("", true)
+	if err := wait(done5, time.Millisecond*10); err != ErrConfirmTimeout {
+		t.Fatalf("expected timeout got %v", err)
+	}
+
+	// pending publishes fail when the channel closes
+	close(returns)
+	close(confirms)
+
+	if err := wait(done5, time.Second); err != ErrConfirmClosed {
+		t.Fatalf("expected closed got %v", err)
+	}
+}
+
+func TestConfirmTrackerCancel(t *testing.T) {
+	c := newConfirmTracker()
+
+	c.next("", true)
+	tag, _ := c.next("message-2", true)
+	c.cancel(tag)
+
+	if tag, _ = c.next("", true); tag != 2 {
+		t.Fatalf("expected the cancelled tag to be reused got %d", tag)
+	}
+	if _, ok := c.ids["message-2"]; ok {
+		t.Fatal("expected the cancelled publish to be forgotten")
+	}
+}
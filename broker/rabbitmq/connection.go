@@ -22,6 +22,7 @@ var (
 	DefaultPrefetchCount  = 0
 	DefaultPrefetchGlobal = false
 	DefaultRequeueOnError = false
+	DefaultConfirmTimeout = 5 * time.Second
 
 	// The amqp library does not seem to set these when using amqp.DialConfig
 	// (even though it says so in the comments) so we set them manually to make
@@ -47,6 +48,8 @@ type rabbitMQConn struct {
 	url             string
 	prefetchCount   int
 	prefetchGlobal  bool
+	// onReturn is called with the messages returned by the broker
+	onReturn func(amqp.Return)
 
 	sync.Mutex
 	connected bool
@@ -213,17 +216,28 @@ func (r *rabbitMQConn) tryConnect(secure bool, config *amqp.Config) error {
 	} else {
 		r.Channel.DeclareExchange(r.exchange.Name)
 	}
-	r.ExchangeChannel, err = newRabbitChannel(r.Connection, r.prefetchCount, r.prefetchGlobal)
+	if r.ExchangeChannel, err = newRabbitChannel(r.Connection, r.prefetchCount, r.prefetchGlobal); err != nil {
+		return err
+	}
 
-	return err
+	r.ExchangeChannel.Track(r.onReturn)
+
+	return nil
 }
 
-func (r *rabbitMQConn) Consume(queue, key string, headers amqp.Table, qArgs amqp.Table, autoAck, durableQueue bool) (*rabbitMQChannel, <-chan amqp.Delivery, error) {
+func (r *rabbitMQConn) Consume(queue, key string, headers amqp.Table, qArgs amqp.Table, autoAck, durableQueue bool, topo *topology) (*rabbitMQChannel, <-chan amqp.Delivery, error) {
 	consumerChannel, err := newRabbitChannel(r.Connection, r.prefetchCount, r.prefetchGlobal)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	if topo != nil {
+		if err := topo.declare(consumerChannel, queue); err != nil {
+			return nil, nil, err
+		}
+		qArgs = topo.queueArgs(queue, qArgs)
+	}
+
 	if durableQueue {
 		err = consumerChannel.DeclareDurableQueue(queue, qArgs)
 	} else {
@@ -247,6 +261,6 @@ func (r *rabbitMQConn) Consume(queue, key string, headers amqp.Table, qArgs amqp
 	return consumerChannel, deliveries, nil
 }
 
-func (r *rabbitMQConn) Publish(exchange, key string, msg amqp.Publishing) error {
-	return r.ExchangeChannel.Publish(exchange, key, msg)
+func (r *rabbitMQConn) Publish(exchange, key string, mandatory, confirm bool, msg amqp.Publishing) (<-chan error, error) {
+	return r.ExchangeChannel.Publish(exchange, key, mandatory, confirm, msg)
 }
@@ -0,0 +1,117 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// headerValue converts an amqp header to a string. Numbers and booleans are
+// formatted, timestamps use RFC3339 and tables and arrays e.g x-death are
+// encoded as json.
+func headerValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case []byte:
+		return string(t)
+	case bool:
+		return strconv.FormatBool(t)
+	case int8:
+		return strconv.FormatInt(int64(t), 10)
+	case int16:
+		return strconv.FormatInt(int64(t), 10)
+	case int32:
+		return strconv.FormatInt(int64(t), 10)
+	case int64:
+		return strconv.FormatInt(t, 10)
+	case int:
+		return strconv.Itoa(t)
+	case uint8:
+		return strconv.FormatUint(uint64(t), 10)
+	case uint16:
+		return strconv.FormatUint(uint64(t), 10)
+	case uint32:
+		return strconv.FormatUint(uint64(t), 10)
+	case uint64:
+		return strconv.FormatUint(t, 10)
+	case float32:
+		return strconv.FormatFloat(float64(t), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(t, 'g', -1, 64)
+	case time.Time:
+		return t.UTC().Format(time.RFC3339)
+	case amqp.Decimal:
+		return decimalValue(t)
+	case amqp.Table, []interface{}:
+		b, err := json.Marshal(jsonValue(t))
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// jsonValue prepares nested headers for json, byte arrays are strings
+// rather than base64 and decimals are formatted
+func jsonValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case amqp.Table:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			m[k] = jsonValue(v)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(t))
+		for i, v := range t {
+			a[i] = jsonValue(v)
+		}
+		return a
+	case []byte:
+		return string(t)
+	case amqp.Decimal:
+		return decimalValue(t)
+	case time.Time:
+		return t.UTC().Format(time.RFC3339)
+	default:
+		return v
+	}
+}
+
+func decimalValue(d amqp.Decimal) string {
+	value := int64(d.Value)
+	sign := ""
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	s := strconv.FormatInt(value, 10)
+	scale := int(d.Scale)
+	if scale == 0 {
+		return sign + s
+	}
+
+	if len(s) <= scale {
+		s = strings.Repeat("0", scale-len(s)+1) + s
+	}
+
+	return sign + s[:len(s)-scale] + "." + s[len(s)-scale:]
+}
+
+func messageHeader(table amqp.Table) map[string]string {
+	header := make(map[string]string, len(table))
+	for k, v := range table {
+		header[k] = headerValue(v)
+	}
+	return header
+}
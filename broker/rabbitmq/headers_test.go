@@ -0,0 +1,46 @@
+package rabbitmq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+func TestMessageHeader(t *testing.T) {
+	header := messageHeader(amqp.Table{
+		"string":    "value",
+		"bytes":     []byte("bytes"),
+		"bool":      true,
+		"int16":     int16(-16),
+		"int32":     int32(32),
+		"int64":     int64(64),
+		"float64":   1.5,
+		"decimal":   amqp.Decimal{Scale: 2, Value: -5},
+		"timestamp": time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		"nil":       nil,
+		"x-death": []interface{}{
+			amqp.Table{"count": int64(2), "queue": "queue", "reason": "rejected"},
+		},
+	})
+
+	expected := map[string]string{
+		"string":    "value",
+		"bytes":     "bytes",
+		"bool":      "true",
+		"int16":     "-16",
+		"int32":     "32",
+		"int64":     "64",
+		"float64":   "1.5",
+		"decimal":   "-0.05",
+		"timestamp": "2020-01-02T03:04:05Z",
+		"nil":       "",
+		"x-death":   `[{"count":2,"queue":"queue","reason":"rejected"}]`,
+	}
+
+	for k, v := range expected {
+		if header[k] != v {
+			t.Errorf("%s: expected %q got %q", k, v, header[k])
+		}
+	}
+}
@@ -2,6 +2,7 @@ package rabbitmq
 
 import (
 	"context"
+	"time"
 
 	"github.com/micro/go-micro/v2/broker"
 )
@@ -17,6 +18,13 @@ type deliveryMode struct{}
 type priorityKey struct{}
 type externalAuth struct{}
 type durableExchange struct{}
+type mandatoryKey struct{}
+type confirmKey struct{}
+type confirmBatchKey struct{}
+type confirmTimeoutKey struct{}
+type returnHandlerKey struct{}
+type deadLetterExchangeKey struct{}
+type retryKey struct{}
 
 // DurableQueue creates a durable queue when subscribing.
 func DurableQueue() broker.SubscribeOption {
@@ -85,3 +93,53 @@ type ackSuccessKey struct{}
 func AckOnSuccess() broker.SubscribeOption {
 	return setSubscribeOption(ackSuccessKey{}, true)
 }
+
+// Mandatory sets the mandatory flag when publishing, the broker returns
+// messages which can't be routed to a queue. Returned messages are passed to
+// the ReturnHandler and fail the publish when it's confirmed.
+func Mandatory() broker.PublishOption {
+	return setPublishOption(mandatoryKey{}, true)
+}
+
+// Confirm waits for the broker to confirm the publish. The publish fails
+// with ErrNack if the broker doesn't acknowledge it, ErrConfirmTimeout if
+// it isn't confirmed within the ConfirmTimeout or a *ReturnError if the
+// message is mandatory and was returned.
+func Confirm() broker.PublishOption {
+	return setPublishOption(confirmKey{}, true)
+}
+
+// ConfirmBatch confirms publishes in batches of size messages. The publish
+// which fills a batch waits for the confirms of the whole batch and fails
+// if any of them failed, the others return once they're sent. A batch which
+// isn't full is confirmed on Disconnect.
+func ConfirmBatch(size int) broker.PublishOption {
+	return setPublishOption(confirmBatchKey{}, size)
+}
+
+// ConfirmTimeout sets how long to wait for the broker to confirm a publish
+func ConfirmTimeout(d time.Duration) broker.Option {
+	return setBrokerOption(confirmTimeoutKey{}, d)
+}
+
+// ReturnHandler is called with the mandatory messages returned by the broker
+func ReturnHandler(fn func(*Returned)) broker.Option {
+	return setBrokerOption(returnHandlerKey{}, fn)
+}
+
+// DeadLetterExchange declares a durable direct exchange and a <queue>.dlq
+// queue bound to it by the queue name. Messages the handler fails to process
+// are sent to the dead letter queue once out of retries. The queue must be
+// named and is declared with the x-dead-letter arguments, an existing queue
+// declared without them has to be deleted first.
+func DeadLetterExchange(exchange string) broker.SubscribeOption {
+	return setSubscribeOption(deadLetterExchangeKey{}, exchange)
+}
+
+// Retry retries messages the handler fails to process up to retries times.
+// Messages wait for the delay in a <queue>.retry queue with a message TTL
+// before they're redelivered. Messages out of retries are dropped unless
+// there's a DeadLetterExchange.
+func Retry(delay time.Duration, retries int) broker.SubscribeOption {
+	return setSubscribeOption(retryKey{}, retry{delay: delay, retries: retries})
+}
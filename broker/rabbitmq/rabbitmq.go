@@ -4,6 +4,7 @@ package rabbitmq
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
@@ -20,6 +21,10 @@ type rbroker struct {
 	prefetchGlobal bool
 	mtx            sync.Mutex
 	wg             sync.WaitGroup
+
+	// publishes waiting for their confirms in a batch
+	batchMtx sync.Mutex
+	batch    []<-chan error
 }
 
 type subscriber struct {
@@ -33,6 +38,15 @@ type subscriber struct {
 	r            *rbroker
 	fn           func(msg amqp.Delivery)
 	headers      map[string]interface{}
+	topology     *topology
+}
+
+// Returned is a mandatory message the broker couldn't route
+type Returned struct {
+	Topic   string
+	Message *broker.Message
+	Code    uint16
+	Reason  string
 }
 
 type publication struct {
@@ -118,6 +132,7 @@ func (s *subscriber) resubscribe() {
 			s.queueArgs,
 			s.opts.AutoAck,
 			s.durableQueue,
+			s.topology,
 		)
 
 		s.r.mtx.Unlock()
@@ -154,6 +169,9 @@ func (r *rbroker) Publish(topic string, msg *broker.Message, opts ...broker.Publ
 		o(&options)
 	}
 
+	var mandatory, confirm bool
+	var batch int
+
 	if options.Context != nil {
 		if value, ok := options.Context.Value(deliveryMode{}).(uint8); ok {
 			m.DeliveryMode = value
@@ -162,6 +180,10 @@ func (r *rbroker) Publish(topic string, msg *broker.Message, opts ...broker.Publ
 		if value, ok := options.Context.Value(priorityKey{}).(uint8); ok {
 			m.Priority = value
 		}
+
+		mandatory, _ = options.Context.Value(mandatoryKey{}).(bool)
+		confirm, _ = options.Context.Value(confirmKey{}).(bool)
+		batch, _ = options.Context.Value(confirmBatchKey{}).(int)
 	}
 
 	for k, v := range msg.Header {
@@ -172,7 +194,93 @@ func (r *rbroker) Publish(topic string, msg *broker.Message, opts ...broker.Publ
 		return errors.New("connection is nil")
 	}
 
-	return r.conn.Publish(r.conn.exchange.Name, topic, m)
+	done, err := r.conn.Publish(r.conn.exchange.Name, topic, mandatory, confirm || batch > 0, m)
+	if err != nil {
+		return err
+	}
+
+	if batch > 0 {
+		return r.confirmBatch(done, batch)
+	}
+
+	return wait(done, r.getConfirmTimeout())
+}
+
+// confirmBatch adds a publish to the batch and waits for the confirms of
+// the batch once it's full
+func (r *rbroker) confirmBatch(done <-chan error, size int) error {
+	r.batchMtx.Lock()
+	r.batch = append(r.batch, done)
+	if len(r.batch) < size {
+		r.batchMtx.Unlock()
+		return nil
+	}
+	batch := r.batch
+	r.batch = nil
+	r.batchMtx.Unlock()
+
+	return r.waitBatch(batch)
+}
+
+func (r *rbroker) waitBatch(batch []<-chan error) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	deadline := time.NewTimer(r.getConfirmTimeout())
+	defer deadline.Stop()
+
+	var expired bool
+	var failed int
+	var first error
+
+	for _, done := range batch {
+		var err error
+		if expired {
+			select {
+			case err = <-done:
+			default:
+				err = ErrConfirmTimeout
+			}
+		} else {
+			select {
+			case err = <-done:
+			case <-deadline.C:
+				expired = true
+				err = ErrConfirmTimeout
+			}
+		}
+
+		if err != nil {
+			failed++
+			if first == nil {
+				first = err
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d publishes in the batch failed: %w", failed, len(batch), first)
+	}
+
+	return nil
+}
+
+func (r *rbroker) onReturn(ret amqp.Return) {
+	fn, ok := r.opts.Context.Value(returnHandlerKey{}).(func(*Returned))
+	if !ok || fn == nil {
+		return
+	}
+
+	fn(&Returned{
+		Topic: ret.RoutingKey,
+		Message: &broker.Message{
+			Header: messageHeader(ret.Headers),
+			Body:   ret.Body,
+		},
+		Code:   ret.ReplyCode,
+		Reason: ret.ReplyText,
+	})
 }
 
 func (r *rbroker) Subscribe(topic string, handler broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
@@ -221,13 +329,30 @@ func (r *rbroker) Subscribe(topic string, handler broker.Handler, opts ...broker
 		ackSuccess = true
 	}
 
-	fn := func(msg amqp.Delivery) {
-		header := make(map[string]string)
-		for k, v := range msg.Headers {
-			header[k], _ = v.(string)
+	var topo *topology
+	if dlx, ok := ctx.Value(deadLetterExchangeKey{}).(string); ok && len(dlx) > 0 {
+		topo = &topology{deadLetterExchange: dlx, confirmTimeout: r.getConfirmTimeout()}
+	}
+	if rt, ok := ctx.Value(retryKey{}).(retry); ok && rt.retries > 0 {
+		if topo == nil {
+			topo = &topology{}
+		}
+		topo.retryDelay = rt.delay
+		topo.retries = rt.retries
+	}
+
+	if topo != nil {
+		if len(opt.Queue) == 0 {
+			return nil, errors.New("dead letter and retry queues require a queue")
 		}
+		// messages are rejected when the handler fails
+		opt.AutoAck = false
+		ackSuccess = true
+	}
+
+	fn := func(msg amqp.Delivery) {
 		m := &broker.Message{
-			Header: header,
+			Header: messageHeader(msg.Headers),
 			Body:   msg.Body,
 		}
 		p := &publication{d: msg, m: m, t: msg.RoutingKey}
@@ -235,12 +360,16 @@ func (r *rbroker) Subscribe(topic string, handler broker.Handler, opts ...broker
 		if p.err == nil && ackSuccess && !opt.AutoAck {
 			msg.Ack(false)
 		} else if p.err != nil && !opt.AutoAck {
-			msg.Nack(false, requeueOnError)
+			if topo != nil {
+				topo.reject(r.conn, opt.Queue, msg)
+			} else {
+				msg.Nack(false, requeueOnError)
+			}
 		}
 	}
 
 	sret := &subscriber{topic: topic, opts: opt, mayRun: true, r: r,
-		durableQueue: durableQueue, fn: fn, headers: headers, queueArgs: qArgs}
+		durableQueue: durableQueue, fn: fn, headers: headers, queueArgs: qArgs, topology: topo}
 
 	go sret.resubscribe()
 
@@ -273,6 +402,7 @@ func (r *rbroker) Init(opts ...broker.Option) error {
 func (r *rbroker) Connect() error {
 	if r.conn == nil {
 		r.conn = newRabbitMQConn(r.getExchange(), r.opts.Addrs, r.getPrefetchCount(), r.getPrefetchGlobal())
+		r.conn.onReturn = r.onReturn
 	}
 
 	conf := defaultAmqpConfig
@@ -290,8 +420,19 @@ func (r *rbroker) Disconnect() error {
 	if r.conn == nil {
 		return errors.New("connection is nil")
 	}
+
+	// confirm the batch which isn't full
+	r.batchMtx.Lock()
+	batch := r.batch
+	r.batch = nil
+	r.batchMtx.Unlock()
+	berr := r.waitBatch(batch)
+
 	ret := r.conn.Close()
 	r.wg.Wait() // wait all goroutines
+	if ret == nil {
+		ret = berr
+	}
 	return ret
 }
 
@@ -332,6 +473,13 @@ func (r *rbroker) getPrefetchCount() int {
 	return DefaultPrefetchCount
 }
 
+func (r *rbroker) getConfirmTimeout() time.Duration {
+	if d, ok := r.opts.Context.Value(confirmTimeoutKey{}).(time.Duration); ok && d > 0 {
+		return d
+	}
+	return DefaultConfirmTimeout
+}
+
 func (r *rbroker) getPrefetchGlobal() bool {
 	if e, ok := r.opts.Context.Value(prefetchGlobalKey{}).(bool); ok {
 		return e
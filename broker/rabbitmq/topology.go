@@ -0,0 +1,145 @@
+package rabbitmq
+
+import (
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// topology is the dead letter and retry topology of a queue. Messages the
+// handler fails to process are dead lettered to a <queue>.retry queue which
+// sends them back to the queue once their ttl expires. When they run out
+// of retries, or without retries, they're sent to the <queue>.dlq queue
+// bound to the dead letter exchange by the queue name.
+type topology struct {
+	deadLetterExchange string
+	retryDelay         time.Duration
+	retries            int
+	// confirmTimeout is how long to wait for a dead letter to be confirmed
+	confirmTimeout time.Duration
+}
+
+type retry struct {
+	delay   time.Duration
+	retries int
+}
+
+func deadLetterQueue(queue string) string {
+	return queue + ".dlq"
+}
+
+func retryQueue(queue string) string {
+	return queue + ".retry"
+}
+
+// queueArgs are the arguments of the queue which route rejected messages
+// to the retry queue, or the dead letter exchange
+func (t *topology) queueArgs(queue string, args amqp.Table) amqp.Table {
+	qArgs := amqp.Table{}
+	for k, v := range args {
+		qArgs[k] = v
+	}
+
+	switch {
+	case t.retries > 0:
+		// the default exchange routes to the queue named by the key
+		qArgs["x-dead-letter-exchange"] = ""
+		qArgs["x-dead-letter-routing-key"] = retryQueue(queue)
+	case len(t.deadLetterExchange) > 0:
+		qArgs["x-dead-letter-exchange"] = t.deadLetterExchange
+		qArgs["x-dead-letter-routing-key"] = queue
+	}
+
+	return qArgs
+}
+
+// declare declares the dead letter exchange and queue and the retry queue
+func (t *topology) declare(ch *rabbitMQChannel, queue string) error {
+	if len(t.deadLetterExchange) > 0 {
+		if err := ch.DeclareDeadLetterExchange(t.deadLetterExchange); err != nil {
+			return err
+		}
+		if err := ch.DeclareDurableQueue(deadLetterQueue(queue), nil); err != nil {
+			return err
+		}
+		if err := ch.BindQueue(deadLetterQueue(queue), queue, t.deadLetterExchange, nil); err != nil {
+			return err
+		}
+	}
+
+	if t.retries > 0 {
+		if err := ch.DeclareDurableQueue(retryQueue(queue), amqp.Table{
+			"x-message-ttl":             int64(t.retryDelay / time.Millisecond),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queue,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reject dead letters a message the handler failed to process. Once it's
+// out of retries it's published to the dead letter exchange and acked,
+// or dropped without a dead letter exchange.
+func (t *topology) reject(conn *rabbitMQConn, queue string, msg amqp.Delivery) error {
+	if t.retries == 0 || deaths(msg.Headers, queue) < int64(t.retries) {
+		return msg.Nack(false, false)
+	}
+
+	if len(t.deadLetterExchange) > 0 {
+		done, err := conn.Publish(t.deadLetterExchange, queue, false, true, deadLetter(msg))
+		if err == nil {
+			err = wait(done, t.confirmTimeout)
+		}
+		// retry again rather than lose the message
+		if err != nil {
+			return msg.Nack(false, false)
+		}
+	}
+
+	return msg.Ack(false)
+}
+
+// deaths is the number of times a message was rejected from the queue
+func deaths(headers amqp.Table, queue string) int64 {
+	xDeath, _ := headers["x-death"].([]interface{})
+
+	for _, d := range xDeath {
+		death, ok := d.(amqp.Table)
+		if !ok {
+			continue
+		}
+		if q, _ := death["queue"].(string); q != queue {
+			continue
+		}
+		if r, _ := death["reason"].(string); r != "rejected" {
+			continue
+		}
+		if count, ok := death["count"].(int64); ok {
+			return count
+		}
+	}
+
+	return 0
+}
+
+// deadLetter copies a delivery to publish it to the dead letter exchange
+func deadLetter(msg amqp.Delivery) amqp.Publishing {
+	return amqp.Publishing{
+		Headers:         msg.Headers,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		DeliveryMode:    amqp.Persistent,
+		Priority:        msg.Priority,
+		CorrelationId:   msg.CorrelationId,
+		ReplyTo:         msg.ReplyTo,
+		MessageId:       msg.MessageId,
+		Timestamp:       msg.Timestamp,
+		Type:            msg.Type,
+		UserId:          msg.UserId,
+		AppId:           msg.AppId,
+		Body:            msg.Body,
+	}
+}
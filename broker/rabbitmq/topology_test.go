@@ -0,0 +1,58 @@
+package rabbitmq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+func TestTopologyQueueArgs(t *testing.T) {
+	testcases := []struct {
+		title    string
+		topology topology
+		exchange string
+		key      string
+	}{
+		{"Dead letter exchange", topology{deadLetterExchange: "dlx"}, "dlx", "queue"},
+		{"Retry", topology{retryDelay: time.Second, retries: 3}, "", "queue.retry"},
+		{"Retry and dead letter exchange", topology{deadLetterExchange: "dlx", retryDelay: time.Second, retries: 3}, "", "queue.retry"},
+	}
+
+	for _, test := range testcases {
+		args := amqp.Table{"x-max-length": int32(10)}
+		qArgs := test.topology.queueArgs("queue", args)
+
+		if have := qArgs["x-dead-letter-exchange"]; have != test.exchange {
+			t.Errorf("%s: want exchange %q, have %q", test.title, test.exchange, have)
+		}
+		if have := qArgs["x-dead-letter-routing-key"]; have != test.key {
+			t.Errorf("%s: want key %q, have %q", test.title, test.key, have)
+		}
+		if qArgs["x-max-length"] != int32(10) {
+			t.Errorf("%s: queue arguments not kept", test.title)
+		}
+		if len(args) != 1 {
+			t.Errorf("%s: queue arguments changed", test.title)
+		}
+	}
+}
+
+func TestDeaths(t *testing.T) {
+	headers := amqp.Table{
+		"x-death": []interface{}{
+			amqp.Table{"count": int64(1), "queue": "queue.retry", "reason": "expired"},
+			amqp.Table{"count": int64(2), "queue": "queue", "reason": "rejected"},
+		},
+	}
+
+	if n := deaths(headers, "queue"); n != 2 {
+		t.Errorf("want 2 deaths, have %d", n)
+	}
+	if n := deaths(headers, "other"); n != 0 {
+		t.Errorf("want 0 deaths, have %d", n)
+	}
+	if n := deaths(amqp.Table{}, "queue"); n != 0 {
+		t.Errorf("want 0 deaths, have %d", n)
+	}
+}
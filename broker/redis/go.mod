@@ -4,5 +4,6 @@ go 1.13
 
 require (
 	github.com/gomodule/redigo v2.0.0+incompatible
+	github.com/google/uuid v1.1.1
 	github.com/micro/go-micro/v2 v2.9.1
 )
@@ -14,6 +14,13 @@ var (
 	DefaultReadTimeout    = 5 * time.Second
 	DefaultWriteTimeout   = 5 * time.Second
 
+	// DefaultClaimIdleTimeout is how long a stream entry stays pending
+	// before another consumer of the group reclaims it
+	DefaultClaimIdleTimeout = time.Minute
+	// DefaultReadBlock is how long a stream subscriber blocks waiting
+	// for new entries
+	DefaultReadBlock = time.Second
+
 	optionsKey = optionsKeyType{}
 )
 
@@ -25,6 +32,13 @@ type brokerOptions struct {
 	connectTimeout time.Duration
 	readTimeout    time.Duration
 	writeTimeout   time.Duration
+
+	// streams mode
+	streams          bool
+	maxLen           int64
+	approxMaxLen     bool
+	claimIdleTimeout time.Duration
+	readBlock        time.Duration
 }
 
 type optionsKeyType struct{}
@@ -70,3 +84,51 @@ func IdleTimeout(d time.Duration) broker.Option {
 		bo.idleTimeout = d
 	}
 }
+
+// Streams publishes to Redis streams rather than pub/sub channels. Each
+// topic is a stream and subscribers of the same queue share a consumer
+// group, entries are acked once handled and redelivered otherwise.
+// Subscribers without a queue get every entry published while they're
+// subscribed. Requires Redis 6.2 or later.
+func Streams() broker.Option {
+	return func(o *broker.Options) {
+		bo := o.Context.Value(optionsKey).(*brokerOptions)
+		bo.streams = true
+	}
+}
+
+// MaxLen trims the streams to the latest n entries when publishing
+func MaxLen(n int64) broker.Option {
+	return func(o *broker.Options) {
+		bo := o.Context.Value(optionsKey).(*brokerOptions)
+		bo.maxLen = n
+		bo.approxMaxLen = false
+	}
+}
+
+// ApproxMaxLen trims the streams to about the latest n entries when
+// publishing, which is more efficient than MaxLen
+func ApproxMaxLen(n int64) broker.Option {
+	return func(o *broker.Options) {
+		bo := o.Context.Value(optionsKey).(*brokerOptions)
+		bo.maxLen = n
+		bo.approxMaxLen = true
+	}
+}
+
+// ClaimIdleTimeout sets how long a stream entry which wasn't acked stays
+// pending before it's reclaimed and redelivered, 0 disables reclaiming
+func ClaimIdleTimeout(d time.Duration) broker.Option {
+	return func(o *broker.Options) {
+		bo := o.Context.Value(optionsKey).(*brokerOptions)
+		bo.claimIdleTimeout = d
+	}
+}
+
+// ReadBlock sets how long stream subscribers block waiting for new entries
+func ReadBlock(d time.Duration) broker.Option {
+	return func(o *broker.Options) {
+		bo := o.Context.Value(optionsKey).(*brokerOptions)
+		bo.readBlock = d
+	}
+}
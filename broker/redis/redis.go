@@ -23,6 +23,7 @@ type publication struct {
 	topic   string
 	message *broker.Message
 	err     error
+	ack     func() error
 }
 
 // Topic returns the topic this publication applies to.
@@ -35,10 +36,13 @@ func (p *publication) Message() *broker.Message {
 	return p.message
 }
 
-// Ack sends an acknowledgement to the broker. However this is not supported
-// is Redis and therefore this is a no-op.
+// Ack sends an acknowledgement to the broker. This is only supported by
+// streams, with pub/sub it's a no-op.
 func (p *publication) Ack() error {
-	return nil
+	if p.ack == nil {
+		return nil
+	}
+	return p.ack()
 }
 
 func (p *publication) Error() error {
@@ -208,6 +212,10 @@ func (b *redisBroker) Publish(topic string, msg *broker.Message, opts ...broker.
 		return err
 	}
 
+	if b.bopts.streams {
+		return b.publishStream(topic, v)
+	}
+
 	conn := b.pool.Get()
 	_, err = redis.Int(conn.Do("PUBLISH", topic, v))
 	conn.Close()
@@ -217,11 +225,17 @@ func (b *redisBroker) Publish(topic string, msg *broker.Message, opts ...broker.
 
 // Subscribe returns a subscriber for the topic and handler.
 func (b *redisBroker) Subscribe(topic string, handler broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
-	var options broker.SubscribeOptions
+	options := broker.SubscribeOptions{
+		AutoAck: true,
+	}
 	for _, o := range opts {
 		o(&options)
 	}
 
+	if b.bopts.streams {
+		return b.subscribeStream(topic, handler, options)
+	}
+
 	s := subscriber{
 		codec:  b.opts.Codec,
 		conn:   &redis.PubSubConn{Conn: b.pool.Get()},
@@ -252,6 +266,9 @@ func NewBroker(opts ...broker.Option) broker.Broker {
 		connectTimeout: DefaultConnectTimeout,
 		readTimeout:    DefaultReadTimeout,
 		writeTimeout:   DefaultWriteTimeout,
+
+		claimIdleTimeout: DefaultClaimIdleTimeout,
+		readBlock:        DefaultReadBlock,
 	}
 
 	// Initialize with empty broker options.
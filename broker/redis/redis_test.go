@@ -1,11 +1,14 @@
 package redis
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"reflect"
 	"sort"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/micro/go-micro/v2/broker"
 )
@@ -106,3 +109,91 @@ func TestBroker(t *testing.T) {
 		t.Fatalf("expected %v, got %v", exp, actual)
 	}
 }
+
+func TestStreams(t *testing.T) {
+	url := os.Getenv("REDIS_URL")
+	if url == "" {
+		t.Skip("REDIS_URL not defined")
+	}
+
+	b := NewBroker(
+		broker.Addrs(url),
+		Streams(),
+		ApproxMaxLen(100),
+		ClaimIdleTimeout(time.Millisecond*100),
+		ReadBlock(time.Millisecond*100),
+	)
+
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+
+	topic := fmt.Sprintf("test.streams.%d", time.Now().UnixNano())
+	msgs := make(chan string, 10)
+
+	// the queue subscribers share the messages
+	var mtx sync.Mutex
+	failed := false
+	for i := 0; i < 2; i++ {
+		s, err := b.Subscribe(topic, func(p broker.Event) error {
+			// fail once, the message is redelivered once claimed
+			mtx.Lock()
+			defer mtx.Unlock()
+			if !failed {
+				failed = true
+				return errors.New("failed")
+			}
+			msgs <- fmt.Sprintf("q:%s", string(p.Message().Body))
+			return nil
+		}, broker.Queue("queue"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer unsubscribe(t, s)
+	}
+
+	// a subscriber without a queue gets every message
+	s := subscribe(t, b, topic, func(p broker.Event) error {
+		msgs <- fmt.Sprintf("s:%s", string(p.Message().Body))
+		return nil
+	})
+
+	publish(t, b, topic, &broker.Message{Body: []byte("hello")})
+	publish(t, b, topic, &broker.Message{Body: []byte("world")})
+
+	var actual []string
+	for len(actual) < 4 {
+		select {
+		case msg := <-msgs:
+			actual = append(actual, msg)
+		case <-time.After(time.Second * 5):
+			t.Fatalf("expected 4 messages got %v", actual)
+		}
+	}
+
+	unsubscribe(t, s)
+	publish(t, b, topic, &broker.Message{Body: []byte("other")})
+
+	select {
+	case msg := <-msgs:
+		actual = append(actual, msg)
+	case <-time.After(time.Second * 5):
+		t.Fatalf("expected 5 messages got %v", actual)
+	}
+
+	exp := []string{
+		"q:hello",
+		"q:world",
+		"q:other",
+		"s:hello",
+		"s:world",
+	}
+
+	sort.Strings(actual)
+	sort.Strings(exp)
+
+	if !reflect.DeepEqual(actual, exp) {
+		t.Fatalf("expected %v, got %v", exp, actual)
+	}
+}
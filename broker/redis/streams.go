@@ -0,0 +1,345 @@
+package redis
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+	"github.com/micro/go-micro/v2/broker"
+	"github.com/micro/go-micro/v2/codec"
+)
+
+const (
+	// streamField is the field of the entries holding the message
+	streamField = "message"
+	// streamCount is the number of entries read or claimed at once
+	streamCount = 10
+)
+
+// streamEntry is an entry read from a stream. The data of entries deleted
+// from the stream, e.g by trimming, while they were pending is nil.
+type streamEntry struct {
+	id   string
+	data []byte
+}
+
+// publishStream adds the message to the stream of the topic
+func (b *redisBroker) publishStream(topic string, v []byte) error {
+	args := redis.Args{topic}
+	if b.bopts.maxLen > 0 {
+		args = args.Add("MAXLEN")
+		if b.bopts.approxMaxLen {
+			args = args.Add("~")
+		}
+		args = args.Add(b.bopts.maxLen)
+	}
+	args = args.Add("*", streamField, v)
+
+	conn := b.pool.Get()
+	_, err := redis.String(conn.Do("XADD", args...))
+	conn.Close()
+
+	return err
+}
+
+// subscribeStream reads the stream of the topic as a consumer of the
+// consumer group named after the queue. Subscribers without a queue have
+// their own group which is destroyed when they unsubscribe.
+func (b *redisBroker) subscribeStream(topic string, handler broker.Handler, options broker.SubscribeOptions) (broker.Subscriber, error) {
+	s := &streamSubscriber{
+		codec:     b.opts.Codec,
+		pool:      b.pool,
+		bopts:     b.bopts,
+		topic:     topic,
+		group:     options.Queue,
+		consumer:  uuid.New().String(),
+		ephemeral: len(options.Queue) == 0,
+		handle:    handler,
+		opts:      options,
+		exit:      make(chan bool),
+		done:      make(chan bool),
+	}
+
+	if s.ephemeral {
+		s.group = s.consumer
+	}
+
+	// Create the group before returning so messages published once
+	// subscribed aren't missed.
+	conn := b.pool.Get()
+	err := s.createGroup(conn)
+	conn.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	go s.recv()
+
+	return s, nil
+}
+
+// streamSubscriber handles the entries of a stream as broker publications.
+type streamSubscriber struct {
+	codec     codec.Marshaler
+	pool      *redis.Pool
+	bopts     *brokerOptions
+	topic     string
+	group     string
+	consumer  string
+	ephemeral bool
+	handle    broker.Handler
+	opts      broker.SubscribeOptions
+
+	once sync.Once
+	exit chan bool
+	done chan bool
+}
+
+// createGroup creates the consumer group, reading the entries added from
+// now on, and the stream if it doesn't exist yet.
+func (s *streamSubscriber) createGroup(conn redis.Conn) error {
+	_, err := conn.Do("XGROUP", "CREATE", s.topic, s.group, "$", "MKSTREAM")
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+// recv loops to read new entries and reclaim the entries other consumers
+// of the group failed to ack, and handle them as publications. Connection
+// errors are retried until the subscriber is unsubscribed.
+func (s *streamSubscriber) recv() {
+	defer close(s.done)
+
+	var claimed time.Time
+
+	for {
+		conn := s.pool.Get()
+		err := s.createGroup(conn)
+
+		for err == nil {
+			select {
+			case <-s.exit:
+				conn.Close()
+				return
+			default:
+			}
+
+			if s.bopts.claimIdleTimeout > 0 && time.Since(claimed) > s.bopts.claimIdleTimeout {
+				if err = s.claim(conn); err != nil {
+					break
+				}
+				claimed = time.Now()
+			}
+
+			err = s.read(conn)
+		}
+
+		conn.Close()
+
+		select {
+		case <-s.exit:
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// read blocks until new entries are added to the stream and handles them.
+func (s *streamSubscriber) read(conn redis.Conn) error {
+	block := s.bopts.readBlock
+	reply, err := redis.DoWithTimeout(conn, block+s.bopts.readTimeout,
+		"XREADGROUP", "GROUP", s.group, s.consumer, "COUNT", streamCount,
+		"BLOCK", int64(block/time.Millisecond), "STREAMS", s.topic, ">")
+	if err != nil {
+		return err
+	}
+
+	// no entries were added
+	if reply == nil {
+		return nil
+	}
+
+	streams, err := redis.Values(reply, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, stream := range streams {
+		// each stream is a pair of the key and the entries
+		kv, err := redis.Values(stream, nil)
+		if err != nil {
+			return err
+		}
+		if len(kv) != 2 {
+			continue
+		}
+
+		entries, err := parseEntries(kv[1])
+		if err != nil {
+			return err
+		}
+
+		for _, e := range entries {
+			s.handleEntry(e)
+		}
+	}
+
+	return nil
+}
+
+// claim takes over the entries which have been pending for longer than
+// the claim idle timeout, e.g because the consumer failed to handle them
+// or exited, and handles them again.
+func (s *streamSubscriber) claim(conn redis.Conn) error {
+	minIdle := int64(s.bopts.claimIdleTimeout / time.Millisecond)
+	start := "0-0"
+
+	for {
+		values, err := redis.Values(conn.Do("XAUTOCLAIM", s.topic, s.group, s.consumer,
+			minIdle, start, "COUNT", streamCount))
+		if err != nil {
+			return err
+		}
+
+		if len(values) < 2 {
+			return nil
+		}
+
+		start, err = redis.String(values[0], nil)
+		if err != nil {
+			return err
+		}
+
+		entries, err := parseEntries(values[1])
+		if err != nil {
+			return err
+		}
+
+		for _, e := range entries {
+			s.handleEntry(e)
+		}
+
+		if start == "0-0" {
+			return nil
+		}
+
+		select {
+		case <-s.exit:
+			return nil
+		default:
+		}
+	}
+}
+
+// handleEntry handles an entry as a publication. Entries which aren't
+// acked stay pending and are claimed again once idle.
+func (s *streamSubscriber) handleEntry(e streamEntry) {
+	p := publication{
+		topic: s.topic,
+		ack: func() error {
+			return s.ack(e.id)
+		},
+	}
+
+	// The entry was deleted or can never be decoded, ack it so it's
+	// not claimed over and over.
+	var m broker.Message
+	if e.data == nil || s.codec.Unmarshal(e.data, &m) != nil {
+		p.Ack()
+		return
+	}
+
+	p.message = &m
+
+	if p.err = s.handle(&p); p.err != nil {
+		return
+	}
+
+	if s.opts.AutoAck {
+		p.Ack()
+	}
+}
+
+// ack acks the entry so it's removed from the pending entries of the group
+func (s *streamSubscriber) ack(id string) error {
+	conn := s.pool.Get()
+	_, err := conn.Do("XACK", s.topic, s.group, id)
+	conn.Close()
+	return err
+}
+
+// parseEntries parses a list of stream entries, each of which is a pair
+// of the id and the field value pairs.
+func parseEntries(reply interface{}) ([]streamEntry, error) {
+	values, err := redis.Values(reply, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]streamEntry, 0, len(values))
+
+	for _, v := range values {
+		entry, err := redis.Values(v, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(entry) != 2 {
+			continue
+		}
+
+		id, err := redis.String(entry[0], nil)
+		if err != nil {
+			return nil, err
+		}
+
+		e := streamEntry{id: id}
+
+		if entry[1] != nil {
+			fields, err := redis.ByteSlices(entry[1], nil)
+			if err != nil {
+				return nil, err
+			}
+			for i := 0; i+1 < len(fields); i += 2 {
+				if string(fields[i]) == streamField {
+					e.data = fields[i+1]
+				}
+			}
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// Options returns the subscriber options.
+func (s *streamSubscriber) Options() broker.SubscribeOptions {
+	return s.opts
+}
+
+// Topic returns the topic of the subscriber.
+func (s *streamSubscriber) Topic() string {
+	return s.topic
+}
+
+// Unsubscribe stops reading the stream once the current read returns. The
+// group of a subscriber without a queue is destroyed.
+func (s *streamSubscriber) Unsubscribe() error {
+	s.once.Do(func() {
+		close(s.exit)
+	})
+	<-s.done
+
+	if !s.ephemeral {
+		return nil
+	}
+
+	conn := s.pool.Get()
+	_, err := conn.Do("XGROUP", "DESTROY", s.topic, s.group)
+	conn.Close()
+
+	return err
+}
@@ -0,0 +1,55 @@
+// Package frame reads and writes the stream messages sent over a single
+// http request by the http client and server plugins
+package frame
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	// Message carries an encoded message
+	Message byte = iota
+	// Error carries a go-micro error which ends the stream
+	Error
+)
+
+var (
+	// DefaultMaxSize is the largest stream message which will be read
+	DefaultMaxSize = 4 << 20
+)
+
+// Write writes a type byte and big endian length followed by the payload
+func Write(w io.Writer, typ byte, b []byte) error {
+	frame := make([]byte, 5+len(b))
+	frame[0] = typ
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(b)))
+	copy(frame[5:], b)
+	_, err := w.Write(frame)
+	return err
+}
+
+// Read reads a frame written by Write, returning io.EOF
+// if the stream ends cleanly between frames
+func Read(r io.Reader) (byte, []byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+
+	size := binary.BigEndian.Uint32(hdr[1:])
+	if int(size) > DefaultMaxSize {
+		return 0, nil, fmt.Errorf("frame of %d bytes exceeds the max of %d", size, DefaultMaxSize)
+	}
+
+	b := make([]byte, size)
+	if _, err := io.ReadFull(r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+
+	return hdr[0], b, nil
+}
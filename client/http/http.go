@@ -2,15 +2,14 @@
 package http
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"fmt"
 	"io/ioutil"
-	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -29,6 +28,9 @@ import (
 type httpClient struct {
 	once sync.Once
 	opts client.Options
+
+	sync.RWMutex
+	client *http.Client
 }
 
 func init() {
@@ -109,7 +111,7 @@ func (h *httpClient) call(ctx context.Context, node *registry.Node, req client.R
 	hreq := &http.Request{
 		Method: "POST",
 		URL: &url.URL{
-			Scheme: "http",
+			Scheme: scheme(node),
 			Host:   address,
 			Path:   req.Endpoint(),
 		},
@@ -119,8 +121,12 @@ func (h *httpClient) call(ctx context.Context, node *registry.Node, req client.R
 		Host:          address,
 	}
 
+	h.RLock()
+	hc := h.client
+	h.RUnlock()
+
 	// make the request
-	hrsp, err := http.DefaultClient.Do(hreq.WithContext(ctx))
+	hrsp, err := hc.Do(hreq.WithContext(ctx))
 	if err != nil {
 		return errors.InternalServerError("go.micro.client", err.Error())
 	}
@@ -132,6 +138,11 @@ func (h *httpClient) call(ctx context.Context, node *registry.Node, req client.R
 		return errors.InternalServerError("go.micro.client", err.Error())
 	}
 
+	// decode the error rather than the response
+	if hrsp.StatusCode < 200 || hrsp.StatusCode > 299 {
+		return newError(hrsp, b)
+	}
+
 	// unmarshal
 	if err := cf.Unmarshal(b, rsp); err != nil {
 		return errors.InternalServerError("go.micro.client", err.Error())
@@ -150,10 +161,14 @@ func (h *httpClient) stream(ctx context.Context, node *registry.Node, req client
 		}
 	}
 
-	// set timeout in nanoseconds
-	header.Set("Timeout", fmt.Sprintf("%d", opts.RequestTimeout))
+	// streams are long lived so only time out if asked to
+	if opts.StreamTimeout > time.Duration(0) {
+		header.Set("Timeout", fmt.Sprintf("%d", opts.StreamTimeout))
+	}
 	// set the content type for the request
 	header.Set("Content-Type", req.ContentType())
+	// set the service being streamed to
+	header.Set("Micro-Service", req.Service())
 
 	// get codec
 	cf, err := h.newHTTPCodec(req.ContentType())
@@ -161,21 +176,51 @@ func (h *httpClient) stream(ctx context.Context, node *registry.Node, req client
 		return nil, errors.InternalServerError("go.micro.client", err.Error())
 	}
 
-	cc, err := net.Dial("tcp", address)
-	if err != nil {
-		return nil, errors.InternalServerError("go.micro.client", fmt.Sprintf("Error dialing: %v", err))
-	}
-
-	return &httpStream{
-		address: address,
-		context: ctx,
-		closed:  make(chan bool),
-		conn:    cc,
-		codec:   cf,
-		header:  header,
-		reader:  bufio.NewReader(cc),
-		request: req,
-	}, nil
+	hreq := &http.Request{
+		Method: "POST",
+		URL: &url.URL{
+			Scheme: scheme(node),
+			Host:   address,
+			Path:   req.Endpoint(),
+		},
+		Header: header,
+		Host:   address,
+	}
+
+	h.RLock()
+	hc := h.client
+	h.RUnlock()
+
+	return newHTTPStream(ctx, opts.StreamTimeout, hc, hreq, req, cf), nil
+}
+
+// secure returns true if the node is served over https
+func secure(node *registry.Node) bool {
+	return node.Metadata["secure"] == "true"
+}
+
+// scheme returns the url scheme for a node
+func scheme(node *registry.Node) string {
+	if secure(node) {
+		return "https"
+	}
+	return "http"
+}
+
+// newError decodes a non 2xx response into a go-micro error, keeping
+// the error returned by a go-micro service or using the status code
+func newError(rsp *http.Response, b []byte) error {
+	if err := errors.Parse(string(b)); err.Code > 0 {
+		return err
+	}
+
+	// don't return html error pages as the detail
+	detail := strings.TrimSpace(string(b))
+	if len(detail) == 0 || strings.HasPrefix(detail, "<") {
+		detail = rsp.Status
+	}
+
+	return errors.New("go.micro.client", detail, int32(rsp.StatusCode))
 }
 
 func (h *httpClient) newHTTPCodec(contentType string) (Codec, error) {
@@ -199,6 +244,12 @@ func (h *httpClient) Init(opts ...client.Option) error {
 	for _, o := range opts {
 		o(&h.opts)
 	}
+
+	// pick up any changes to the tls or pool options
+	h.Lock()
+	h.client = newHTTPClient(h.opts)
+	h.Unlock()
+
 	return nil
 }
 
@@ -327,18 +378,6 @@ func (h *httpClient) Stream(ctx context.Context, req client.Request, opts ...cli
 		return nil, err
 	}
 
-	// check if we already have a deadline
-	d, ok := ctx.Deadline()
-	if !ok {
-		// no deadline so we create a new one
-		ctx, _ = context.WithTimeout(ctx, callOpts.RequestTimeout)
-	} else {
-		// got a deadline so no need to setup context
-		// but we need to set the timeout we pass along
-		opt := client.WithRequestTimeout(d.Sub(time.Now()))
-		opt(&callOpts)
-	}
-
 	// should we noop right here?
 	select {
 	case <-ctx.Done():
@@ -502,8 +541,9 @@ func newClient(opts ...client.Option) client.Client {
 	}
 
 	rc := &httpClient{
-		once: sync.Once{},
-		opts: options,
+		once:   sync.Once{},
+		opts:   options,
+		client: newHTTPClient(options),
 	}
 
 	c := client.Client(rc)
@@ -1,19 +1,23 @@
 package http
 
 import (
-	"bufio"
-	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/micro/go-micro/v2/client"
 	"github.com/micro/go-micro/v2/client/selector"
+	"github.com/micro/go-micro/v2/errors"
 	"github.com/micro/go-micro/v2/registry"
 	"github.com/micro/go-micro/v2/registry/memory"
+	"github.com/micro/go-plugins/client/http/v2/frame"
 	"github.com/micro/go-plugins/client/http/v2/test"
 )
 
@@ -119,23 +123,6 @@ func TestHTTPClientStream(t *testing.T) {
 			return
 		}
 
-		// hijack the connection
-		hj, ok := w.(http.Hijacker)
-		if !ok {
-			http.Error(w, "could not hijack conn", 500)
-			return
-
-		}
-
-		// hijacked
-		conn, bufrw, err := hj.Hijack()
-		if err != nil {
-			http.Error(w, err.Error(), 500)
-			return
-		}
-		defer conn.Close()
-
-		// read off the first request
 		// get codec
 		ct := r.Header.Get("Content-Type")
 		codec, ok := defaultHTTPCodecs[ct]
@@ -143,85 +130,43 @@ func TestHTTPClientStream(t *testing.T) {
 			http.Error(w, "codec not found", 500)
 			return
 		}
-		b, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, err.Error(), 500)
-			return
-		}
 
-		// extract message
-		msg := new(test.Message)
-		if err := codec.Unmarshal(b, msg); err != nil {
+		// read the request while writing the response
+		if err := http.NewResponseController(w).EnableFullDuplex(); err != nil {
 			http.Error(w, err.Error(), 500)
 			return
 		}
+		w.WriteHeader(200)
+		w.(http.Flusher).Flush()
 
-		// marshal response
-		b, err = codec.Marshal(msg)
-		if err != nil {
-			http.Error(w, err.Error(), 500)
-			return
-		}
-
-		// write response
-		rsp := &http.Response{
-			Header:        r.Header,
-			Body:          &buffer{bytes.NewBuffer(b)},
-			Status:        "200 OK",
-			StatusCode:    200,
-			Proto:         "HTTP/1.1",
-			ProtoMajor:    1,
-			ProtoMinor:    1,
-			ContentLength: int64(len(b)),
-		}
-
-		// write response
-		rsp.Write(bufrw)
-		bufrw.Flush()
-
-		reader := bufio.NewReader(conn)
-
+		var count int
 		for {
-			r, err := http.ReadRequest(reader)
-			if err != nil {
-				http.Error(w, err.Error(), 500)
+			_, b, err := frame.Read(r.Body)
+			if err == io.EOF {
+				// the client closed its side, end with an error
+				b := []byte(errors.New("test.service", fmt.Sprintf("received %d", count), 499).Error())
+				frame.Write(w, frame.Error, b)
 				return
-			}
-
-			b, err = ioutil.ReadAll(r.Body)
-			if err != nil {
-				http.Error(w, err.Error(), 500)
+			} else if err != nil {
 				return
 			}
+			count++
 
 			// extract message
 			msg := new(test.Message)
 			if err := codec.Unmarshal(b, msg); err != nil {
-				http.Error(w, err.Error(), 500)
 				return
 			}
 
 			// marshal response
 			b, err = codec.Marshal(msg)
 			if err != nil {
-				http.Error(w, err.Error(), 500)
 				return
 			}
 
-			rsp := &http.Response{
-				Header:        r.Header,
-				Body:          &buffer{bytes.NewBuffer(b)},
-				Status:        "200 OK",
-				StatusCode:    200,
-				Proto:         "HTTP/1.1",
-				ProtoMajor:    1,
-				ProtoMinor:    1,
-				ContentLength: int64(len(b)),
-			}
-
 			// write response
-			rsp.Write(bufrw)
-			bufrw.Flush()
+			frame.Write(w, frame.Message, b)
+			w.(http.Flusher).Flush()
 		}
 	})
 	go http.Serve(l, mux)
@@ -267,4 +212,124 @@ func TestHTTPClientStream(t *testing.T) {
 			t.Fatalf("invalid seq %d for %d", rsp.Seq, msg.Seq)
 		}
 	}
+
+	// half close and receive the server's final error
+	if err := stream.(*httpStream).CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	err = stream.Recv(new(test.Message))
+	merr, ok := err.(*errors.Error)
+	if !ok || merr.Code != 499 || merr.Detail != "received 10" {
+		t.Fatalf("Expected the stream error got %v", err)
+	}
+	if stream.Error() != err {
+		t.Fatalf("Expected the stream error to be kept got %v", stream.Error())
+	}
+}
+
+func TestHTTPClientTLS(t *testing.T) {
+	r := memory.NewRegistry()
+	s := selector.NewSelector(selector.Registry(r))
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		// echo the request
+		w.Write(b)
+	}))
+	defer srv.Close()
+
+	if err := r.Register(&registry.Service{
+		Name: "test.service",
+		Nodes: []*registry.Node{
+			{
+				Id:      "test.service.1",
+				Address: srv.Listener.Addr().String(),
+				Metadata: map[string]string{
+					"protocol": "http",
+					"secure":   "true",
+				},
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+
+	c := NewClient(
+		client.Selector(s),
+		TLSConfig(&tls.Config{RootCAs: pool}),
+		MaxIdleConnsPerHost(1),
+	)
+
+	msg := &test.Message{Seq: 1, Data: "secure"}
+	rsp := new(test.Message)
+	if err := c.Call(context.TODO(), c.NewRequest("test.service", "/foo/bar", msg), rsp); err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Data != msg.Data {
+		t.Fatalf("Expected %s got %s", msg.Data, rsp.Data)
+	}
+}
+
+func TestHTTPClientError(t *testing.T) {
+	r := memory.NewRegistry()
+	s := selector.NewSelector(selector.Registry(r))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(502)
+		w.Write([]byte("<html><body>Bad Gateway</body></html>"))
+	})
+	mux.HandleFunc("/micro", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+		w.Write([]byte(errors.NotFound("test.service", "thing not found").Error()))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	if err := r.Register(&registry.Service{
+		Name: "test.service",
+		Nodes: []*registry.Node{
+			{
+				Id:      "test.service.1",
+				Address: srv.Listener.Addr().String(),
+				Metadata: map[string]string{
+					"protocol": "http",
+				},
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewClient(client.Selector(s))
+
+	testData := []struct {
+		path   string
+		id     string
+		code   int32
+		detail string
+	}{
+		{"/html", "go.micro.client", 502, "502 Bad Gateway"},
+		{"/micro", "test.service", 404, "thing not found"},
+		{"/missing", "go.micro.client", 404, "404 page not found"},
+	}
+
+	for _, d := range testData {
+		err := c.Call(context.TODO(), c.NewRequest("test.service", d.path, new(test.Message)), new(test.Message))
+		merr, ok := err.(*errors.Error)
+		if !ok {
+			t.Fatalf("Expected a go-micro error for %s got %v", d.path, err)
+		}
+		if merr.Id != d.id || merr.Code != d.code || merr.Detail != d.detail {
+			t.Fatalf("Unexpected error for %s: %+v", d.path, merr)
+		}
+	}
 }
@@ -0,0 +1,129 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/micro/go-micro/v2/client"
+)
+
+var (
+	// DefaultMaxIdleConns is the number of idle connections kept across all hosts
+	DefaultMaxIdleConns = 100
+	// DefaultMaxIdleConnsPerHost is the number of idle connections kept per host
+	DefaultMaxIdleConnsPerHost = 10
+	// DefaultIdleConnTimeout is how long an idle connection is kept open
+	DefaultIdleConnTimeout = time.Second * 90
+)
+
+type tlsConfigKey struct{}
+type transportKey struct{}
+type maxIdleConnsKey struct{}
+type maxIdleConnsPerHostKey struct{}
+type maxConnsPerHostKey struct{}
+type idleConnTimeoutKey struct{}
+type http2Key struct{}
+
+// TLSConfig sets the tls config used to call nodes with the metadata secure=true
+func TLSConfig(t *tls.Config) client.Option {
+	return setClientOption(tlsConfigKey{}, t)
+}
+
+// Transport sets the http transport used for calls, overriding the pool options
+func Transport(t http.RoundTripper) client.Option {
+	return setClientOption(transportKey{}, t)
+}
+
+// MaxIdleConns sets the number of idle connections kept across all hosts
+func MaxIdleConns(n int) client.Option {
+	return setClientOption(maxIdleConnsKey{}, n)
+}
+
+// MaxIdleConnsPerHost sets the number of idle connections kept per host
+func MaxIdleConnsPerHost(n int) client.Option {
+	return setClientOption(maxIdleConnsPerHostKey{}, n)
+}
+
+// MaxConnsPerHost limits the number of connections per host, zero is no limit
+func MaxConnsPerHost(n int) client.Option {
+	return setClientOption(maxConnsPerHostKey{}, n)
+}
+
+// IdleConnTimeout sets how long an idle connection is kept open
+func IdleConnTimeout(d time.Duration) client.Option {
+	return setClientOption(idleConnTimeoutKey{}, d)
+}
+
+// HTTP2 enables http/2 for secure connections
+func HTTP2(b bool) client.Option {
+	return setClientOption(http2Key{}, b)
+}
+
+// setClientOption returns a function to setup a context with given value
+func setClientOption(k, v interface{}) client.Option {
+	return func(o *client.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
+
+// tlsConfig returns the configured tls config
+func tlsConfig(opts client.Options) *tls.Config {
+	if opts.Context != nil {
+		if t, ok := opts.Context.Value(tlsConfigKey{}).(*tls.Config); ok && t != nil {
+			return t
+		}
+	}
+	return &tls.Config{}
+}
+
+// newHTTPClient returns the http client for the options
+func newHTTPClient(opts client.Options) *http.Client {
+	if opts.Context != nil {
+		if t, ok := opts.Context.Value(transportKey{}).(http.RoundTripper); ok && t != nil {
+			return &http.Client{Transport: t}
+		}
+	}
+
+	t := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   opts.CallOptions.DialTimeout,
+			KeepAlive: time.Second * 30,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig(opts),
+		MaxIdleConns:          DefaultMaxIdleConns,
+		MaxIdleConnsPerHost:   DefaultMaxIdleConnsPerHost,
+		IdleConnTimeout:       DefaultIdleConnTimeout,
+		TLSHandshakeTimeout:   time.Second * 10,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	if opts.Context == nil {
+		return &http.Client{Transport: t}
+	}
+
+	if n, ok := opts.Context.Value(maxIdleConnsKey{}).(int); ok {
+		t.MaxIdleConns = n
+	}
+	if n, ok := opts.Context.Value(maxIdleConnsPerHostKey{}).(int); ok {
+		t.MaxIdleConnsPerHost = n
+	}
+	if n, ok := opts.Context.Value(maxConnsPerHostKey{}).(int); ok {
+		t.MaxConnsPerHost = n
+	}
+	if d, ok := opts.Context.Value(idleConnTimeoutKey{}).(time.Duration); ok {
+		t.IdleConnTimeout = d
+	}
+	if b, ok := opts.Context.Value(http2Key{}).(bool); ok {
+		// a custom tls config disables http/2 unless forced
+		t.ForceAttemptHTTP2 = b
+	}
+
+	return &http.Client{Transport: t}
+}
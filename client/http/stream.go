@@ -1,38 +1,107 @@
 package http
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"errors"
+	"io"
 	"io/ioutil"
-	"net"
 	"net/http"
-	"net/url"
 	"sync"
+	"time"
 
 	"github.com/micro/go-micro/v2/client"
+	merrors "github.com/micro/go-micro/v2/errors"
+	"github.com/micro/go-plugins/client/http/v2/frame"
 )
 
-// Implements the streamer interface
+// Implements the streamer interface. Messages are sent as frames in the
+// body of a single request and received as frames in the response body.
 type httpStream struct {
 	sync.RWMutex
-	address string
 	codec   Codec
 	context context.Context
-	header  http.Header
-	seq     uint64
+	cancel  context.CancelFunc
 	closed  chan bool
 	err     error
-	conn    net.Conn
-	reader  *bufio.Reader
 	request client.Request
+
+	// serialise concurrent senders and receivers
+	send sync.Mutex
+	recv sync.Mutex
+
+	// writer is the request body
+	writer *io.PipeWriter
+	// ready is closed once the response or an error is received
+	ready    chan bool
+	response *http.Response
 }
 
 var (
 	errShutdown = errors.New("connection is shut down")
 )
 
+// newHTTPStream starts the request for a stream, which is cancelled
+// when the stream is closed or the timeout passes
+func newHTTPStream(ctx context.Context, timeout time.Duration, hc *http.Client, hreq *http.Request, req client.Request, cf Codec) *httpStream {
+	var cancel context.CancelFunc
+	if timeout > time.Duration(0) {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	pr, pw := io.Pipe()
+
+	hreq.Body = pr
+	// unknown length, sent chunked on http/1.1
+	hreq.ContentLength = -1
+
+	s := &httpStream{
+		codec:   cf,
+		context: ctx,
+		cancel:  cancel,
+		closed:  make(chan bool),
+		request: req,
+		writer:  pw,
+		ready:   make(chan bool),
+	}
+
+	go s.run(hc, hreq.WithContext(ctx), pr)
+
+	return s
+}
+
+// run makes the request and waits for the response headers
+func (h *httpStream) run(hc *http.Client, hreq *http.Request, pr *io.PipeReader) {
+	defer close(h.ready)
+
+	rsp, err := hc.Do(hreq)
+	if err != nil {
+		pr.CloseWithError(err)
+		h.setError(merrors.InternalServerError("go.micro.client", err.Error()))
+		return
+	}
+
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		b, _ := ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		err := newError(rsp, b)
+		pr.CloseWithError(err)
+		h.setError(err)
+		return
+	}
+
+	h.response = rsp
+}
+
+func (h *httpStream) setError(err error) {
+	h.Lock()
+	if h.err == nil {
+		h.err = err
+	}
+	h.Unlock()
+}
+
 func (h *httpStream) isClosed() bool {
 	select {
 	case <-h.closed:
@@ -55,11 +124,11 @@ func (h *httpStream) Response() client.Response {
 }
 
 func (h *httpStream) Send(msg interface{}) error {
-	h.Lock()
-	defer h.Unlock()
+	h.send.Lock()
+	defer h.send.Unlock()
 
 	if h.isClosed() {
-		h.err = errShutdown
+		h.setError(errShutdown)
 		return errShutdown
 	}
 
@@ -68,64 +137,78 @@ func (h *httpStream) Send(msg interface{}) error {
 		return err
 	}
 
-	buf := &buffer{bytes.NewBuffer(b)}
-	defer buf.Close()
-
-	req := &http.Request{
-		Method: "POST",
-		URL: &url.URL{
-			Scheme: "http",
-			Host:   h.address,
-			Path:   h.request.Endpoint(),
-		},
-		Header:        h.header,
-		Body:          buf,
-		ContentLength: int64(len(b)),
-		Host:          h.address,
+	if err := frame.Write(h.writer, frame.Message, b); err != nil {
+		h.setError(err)
+		return err
 	}
 
-	return req.Write(h.conn)
+	return nil
 }
 
 func (h *httpStream) Recv(msg interface{}) error {
-	h.Lock()
-	defer h.Unlock()
+	h.recv.Lock()
+	defer h.recv.Unlock()
 
 	if h.isClosed() {
-		h.err = errShutdown
+		h.setError(errShutdown)
 		return errShutdown
 	}
 
-	rsp, err := http.ReadResponse(h.reader, new(http.Request))
-	if err != nil {
-		return err
+	select {
+	case <-h.ready:
+	case <-h.context.Done():
+		return merrors.New("go.micro.client", h.context.Err().Error(), 408)
+	}
+
+	if h.response == nil {
+		return h.Error()
 	}
-	defer rsp.Body.Close()
 
-	b, err := ioutil.ReadAll(rsp.Body)
+	typ, b, err := frame.Read(h.response.Body)
 	if err != nil {
+		// io.EOF is the end of the stream
+		if err != io.EOF {
+			h.setError(err)
+		}
 		return err
 	}
 
-	if rsp.StatusCode != 200 {
-		return errors.New(rsp.Status + ": " + string(b))
+	if typ == frame.Error {
+		err := merrors.Parse(string(b))
+		h.setError(err)
+		return err
 	}
 
 	return h.codec.Unmarshal(b, msg)
 }
 
+// CloseSend closes the sending side of the stream, the server
+// receives io.EOF once it's read the messages already sent
+func (h *httpStream) CloseSend() error {
+	return h.writer.Close()
+}
+
 func (h *httpStream) Error() error {
 	h.RLock()
 	defer h.RUnlock()
 	return h.err
 }
 
+// Close ends the stream in both directions, cancelling the request
 func (h *httpStream) Close() error {
 	select {
 	case <-h.closed:
 		return nil
 	default:
 		close(h.closed)
-		return h.conn.Close()
 	}
+
+	h.writer.Close()
+	h.cancel()
+
+	<-h.ready
+	if h.response != nil {
+		return h.response.Body.Close()
+	}
+	return nil
 }
@@ -5,6 +5,8 @@ import (
 )
 
 type responseKey struct{}
+type streamKey struct{}
+type publishKey struct{}
 
 func fromContext(ctx context.Context) (map[string][]MockResponse, bool) {
 	r, ok := ctx.Value(responseKey{}).(map[string][]MockResponse)
@@ -14,3 +16,21 @@ func fromContext(ctx context.Context) (map[string][]MockResponse, bool) {
 func newContext(ctx context.Context, r map[string][]MockResponse) context.Context {
 	return context.WithValue(ctx, responseKey{}, r)
 }
+
+func streamsFromContext(ctx context.Context) (map[string][]MockStream, bool) {
+	s, ok := ctx.Value(streamKey{}).(map[string][]MockStream)
+	return s, ok
+}
+
+func newStreamsContext(ctx context.Context, s map[string][]MockStream) context.Context {
+	return context.WithValue(ctx, streamKey{}, s)
+}
+
+func publishFromContext(ctx context.Context) (map[string]error, bool) {
+	p, ok := ctx.Value(publishKey{}).(map[string]error)
+	return p, ok
+}
+
+func newPublishContext(ctx context.Context, p map[string]error) context.Context {
+	return context.WithValue(ctx, publishKey{}, p)
+}
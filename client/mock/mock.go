@@ -26,7 +26,11 @@ type MockClient struct {
 	Opts   client.Options
 
 	sync.Mutex
-	Response map[string][]MockResponse
+	Response     map[string][]MockResponse
+	Streams      map[string][]MockStream
+	Publications map[string]error
+
+	calls []*MockCall
 }
 
 func (m *MockClient) Init(opts ...client.Option) error {
@@ -43,6 +47,18 @@ func (m *MockClient) Init(opts ...client.Option) error {
 	}
 	m.Response = r
 
+	s, ok := streamsFromContext(m.Opts.Context)
+	if !ok {
+		s = make(map[string][]MockStream)
+	}
+	m.Streams = s
+
+	p, ok := publishFromContext(m.Opts.Context)
+	if !ok {
+		p = make(map[string]error)
+	}
+	m.Publications = p
+
 	return nil
 }
 
@@ -62,6 +78,13 @@ func (m *MockClient) Call(ctx context.Context, req client.Request, rsp interface
 	m.Lock()
 	defer m.Unlock()
 
+	m.record(MockCall{
+		Type:     CallType,
+		Service:  req.Service(),
+		Endpoint: req.Endpoint(),
+		Body:     req.Body(),
+	})
+
 	response, ok := m.Response[req.Service()]
 	if !ok {
 		return errors.NotFound("go.micro.client.mock", "service not found")
@@ -76,52 +99,56 @@ func (m *MockClient) Call(ctx context.Context, req client.Request, rsp interface
 			return r.Error
 		}
 
-		v := reflect.ValueOf(rsp)
+		return setResponse(ctx, req.Body(), r.Response, rsp)
+	}
 
-		if t := reflect.TypeOf(rsp); t.Kind() == reflect.Ptr {
-			v = reflect.Indirect(v)
-		}
-		response := r.Response
-		if t := reflect.TypeOf(r.Response); t.Kind() == reflect.Func {
-			var request []reflect.Value
-			switch t.NumIn() {
-			case 1:
-				// one input params: (req)
-				request = append(request, reflect.ValueOf(req.Body()))
-			case 2:
-				// two input params: (ctx, req)
-				request = append(request, reflect.ValueOf(ctx), reflect.ValueOf(req.Body()))
-			}
+	return fmt.Errorf("rpc: can't find service %s", req.Endpoint())
+}
 
-			responseValue := reflect.ValueOf(r.Response).Call(request)
-			response = responseValue[0].Interface()
-			if len(responseValue) == 2 {
-				// make it possible to return error in response function
-				respErr, ok := responseValue[1].Interface().(error)
-				if ok && respErr != nil {
-					return respErr
-				}
-			}
+func (m *MockClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	call := m.record(MockCall{
+		Type:     StreamType,
+		Service:  req.Service(),
+		Endpoint: req.Endpoint(),
+		Body:     req.Body(),
+	})
+
+	streams, ok := m.Streams[req.Service()]
+	if !ok {
+		return nil, errors.NotFound("go.micro.client.mock", "service not found")
+	}
+
+	for _, s := range streams {
+		if s.Endpoint != req.Endpoint() {
+			continue
 		}
 
-		v.Set(reflect.ValueOf(response))
+		record := func(msg interface{}) {
+			m.Lock()
+			call.Sent = append(call.Sent, msg)
+			m.Unlock()
+		}
 
-		return nil
+		return newStream(ctx, req, s, record), nil
 	}
 
-	return fmt.Errorf("rpc: can't find service %s", req.Endpoint())
+	return nil, fmt.Errorf("rpc: can't find stream %s", req.Endpoint())
 }
 
-func (m *MockClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+func (m *MockClient) Publish(ctx context.Context, p client.Message, opts ...client.PublishOption) error {
 	m.Lock()
 	defer m.Unlock()
 
-	// TODO: mock stream
-	return nil, nil
-}
+	m.record(MockCall{
+		Type:  PublishType,
+		Topic: p.Topic(),
+		Body:  p.Payload(),
+	})
 
-func (m *MockClient) Publish(ctx context.Context, p client.Message, opts ...client.PublishOption) error {
-	return nil
+	return m.Publications[p.Topic()]
 }
 
 func (m *MockClient) String() string {
@@ -142,9 +169,63 @@ func NewClient(opts ...client.Option) *MockClient {
 		r = make(map[string][]MockResponse)
 	}
 
+	s, ok := streamsFromContext(options.Context)
+	if !ok {
+		s = make(map[string][]MockStream)
+	}
+
+	p, ok := publishFromContext(options.Context)
+	if !ok {
+		p = make(map[string]error)
+	}
+
 	return &MockClient{
-		Client:   client.DefaultClient,
-		Opts:     options,
-		Response: r,
+		Client:       client.DefaultClient,
+		Opts:         options,
+		Response:     r,
+		Streams:      s,
+		Publications: p,
+	}
+}
+
+// setResponse sets rsp to the mocked response, calling it first if it's a func
+func setResponse(ctx context.Context, body, response, rsp interface{}) error {
+	v := reflect.ValueOf(rsp)
+
+	if t := reflect.TypeOf(rsp); t.Kind() == reflect.Ptr {
+		v = reflect.Indirect(v)
+	}
+	if t := reflect.TypeOf(response); t != nil && t.Kind() == reflect.Func {
+		var request []reflect.Value
+		switch t.NumIn() {
+		case 1:
+			// one input params: (req)
+			request = append(request, bodyValue(t.In(0), body))
+		case 2:
+			// two input params: (ctx, req)
+			request = append(request, reflect.ValueOf(ctx), bodyValue(t.In(1), body))
+		}
+
+		responseValue := reflect.ValueOf(response).Call(request)
+		response = responseValue[0].Interface()
+		if len(responseValue) == 2 {
+			// make it possible to return error in response function
+			respErr, ok := responseValue[1].Interface().(error)
+			if ok && respErr != nil {
+				return respErr
+			}
+		}
+	}
+
+	v.Set(reflect.ValueOf(response))
+
+	return nil
+}
+
+// bodyValue returns the value of body or the zero value of t when body is nil
+func bodyValue(t reflect.Type, body interface{}) reflect.Value {
+	if body == nil {
+		return reflect.Zero(t)
 	}
+	return reflect.ValueOf(body)
 }
@@ -3,6 +3,7 @@ package mock
 import (
 	"context"
 	"fmt"
+	"io"
 	"testing"
 
 	"github.com/micro/go-micro/v2/errors"
@@ -65,3 +66,113 @@ func TestClient(t *testing.T) {
 	}
 
 }
+
+func TestStream(t *testing.T) {
+	streams := []MockStream{
+		{
+			Endpoint: "Foo.Stream",
+			Frames: []MockFrame{
+				{Type: SendFrame, Message: "ping"},
+				{Type: RecvFrame, Message: "pong"},
+				{Type: RecvFrame, Message: func(req interface{}) string { return req.(string) + "!" }},
+			},
+		},
+		{
+			Endpoint: "Foo.Fail",
+			Frames: []MockFrame{
+				{Type: RecvFrame, Error: errors.InternalServerError("go.mock", "failed")},
+			},
+		},
+	}
+
+	c := NewClient(Stream("go.mock", streams))
+
+	req := c.NewRequest("go.mock", "Foo.Stream", nil)
+	stream, err := c.Stream(context.TODO(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := stream.Send("ping"); err != nil {
+		t.Fatalf("Unexpected send error %v", err)
+	}
+
+	for _, expected := range []string{"pong", "ping!"} {
+		var rsp string
+		if err := stream.Recv(&rsp); err != nil {
+			t.Fatalf("Unexpected recv error %v", err)
+		}
+		if rsp != expected {
+			t.Fatalf("Expected %s got %s", expected, rsp)
+		}
+	}
+
+	var rsp string
+	if err := stream.Recv(&rsp); err != io.EOF {
+		t.Fatalf("Expected io.EOF got %v", err)
+	}
+
+	stream, err = c.Stream(context.TODO(), c.NewRequest("go.mock", "Foo.Fail", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send("ping"); err == nil {
+		t.Fatal("Expected out of order send to fail")
+	}
+	if err := stream.Recv(&rsp); err == nil || stream.Error() != err {
+		t.Fatalf("Expected scripted error got %v", err)
+	}
+
+	calls := c.CallsTo("go.mock", "Foo.Stream")
+	if len(calls) != 1 || len(calls[0].Sent) != 1 || calls[0].Sent[0] != "ping" {
+		t.Fatalf("Unexpected recorded stream %+v", calls)
+	}
+
+	// a stream opened before the client is reset still records its messages
+	stream, err = c.Stream(context.TODO(), c.NewRequest("go.mock", "Foo.Stream", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Reset()
+	if err := stream.Send("ping"); err != nil {
+		t.Fatalf("Unexpected send error %v", err)
+	}
+	if calls := c.Calls(); len(calls) != 0 {
+		t.Fatalf("Expected no recorded calls after reset got %+v", calls)
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	response := []MockResponse{
+		{Endpoint: "Foo.Bar", Response: "bar"},
+	}
+
+	fail := errors.InternalServerError("go.mock", "failed")
+	c := NewClient(Response("go.mock", response), Publish("go.fail", fail))
+
+	for _, body := range []string{"a", "b", "a"} {
+		var rsp interface{}
+		if err := c.Call(context.TODO(), c.NewRequest("go.mock", "Foo.Bar", body), &rsp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.Publish(context.TODO(), c.NewMessage("go.events", "event")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Publish(context.TODO(), c.NewMessage("go.fail", "event")); err != fail {
+		t.Fatalf("Expected publish error %v got %v", fail, err)
+	}
+
+	c.AssertCalled(t, "go.mock", "Foo.Bar", 3)
+	c.AssertCalled(t, "go.mock", "Foo.Bar", 2, "a")
+	c.AssertCalled(t, "go.mock", "Foo.Bar", 1, func(body interface{}) bool { return body == "b" })
+	c.AssertNotCalled(t, "go.mock", "Foo.Baz")
+	c.AssertPublished(t, "go.events", 1, "event")
+	c.AssertPublished(t, "go.fail", 1)
+
+	c.Reset()
+	if calls := c.Calls(); len(calls) != 0 {
+		t.Fatalf("Expected no calls after reset got %d", len(calls))
+	}
+}
@@ -15,3 +15,27 @@ func Response(service string, response []MockResponse) client.Option {
 		o.Context = newContext(o.Context, r)
 	}
 }
+
+// Stream sets the scripted streams for a service
+func Stream(service string, streams []MockStream) client.Option {
+	return func(o *client.Options) {
+		s, ok := streamsFromContext(o.Context)
+		if !ok {
+			s = make(map[string][]MockStream)
+		}
+		s[service] = streams
+		o.Context = newStreamsContext(o.Context, s)
+	}
+}
+
+// Publish sets the error returned when publishing to a topic
+func Publish(topic string, err error) client.Option {
+	return func(o *client.Options) {
+		p, ok := publishFromContext(o.Context)
+		if !ok {
+			p = make(map[string]error)
+		}
+		p[topic] = err
+		o.Context = newPublishContext(o.Context, p)
+	}
+}
@@ -0,0 +1,148 @@
+package mock
+
+import (
+	"reflect"
+)
+
+// RequestType is the kind of request recorded by the mock client
+type RequestType string
+
+const (
+	CallType    RequestType = "call"
+	StreamType  RequestType = "stream"
+	PublishType RequestType = "publish"
+)
+
+// MockCall is a request recorded by the mock client
+type MockCall struct {
+	Type     RequestType
+	Service  string
+	Endpoint string
+	// Topic is set for publications
+	Topic string
+	// Body is the request body or the message payload
+	Body interface{}
+	// Sent holds the messages sent on a stream
+	Sent []interface{}
+}
+
+// TestingT is the subset of testing.TB used by the assertions
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// record must be called with the lock held
+func (m *MockClient) record(c MockCall) *MockCall {
+	m.calls = append(m.calls, &c)
+	return &c
+}
+
+// Calls returns every request recorded by the client
+func (m *MockClient) Calls() []MockCall {
+	m.Lock()
+	defer m.Unlock()
+
+	calls := make([]MockCall, 0, len(m.calls))
+	for _, c := range m.calls {
+		calls = append(calls, *c)
+	}
+	return calls
+}
+
+// CallsTo returns the calls and streams made to a service endpoint
+func (m *MockClient) CallsTo(service, endpoint string) []MockCall {
+	m.Lock()
+	defer m.Unlock()
+
+	var calls []MockCall
+	for _, c := range m.calls {
+		if c.Type == PublishType {
+			continue
+		}
+		if c.Service == service && c.Endpoint == endpoint {
+			calls = append(calls, *c)
+		}
+	}
+	return calls
+}
+
+// Published returns the messages published to a topic
+func (m *MockClient) Published(topic string) []MockCall {
+	m.Lock()
+	defer m.Unlock()
+
+	var calls []MockCall
+	for _, c := range m.calls {
+		if c.Type == PublishType && c.Topic == topic {
+			calls = append(calls, *c)
+		}
+	}
+	return calls
+}
+
+// Reset clears the recorded requests
+func (m *MockClient) Reset() {
+	m.Lock()
+	m.calls = nil
+	m.Unlock()
+}
+
+// AssertCalled checks the endpoint was called the given number of times.
+// An optional body restricts the count to requests with a matching body,
+// it may be a value compared with reflect.DeepEqual or a func(interface{}) bool.
+func (m *MockClient) AssertCalled(t TestingT, service, endpoint string, times int, body ...interface{}) bool {
+	t.Helper()
+
+	n := count(m.CallsTo(service, endpoint), body)
+	if n != times {
+		t.Errorf("mock: expected %s.%s to be called %d times, got %d", service, endpoint, times, n)
+		return false
+	}
+	return true
+}
+
+// AssertNotCalled checks the endpoint was never called
+func (m *MockClient) AssertNotCalled(t TestingT, service, endpoint string) bool {
+	t.Helper()
+
+	if n := len(m.CallsTo(service, endpoint)); n > 0 {
+		t.Errorf("mock: expected %s.%s not to be called, got %d calls", service, endpoint, n)
+		return false
+	}
+	return true
+}
+
+// AssertPublished checks a topic was published to the given number of times.
+// The optional body is matched against the message payload as in AssertCalled.
+func (m *MockClient) AssertPublished(t TestingT, topic string, times int, body ...interface{}) bool {
+	t.Helper()
+
+	n := count(m.Published(topic), body)
+	if n != times {
+		t.Errorf("mock: expected %d publications to %s, got %d", times, topic, n)
+		return false
+	}
+	return true
+}
+
+func count(calls []MockCall, body []interface{}) int {
+	if len(body) == 0 {
+		return len(calls)
+	}
+
+	var n int
+	for _, c := range calls {
+		if match(body[0], c.Body) {
+			n++
+		}
+	}
+	return n
+}
+
+func match(expected, actual interface{}) bool {
+	if fn, ok := expected.(func(interface{}) bool); ok {
+		return fn(actual)
+	}
+	return reflect.DeepEqual(expected, actual)
+}
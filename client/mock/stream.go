@@ -0,0 +1,215 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/codec"
+	"github.com/micro/go-micro/v2/errors"
+)
+
+// FrameType is the direction of a scripted stream frame
+type FrameType int
+
+const (
+	// SendFrame is consumed by a call to Send
+	SendFrame FrameType = iota
+	// RecvFrame is returned by a call to Recv
+	RecvFrame
+)
+
+func (t FrameType) String() string {
+	switch t {
+	case SendFrame:
+		return "send"
+	case RecvFrame:
+		return "recv"
+	default:
+		return "unknown"
+	}
+}
+
+// MockFrame is a single step of a scripted stream
+type MockFrame struct {
+	Type FrameType
+	// Message is the message expected by Send or returned by Recv.
+	// A nil Message on a send frame accepts anything, a func(interface{}) bool
+	// is used as a matcher. A recv frame may use the same function
+	// signatures as MockResponse.Response.
+	Message interface{}
+	// Error is returned by Send or Recv instead of processing the frame
+	Error error
+}
+
+// MockStream is a scripted stream for an endpoint
+type MockStream struct {
+	Endpoint string
+	Frames   []MockFrame
+	// Error is returned once all frames are consumed, defaults to io.EOF
+	Error error
+}
+
+type mockStream struct {
+	sync.Mutex
+	ctx        context.Context
+	request    client.Request
+	frames     []MockFrame
+	final      error
+	sent       []interface{}
+	err        error
+	closed     bool
+	sendClosed bool
+	// record is called with every message sent
+	record func(msg interface{})
+}
+
+type mockResponse struct {
+	header map[string]string
+}
+
+func (r *mockResponse) Codec() codec.Reader {
+	return nil
+}
+
+func (r *mockResponse) Header() map[string]string {
+	return r.header
+}
+
+func (r *mockResponse) Read() ([]byte, error) {
+	return nil, errors.InternalServerError("go.micro.client.mock", "read not supported")
+}
+
+func newStream(ctx context.Context, req client.Request, s MockStream, record func(interface{})) *mockStream {
+	final := s.Error
+	if final == nil {
+		final = io.EOF
+	}
+
+	frames := make([]MockFrame, len(s.Frames))
+	copy(frames, s.Frames)
+
+	return &mockStream{
+		ctx:     ctx,
+		request: req,
+		frames:  frames,
+		final:   final,
+		record:  record,
+	}
+}
+
+// next pops the next frame if it matches the expected type
+func (m *mockStream) next(t FrameType) (MockFrame, error) {
+	if m.closed {
+		return MockFrame{}, io.EOF
+	}
+
+	if len(m.frames) == 0 {
+		return MockFrame{}, m.final
+	}
+
+	f := m.frames[0]
+	if f.Type != t {
+		return MockFrame{}, fmt.Errorf("mock stream %s: expected %s frame got %s", m.request.Endpoint(), f.Type, t)
+	}
+
+	m.frames = m.frames[1:]
+	return f, nil
+}
+
+func (m *mockStream) Context() context.Context {
+	return m.ctx
+}
+
+func (m *mockStream) Request() client.Request {
+	return m.request
+}
+
+func (m *mockStream) Response() client.Response {
+	return &mockResponse{header: make(map[string]string)}
+}
+
+func (m *mockStream) Send(msg interface{}) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.sendClosed {
+		m.err = errors.BadRequest("go.micro.client.mock", "send on closed stream")
+		return m.err
+	}
+
+	m.sent = append(m.sent, msg)
+	if m.record != nil {
+		m.record(msg)
+	}
+
+	f, err := m.next(SendFrame)
+	if err != nil {
+		m.err = err
+		return err
+	}
+
+	if f.Error != nil {
+		m.err = f.Error
+		return f.Error
+	}
+
+	if f.Message != nil && !match(f.Message, msg) {
+		m.err = fmt.Errorf("mock stream %s: unexpected message %v", m.request.Endpoint(), msg)
+		return m.err
+	}
+
+	return nil
+}
+
+func (m *mockStream) Recv(msg interface{}) error {
+	m.Lock()
+	defer m.Unlock()
+
+	f, err := m.next(RecvFrame)
+	if err != nil {
+		m.err = err
+		return err
+	}
+
+	if f.Error != nil {
+		m.err = f.Error
+		return f.Error
+	}
+
+	var last interface{}
+	if len(m.sent) > 0 {
+		last = m.sent[len(m.sent)-1]
+	}
+
+	if err := setResponse(m.ctx, last, f.Message, msg); err != nil {
+		m.err = err
+		return err
+	}
+
+	return nil
+}
+
+func (m *mockStream) Error() error {
+	m.Lock()
+	defer m.Unlock()
+	return m.err
+}
+
+// CloseSend closes the send direction of the stream
+func (m *mockStream) CloseSend() error {
+	m.Lock()
+	defer m.Unlock()
+	m.sendClosed = true
+	return nil
+}
+
+func (m *mockStream) Close() error {
+	m.Lock()
+	defer m.Unlock()
+	m.closed = true
+	m.sendClosed = true
+	return nil
+}
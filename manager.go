@@ -0,0 +1,317 @@
+package plugin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// DefaultVersion is the go-micro version used when it can't be read from the build info
+	DefaultVersion = "v2.9.1"
+	// DefaultReloadInterval is how often a watched directory is scanned for new plugins
+	DefaultReloadInterval = time.Minute
+)
+
+// Manifest describes a plugin and the binary it was built for. It's stored
+// as json next to the plugin e.g rabbitmq.so and rabbitmq.json
+type Manifest struct {
+	// Name of the plugin e.g rabbitmq
+	Name string `json:"name"`
+	// Type of the plugin e.g broker
+	Type string `json:"type"`
+	// Version of go-micro the plugin was built against
+	Version string `json:"version"`
+	// Checksum is the hex encoded sha256 of the plugin file
+	Checksum string `json:"checksum"`
+}
+
+// Errors holds the failures from loading a directory of plugins
+type Errors []error
+
+func (e Errors) Error() string {
+	errs := make([]string, len(e))
+	for i, err := range e {
+		errs[i] = err.Error()
+	}
+	return strings.Join(errs, "; ")
+}
+
+// ManagerOptions configures a Manager
+type ManagerOptions struct {
+	// Dir is the directory plugins are loaded from
+	Dir string
+	// Version of go-micro plugins must be built against,
+	// defaults to the version in this binary
+	Version string
+	// Override allows plugins to replace registered plugins
+	// of the same type and name rather than conflicting
+	Override bool
+}
+
+// ManagerOption sets values in ManagerOptions
+type ManagerOption func(o *ManagerOptions)
+
+// Dir sets the directory plugins are loaded from
+func Dir(dir string) ManagerOption {
+	return func(o *ManagerOptions) {
+		o.Dir = dir
+	}
+}
+
+// Version sets the go-micro version plugins must be built against
+func Version(v string) ManagerOption {
+	return func(o *ManagerOptions) {
+		o.Version = v
+	}
+}
+
+// Override lets plugins replace built in or previously loaded plugins
+func Override(b bool) ManagerOption {
+	return func(o *ManagerOptions) {
+		o.Override = b
+	}
+}
+
+// Manager loads and registers the plugins in a directory. Go can't unload
+// plugins so reloading only picks up plugins added since the last load.
+type Manager struct {
+	opts ManagerOptions
+
+	sync.Mutex
+	// loaded plugins by path
+	plugins map[string]*loaded
+}
+
+type loaded struct {
+	plugin   *Plugin
+	checksum string
+}
+
+// NewManager returns a plugin manager
+func NewManager(opts ...ManagerOption) *Manager {
+	options := ManagerOptions{
+		Version: buildVersion(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return &Manager{
+		opts:    options,
+		plugins: make(map[string]*loaded),
+	}
+}
+
+// Load loads and registers every plugin in the directory which hasn't been
+// loaded yet. Plugins which fail validation or conflict with a registered
+// plugin are skipped and reported in the returned Errors.
+func (m *Manager) Load() ([]*Plugin, error) {
+	paths, err := filepath.Glob(filepath.Join(m.opts.Dir, "*.so"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var plugins []*Plugin
+	var errs Errors
+
+	for _, path := range paths {
+		p, err := m.LoadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if p != nil {
+			plugins = append(plugins, p)
+		}
+	}
+
+	if len(errs) > 0 {
+		return plugins, errs
+	}
+	return plugins, nil
+}
+
+// LoadFile validates, loads and registers a single plugin. It returns
+// nil if the plugin has already been loaded.
+func (m *Manager) LoadFile(path string) (*Plugin, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	checksum, err := Checksum(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	if l, ok := m.plugins[path]; ok {
+		if l.checksum != checksum {
+			return nil, fmt.Errorf("%s: plugin %s has changed, restart to load it", path, l.plugin.Name)
+		}
+		return nil, nil
+	}
+
+	man, err := ReadManifest(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := m.validate(man, checksum); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	p, err := Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if p.Name != man.Name || p.Type != man.Type {
+		return nil, fmt.Errorf("%s: plugin is %s %s but manifest is %s %s", path, p.Type, p.Name, man.Type, man.Name)
+	}
+
+	if err := m.register(p); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	m.plugins[path] = &loaded{plugin: p, checksum: checksum}
+	return p, nil
+}
+
+// validate checks a manifest against the plugin file and this binary
+func (m *Manager) validate(man *Manifest, checksum string) error {
+	if len(man.Name) == 0 || len(man.Type) == 0 {
+		return fmt.Errorf("manifest is missing a name or type")
+	}
+	if man.Checksum != checksum {
+		return fmt.Errorf("checksum mismatch for plugin %s", man.Name)
+	}
+	// go requires plugins to be built with the exact same
+	// package versions as the binary loading them
+	if len(m.opts.Version) > 0 && man.Version != m.opts.Version {
+		return fmt.Errorf("plugin %s built against go-micro %s, expected %s", man.Name, man.Version, m.opts.Version)
+	}
+	return nil
+}
+
+// register inits the plugin unless it conflicts with a registered plugin
+func (m *Manager) register(p *Plugin) error {
+	if !m.opts.Override && Registered(p) {
+		return fmt.Errorf("conflicts with registered %s plugin %s", p.Type, p.Name)
+	}
+	return Init(p)
+}
+
+// Plugins returns the plugins loaded by the manager
+func (m *Manager) Plugins() []*Plugin {
+	m.Lock()
+	defer m.Unlock()
+
+	paths := make([]string, 0, len(m.plugins))
+	for path := range m.plugins {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	plugins := make([]*Plugin, len(paths))
+	for i, path := range paths {
+		plugins[i] = m.plugins[path].plugin
+	}
+	return plugins
+}
+
+// Watch loads new plugins from the directory every interval until exit is
+// closed. Errors are passed to fn along with the newly loaded plugins.
+func (m *Manager) Watch(interval time.Duration, exit chan bool, fn func([]*Plugin, error)) {
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-exit:
+			return
+		case <-t.C:
+			plugins, err := m.Load()
+			if (len(plugins) > 0 || err != nil) && fn != nil {
+				fn(plugins, err)
+			}
+		}
+	}
+}
+
+// manifestPath returns the path of the manifest for a plugin file
+func manifestPath(path string) string {
+	return strings.TrimSuffix(path, ".so") + ".json"
+}
+
+// ReadManifest reads the manifest for a plugin file
+func ReadManifest(path string) (*Manifest, error) {
+	b, err := ioutil.ReadFile(manifestPath(path))
+	if err != nil {
+		return nil, err
+	}
+	var man *Manifest
+	if err := json.Unmarshal(b, &man); err != nil {
+		return nil, err
+	}
+	return man, nil
+}
+
+// WriteManifest writes the manifest for a plugin file built from p
+func WriteManifest(path string, p *Plugin) error {
+	checksum, err := Checksum(path)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(&Manifest{
+		Name:     p.Name,
+		Type:     p.Type,
+		Version:  buildVersion(),
+		Checksum: checksum,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(manifestPath(path), b, 0644)
+}
+
+// Checksum returns the hex encoded sha256 of a file
+func Checksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// buildVersion returns the go-micro version this binary was built with
+func buildVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path != "github.com/micro/go-micro/v2" {
+				continue
+			}
+			if dep.Replace != nil && len(dep.Replace.Version) > 0 {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return DefaultVersion
+}
@@ -0,0 +1,142 @@
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/micro/go-micro/v2/broker"
+	"github.com/micro/go-micro/v2/config/cmd"
+)
+
+// testPlugin writes a fake plugin file to dir
+func testPlugin(t *testing.T, dir, name string) string {
+	path := filepath.Join(dir, name+".so")
+	if err := ioutil.WriteFile(path, []byte(name), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := testPlugin(t, dir, "test")
+	if err := WriteManifest(path, &Plugin{Name: "test", Type: "broker"}); err != nil {
+		t.Fatal(err)
+	}
+
+	man, err := ReadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checksum, err := Checksum(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if man.Name != "test" || man.Type != "broker" || man.Version != buildVersion() || man.Checksum != checksum {
+		t.Fatalf("unexpected manifest %+v", man)
+	}
+
+	// the manifest is stored next to the plugin
+	if err := ioutil.WriteFile(filepath.Join(dir, "test.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadManifest(path); err == nil {
+		t.Fatal("expected an invalid manifest to fail")
+	}
+
+	if _, err := ReadManifest(filepath.Join(dir, "missing.so")); err == nil {
+		t.Fatal("expected a missing manifest to fail")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	m := NewManager(Version("v2.9.1"))
+
+	testData := []struct {
+		manifest Manifest
+		err      string
+	}{
+		{Manifest{Name: "test", Type: "broker", Version: "v2.9.1", Checksum: "abc"}, ""},
+		{Manifest{Type: "broker", Version: "v2.9.1", Checksum: "abc"}, "missing a name or type"},
+		{Manifest{Name: "test", Type: "broker", Version: "v2.9.1", Checksum: "def"}, "checksum mismatch"},
+		{Manifest{Name: "test", Type: "broker", Version: "v2.9.0", Checksum: "abc"}, "built against go-micro v2.9.0"},
+	}
+
+	for _, d := range testData {
+		err := m.validate(&d.manifest, "abc")
+		if len(d.err) == 0 {
+			if err != nil {
+				t.Fatalf("expected %+v to be valid got %v", d.manifest, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), d.err) {
+			t.Fatalf("expected %+v to fail with %q got %v", d.manifest, d.err, err)
+		}
+	}
+}
+
+func TestLoadChecksum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := testPlugin(t, dir, "test")
+	if err := WriteManifest(path, &Plugin{Name: "test", Type: "broker"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the plugin is changed after the manifest was written
+	if err := ioutil.WriteFile(path, []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManager(Dir(dir))
+	plugins, err := m.Load()
+	if len(plugins) != 0 {
+		t.Fatalf("expected no plugins to be loaded got %d", len(plugins))
+	}
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 1 || !strings.Contains(errs[0].Error(), "checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch got %v", err)
+	}
+}
+
+func TestRegisterConflict(t *testing.T) {
+	newBroker := func(...broker.Option) broker.Broker { return nil }
+
+	// http is a built in broker
+	p := &Plugin{Name: "http", Type: "broker", NewFunc: newBroker}
+	if !Registered(p) {
+		t.Fatal("expected the http broker to be registered")
+	}
+
+	m := NewManager()
+	if err := m.register(p); err == nil || !strings.Contains(err.Error(), "conflicts") {
+		t.Fatalf("expected a conflict got %v", err)
+	}
+
+	defer func(b func(...broker.Option) broker.Broker) {
+		cmd.DefaultBrokers["http"] = b
+	}(cmd.DefaultBrokers["http"])
+
+	m = NewManager(Override(true))
+	if err := m.register(p); err != nil {
+		t.Fatal(err)
+	}
+	if b := cmd.DefaultBrokers["http"](); b != nil {
+		t.Fatal("expected the http broker to be overridden")
+	}
+}
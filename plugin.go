@@ -4,6 +4,7 @@ package plugin
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -14,9 +15,11 @@ import (
 	"github.com/micro/go-micro/v2/broker"
 	"github.com/micro/go-micro/v2/client"
 	"github.com/micro/go-micro/v2/client/selector"
+	"github.com/micro/go-micro/v2/codec"
 	"github.com/micro/go-micro/v2/config/cmd"
 	"github.com/micro/go-micro/v2/registry"
 	"github.com/micro/go-micro/v2/server"
+	"github.com/micro/go-micro/v2/store"
 	"github.com/micro/go-micro/v2/transport"
 	mp "github.com/micro/micro/v2/plugin"
 )
@@ -33,7 +36,9 @@ type Plugin struct {
 	NewFunc interface{}
 }
 
-// Init sets up the plugin
+// Init sets up the plugin. Config source and logger plugins are rejected as
+// go-micro has no registry for them, the config flag only accepts the
+// service source and the logger is a single default rather than by name.
 func Init(p *Plugin) error {
 	switch p.Type {
 	case "micro":
@@ -41,7 +46,9 @@ func Init(p *Plugin) error {
 		if !ok {
 			return fmt.Errorf("Invalid plugin %s", p.Name)
 		}
-		mp.Register(pg())
+		if err := mp.Register(pg()); err != nil {
+			return err
+		}
 	case "broker":
 		pg, ok := p.NewFunc.(func(...broker.Option) broker.Broker)
 		if !ok {
@@ -79,9 +86,67 @@ func Init(p *Plugin) error {
 			return fmt.Errorf("Invalid plugin %s", p.Name)
 		}
 		cmd.DefaultTransports[p.Name] = pg
+	case "store":
+		pg, ok := p.NewFunc.(func(...store.Option) store.Store)
+		if !ok {
+			return fmt.Errorf("Invalid plugin %s", p.Name)
+		}
+		cmd.DefaultStores[p.Name] = pg
+	case "codec":
+		// codecs are named by the content type they handle
+		var pg codec.NewCodec
+		switch fn := p.NewFunc.(type) {
+		case codec.NewCodec:
+			pg = fn
+		case func(io.ReadWriteCloser) codec.Codec:
+			pg = fn
+		default:
+			return fmt.Errorf("Invalid plugin %s", p.Name)
+		}
+		client.DefaultCodecs[p.Name] = pg
+		server.DefaultCodecs[p.Name] = pg
+	case "source", "logger":
+		return fmt.Errorf("Unsupported plugin type: %s for %s, go-micro can't select %s plugins by name", p.Type, p.Name, p.Type)
+	default:
+		return fmt.Errorf("Unknown plugin type: %s for %s", p.Type, p.Name)
 	}
 
-	return fmt.Errorf("Unknown plugin type: %s for %s", p.Type, p.Name)
+	return nil
+}
+
+// Registered returns true if a plugin of the same type and name is already
+// registered, either built in or loaded
+func Registered(p *Plugin) bool {
+	var ok bool
+	switch p.Type {
+	case "micro":
+		for _, pg := range mp.Plugins() {
+			if pg.String() == p.Name {
+				ok = true
+				break
+			}
+		}
+	case "broker":
+		_, ok = cmd.DefaultBrokers[p.Name]
+	case "client":
+		_, ok = cmd.DefaultClients[p.Name]
+	case "registry":
+		_, ok = cmd.DefaultRegistries[p.Name]
+	case "selector":
+		_, ok = cmd.DefaultSelectors[p.Name]
+	case "server":
+		_, ok = cmd.DefaultServers[p.Name]
+	case "transport":
+		_, ok = cmd.DefaultTransports[p.Name]
+	case "store":
+		_, ok = cmd.DefaultStores[p.Name]
+	case "codec":
+		_, ok = client.DefaultCodecs[p.Name]
+		if !ok {
+			_, ok = server.DefaultCodecs[p.Name]
+		}
+	}
+	return ok
 }
 
 // Load loads a plugin created with `go build -buildmode=plugin`
@@ -136,5 +201,10 @@ func Build(path string, p *Plugin) error {
 		return fmt.Errorf("Failed to create dir %s: %v", filepath.Dir(path), err)
 	}
 	c := exec.Command("go", "build", "-buildmode=plugin", "-o", path+".so", goFile)
-	return c.Run()
+	if err := c.Run(); err != nil {
+		return err
+	}
+
+	// write the manifest used by the manager to validate the plugin
+	return WriteManifest(path+".so", p)
 }
@@ -0,0 +1,50 @@
+package plugin
+
+import (
+	"strings"
+	"testing"
+
+	mp "github.com/micro/micro/v2/plugin"
+)
+
+func TestInitMicro(t *testing.T) {
+	p := &Plugin{
+		Name: "test",
+		Type: "micro",
+		NewFunc: func() mp.Plugin {
+			return mp.NewPlugin(mp.WithName("test"))
+		},
+	}
+
+	if Registered(p) {
+		t.Fatal("expected the plugin not to be registered")
+	}
+	if err := Init(p); err != nil {
+		t.Fatal(err)
+	}
+	if !Registered(p) {
+		t.Fatal("expected the plugin to be registered")
+	}
+	// micro rejects plugins of the same name
+	if err := Init(p); err == nil {
+		t.Fatal("expected registering the plugin twice to fail")
+	}
+}
+
+func TestInitInvalid(t *testing.T) {
+	for _, typ := range []string{"broker", "codec", "micro"} {
+		p := &Plugin{Name: "test", Type: typ, NewFunc: func() {}}
+		if err := Init(p); err == nil {
+			t.Fatalf("expected an invalid %s plugin to fail", typ)
+		}
+	}
+}
+
+func TestInitUnsupported(t *testing.T) {
+	for _, typ := range []string{"source", "logger"} {
+		p := &Plugin{Name: "test", Type: typ, NewFunc: func() {}}
+		if err := Init(p); err == nil || !strings.Contains(err.Error(), "Unsupported plugin type") {
+			t.Fatalf("expected a %s plugin to be unsupported got %v", typ, err)
+		}
+	}
+}
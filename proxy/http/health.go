@@ -0,0 +1,99 @@
+package http
+
+import (
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v2/registry"
+)
+
+var (
+	// DefaultMaxFailures is the number of consecutive failed requests before a node is ejected
+	DefaultMaxFailures = 3
+	// DefaultEjectDuration is how long an ejected node is skipped for
+	DefaultEjectDuration = time.Second * 30
+)
+
+// health tracks failing backend nodes so they can be skipped
+type health struct {
+	sync.Mutex
+	nodes map[string]*nodeHealth
+}
+
+type nodeHealth struct {
+	failures int
+	ejected  time.Time
+}
+
+func newHealth() *health {
+	return &health{
+		nodes: make(map[string]*nodeHealth),
+	}
+}
+
+// mark records the result of a request to a node
+func (h *health) mark(node *registry.Node, ok bool) {
+	h.Lock()
+	defer h.Unlock()
+
+	if ok {
+		delete(h.nodes, node.Id)
+		return
+	}
+
+	n, ok := h.nodes[node.Id]
+	if !ok {
+		n = new(nodeHealth)
+		h.nodes[node.Id] = n
+	}
+
+	n.failures++
+	if n.failures >= DefaultMaxFailures {
+		n.failures = 0
+		n.ejected = time.Now().Add(DefaultEjectDuration)
+	}
+}
+
+// ejected returns true if the node is currently being skipped
+func (h *health) ejected(node *registry.Node) bool {
+	h.Lock()
+	defer h.Unlock()
+
+	n, ok := h.nodes[node.Id]
+	if !ok {
+		return false
+	}
+	return time.Now().Before(n.ejected)
+}
+
+// filter is a selector.Filter which removes ejected nodes. All the nodes
+// are kept if every one of them has been ejected.
+func (h *health) filter(old []*registry.Service) []*registry.Service {
+	var services []*registry.Service
+	var count int
+
+	for _, service := range old {
+		var nodes []*registry.Node
+		for _, node := range service.Nodes {
+			if h.ejected(node) {
+				continue
+			}
+			nodes = append(nodes, node)
+		}
+
+		if len(nodes) == 0 {
+			continue
+		}
+		count += len(nodes)
+
+		s := new(registry.Service)
+		*s = *service
+		s.Nodes = nodes
+		services = append(services, s)
+	}
+
+	if count == 0 {
+		return old
+	}
+	return services
+}
@@ -2,31 +2,60 @@
 package http
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/micro/go-micro/v2"
+	"github.com/micro/go-micro/v2/client/selector"
 	"github.com/micro/go-micro/v2/errors"
+	"github.com/micro/go-micro/v2/registry"
 	"github.com/micro/go-micro/v2/server"
 )
 
-// Router will proxy rpc requests as http POST requests. It is a server.Router
+// Router will proxy rpc requests as http requests. It is a server.Router
 type Router struct {
 	// Converts RPC Foo.Bar to /foo/bar
 	Resolver *Resolver
 	// The http backend to call
 	Backend string
+	// Service is the name of a http service in the registry to call instead
+	// of the Backend. Requests are balanced across its nodes and nodes which
+	// keep failing are ejected for a while.
+	Service string
+	// Registry used to look up the Service, defaults to registry.DefaultRegistry
+	Registry registry.Registry
+	// Client makes the http requests, defaults to http.DefaultClient
+	Client *http.Client
+
+	once   sync.Once
+	health *health
+
+	// the selector is built on first use from the registry
+	// at the time, and rebuilt if the registry is changed
+	smtx     sync.Mutex
+	selector selector.Selector
+	registry registry.Registry
+
+	sync.RWMutex
+	// rpc ep / http route mapping
+	eps map[string]*Route
+}
 
-	// first request
-	first bool
-	// rpc ep / http ep mapping
-	eps map[string]string
+// Route is the http method and path for an rpc endpoint. Path parameters
+// e.g /users/{id} are filled from fields of a json request body.
+type Route struct {
+	Method string
+	Path   string
 }
 
 // Resolver resolves rpc to http. It explicity maps Foo.Bar to /foo/bar
@@ -51,15 +80,57 @@ func (r *Resolver) Resolve(ep string) string {
 
 // set the nil things
 func (p *Router) setup() {
-	if p.Resolver == nil {
-		p.Resolver = new(Resolver)
+	p.once.Do(func() {
+		p.Lock()
+		if p.eps == nil {
+			p.eps = map[string]*Route{}
+		}
+		p.Unlock()
+
+		p.health = newHealth()
+	})
+}
+
+// route returns the route for an rpc endpoint
+func (p *Router) route(rpcEp string) *Route {
+	p.RLock()
+	r, ok := p.eps[rpcEp]
+	p.RUnlock()
+	if ok {
+		return r
 	}
-	if p.Backend == "" {
-		p.Backend = DefaultBackend
+
+	// get default
+	resolver := p.Resolver
+	if resolver == nil {
+		resolver = new(Resolver)
 	}
-	if p.eps == nil {
-		p.eps = map[string]string{}
+	return &Route{Method: "POST", Path: resolver.Resolve(rpcEp)}
+}
+
+// backend returns the url of the static backend
+func (p *Router) backend() (*url.URL, error) {
+	backend := p.Backend
+	if len(backend) == 0 {
+		backend = DefaultBackend
 	}
+
+	u, err := url.Parse(backend)
+	if err != nil {
+		return nil, err
+	}
+
+	// set scheme
+	if len(u.Scheme) == 0 {
+		u.Scheme = "http"
+	}
+
+	// set host
+	if len(u.Host) == 0 {
+		u.Host = "localhost"
+	}
+
+	return u, nil
 }
 
 // Endpoint returns the http endpoint for an rpc endpoint.
@@ -67,22 +138,15 @@ func (p *Router) setup() {
 func (p *Router) Endpoint(rpcEp string) (string, error) {
 	p.setup()
 
-	// get http endpoint
-	ep, ok := p.eps[rpcEp]
-	if !ok {
-		// get default
-		ep = p.Resolver.Resolve(rpcEp)
-	}
+	ep := p.route(rpcEp).Path
 
 	// already full qualified URL
-	if strings.HasPrefix(ep, "http://") || strings.HasPrefix(ep, "https://") {
+	if isURL(ep) {
 		return ep, nil
 	}
 
-	// parse into url
-
 	// full path to call
-	u, err := url.Parse(p.Backend)
+	u, err := p.backend()
 	if err != nil {
 		return "", err
 	}
@@ -90,16 +154,6 @@ func (p *Router) Endpoint(rpcEp string) (string, error) {
 	// set path
 	u.Path = filepath.Join(u.Path, ep)
 
-	// set scheme
-	if len(u.Scheme) == 0 {
-		u.Scheme = "http"
-	}
-
-	// set host
-	if len(u.Host) == 0 {
-		u.Host = "localhost"
-	}
-
 	// create ep
 	return u.String(), nil
 }
@@ -111,10 +165,22 @@ func (p *Router) Endpoint(rpcEp string) (string, error) {
 //	RegisterEndpoint("Greeter.Hello", "/helloworld")
 //	RegisterEndpoint("Greeter.Hello", "http://localhost:8080/")
 func (p *Router) RegisterEndpoint(rpcEp, httpEp string) error {
+	return p.RegisterRoute(rpcEp, "POST", httpEp)
+}
+
+// RegisterRoute registers a http method and path template against an RPC endpoint.
+//	RegisterRoute("Users.Read", "GET", "/users/{id}")
+//	RegisterRoute("Users.Delete", "DELETE", "/users/{id}")
+func (p *Router) RegisterRoute(rpcEp, method, path string) error {
 	p.setup()
 
-	// create ep
-	p.eps[rpcEp] = httpEp
+	if len(method) == 0 {
+		method = "POST"
+	}
+
+	p.Lock()
+	p.eps[rpcEp] = &Route{Method: strings.ToUpper(method), Path: path}
+	p.Unlock()
 	return nil
 }
 
@@ -124,8 +190,19 @@ func (p *Router) ProcessMessage(ctx context.Context, msg server.Message) error {
 
 // ServeRequest honours the server.Router interface
 func (p *Router) ServeRequest(ctx context.Context, req server.Request, rsp server.Response) error {
-	// rudimentary post based streaming
-	for {
+	p.setup()
+
+	// get rpc endpoint
+	rpcEp := req.Endpoint()
+
+	for first := true; ; first = false {
+		// later stream messages may set the endpoint
+		if !first {
+			if ep := req.Header()["X-Micro-Endpoint"]; len(ep) > 0 {
+				rpcEp = ep
+			}
+		}
+
 		// get data
 		body, err := req.Read()
 		if err == io.EOF {
@@ -135,69 +212,284 @@ func (p *Router) ServeRequest(ctx context.Context, req server.Request, rsp serve
 			return err
 		}
 
-		var rpcEp string
-
-		// get rpc endpoint
-		if p.first {
-			p.first = false
-			rpcEp = req.Endpoint()
-		} else {
-			hdr := req.Header()
-			rpcEp = hdr["X-Micro-Endpoint"]
+		if err := p.serve(ctx, req, rsp, rpcEp, body); err != nil {
+			return err
 		}
 
-		// get http endpoint
-		ep, err := p.Endpoint(rpcEp)
-		if err != nil {
-			return errors.NotFound(req.Service(), err.Error())
+		// a unary request has a single message
+		if !req.Stream() {
+			return nil
 		}
+	}
+}
 
-		// no stream support currently
-		// TODO: lookup host
-		hreq, err := http.NewRequest("POST", ep, bytes.NewReader(body))
-		if err != nil {
-			return errors.InternalServerError(req.Service(), err.Error())
-		}
+// serve proxies a single request message
+func (p *Router) serve(ctx context.Context, req server.Request, rsp server.Response, rpcEp string, body []byte) error {
+	route := p.route(rpcEp)
+
+	// pick the backend
+	var u *url.URL
+	var node *registry.Node
+	var err error
+
+	switch {
+	case isURL(route.Path):
+		u, err = url.Parse(route.Path)
+		route = &Route{Method: route.Method}
+	case len(p.Service) > 0:
+		u, node, err = p.next()
+	default:
+		u, err = p.backend()
+	}
+	if err != nil {
+		return errors.NotFound(req.Service(), err.Error())
+	}
+
+	hreq, err := newRequest(route, u, req.ContentType(), body)
+	if err != nil {
+		return errors.BadRequest(req.Service(), err.Error())
+	}
+
+	// get the header
+	hdr := req.Header()
+
+	// set the headers
+	for k, v := range hdr {
+		hreq.Header.Set(k, v)
+	}
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	// make the call
+	hrsp, err := client.Do(hreq.WithContext(ctx))
+	if node != nil {
+		p.health.mark(node, err == nil && hrsp.StatusCode < 500)
+	}
+	if err != nil {
+		return errors.InternalServerError(req.Service(), err.Error())
+	}
+	defer hrsp.Body.Close()
+
+	// set response headers
+	hdr = map[string]string{}
+	for k := range hrsp.Header {
+		hdr[k] = hrsp.Header.Get(k)
+	}
+	// write the header
+	rsp.WriteHeader(hdr)
+
+	// pass on each event or chunk as it arrives
+	if req.Stream() && isStream(hrsp) {
+		return p.stream(req, rsp, hrsp)
+	}
 
-		// get the header
-		hdr := req.Header()
+	// read body
+	b, err := ioutil.ReadAll(hrsp.Body)
+	if err != nil {
+		return errors.InternalServerError(req.Service(), err.Error())
+	}
+
+	// write the body
+	err = rsp.Write(b)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return errors.InternalServerError(req.Service(), err.Error())
+	}
+
+	return nil
+}
+
+// getSelector returns the selector for the registry currently in use
+func (p *Router) getSelector() selector.Selector {
+	reg := p.Registry
+	if reg == nil {
+		reg = registry.DefaultRegistry
+	}
+
+	p.smtx.Lock()
+	defer p.smtx.Unlock()
+
+	if p.selector != nil && p.registry == reg {
+		return p.selector
+	}
+
+	if p.selector != nil {
+		p.selector.Close()
+	}
+
+	p.selector = selector.NewSelector(
+		selector.Registry(reg),
+		selector.SetStrategy(selector.RoundRobin),
+	)
+	p.registry = reg
+
+	return p.selector
+}
 
-		// set the headers
-		for k, v := range hdr {
-			hreq.Header.Set(k, v)
+// next returns the url of the next healthy node of the backend service
+func (p *Router) next() (*url.URL, *registry.Node, error) {
+	next, err := p.getSelector().Select(p.Service, selector.WithFilter(p.health.filter))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	node, err := next()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scheme := "http"
+	if node.Metadata["secure"] == "true" {
+		scheme = "https"
+	}
+
+	return &url.URL{Scheme: scheme, Host: node.Address}, node, nil
+}
+
+// stream writes each server sent event or chunk of the response
+func (p *Router) stream(req server.Request, rsp server.Response, hrsp *http.Response) error {
+	sse := strings.HasPrefix(hrsp.Header.Get("Content-Type"), "text/event-stream")
+	reader := bufio.NewReader(hrsp.Body)
+	buf := make([]byte, 32*1024)
+
+	for {
+		var b []byte
+		var err error
+
+		if sse {
+			b, err = readEvent(reader)
+		} else {
+			var n int
+			n, err = reader.Read(buf)
+			b = buf[:n]
 		}
 
-		// make the call
-		hrsp, err := http.DefaultClient.Do(hreq)
+		if len(b) > 0 {
+			if werr := rsp.Write(b); werr == io.EOF {
+				return nil
+			} else if werr != nil {
+				return errors.InternalServerError(req.Service(), werr.Error())
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
 		if err != nil {
 			return errors.InternalServerError(req.Service(), err.Error())
 		}
+	}
+}
 
-		// read body
-		b, err := ioutil.ReadAll(hrsp.Body)
-		hrsp.Body.Close()
+// readEvent reads a server sent event up to and including the blank line ending it
+func readEvent(r *bufio.Reader) ([]byte, error) {
+	var event []byte
+	for {
+		line, err := r.ReadBytes('\n')
+		event = append(event, line...)
 		if err != nil {
-			return errors.InternalServerError(req.Service(), err.Error())
+			return event, err
 		}
+		if len(bytes.TrimRight(line, "\r\n")) == 0 && len(event) > len(line) {
+			return event, nil
+		}
+	}
+}
 
-		// set response headers
-		hdr = map[string]string{}
-		for k, _ := range hrsp.Header {
-			hdr[k] = hrsp.Header.Get(k)
+// isStream returns true for server sent events and chunked responses
+func isStream(hrsp *http.Response) bool {
+	if strings.HasPrefix(hrsp.Header.Get("Content-Type"), "text/event-stream") {
+		return true
+	}
+	return hrsp.ContentLength < 0
+}
+
+// isURL returns true for fully qualified urls
+func isURL(ep string) bool {
+	return strings.HasPrefix(ep, "http://") || strings.HasPrefix(ep, "https://")
+}
+
+// newRequest builds the http request for a route. Path parameters are taken
+// from a json body and the remaining fields are sent as the query string
+// for methods without a body.
+func newRequest(route *Route, u *url.URL, contentType string, body []byte) (*http.Request, error) {
+	hasBody := route.Method != "GET" && route.Method != "HEAD" && route.Method != "DELETE"
+
+	params := strings.Contains(route.Path, "{")
+
+	var fields map[string]interface{}
+	if params || !hasBody {
+		// only json bodies can be mapped to the url
+		d := json.NewDecoder(bytes.NewReader(body))
+		d.UseNumber()
+		if err := d.Decode(&fields); err != nil && params {
+			return nil, fmt.Errorf("path %s requires a json body: %v", route.Path, err)
 		}
-		// write the header
-		rsp.WriteHeader(hdr)
-		// write the body
-		err = rsp.Write(b)
-		if err == io.EOF {
-			return nil
+	}
+
+	// fill in the path parameters, keeping the escaped path
+	// so values containing a / stay in a single segment
+	var path, rawPath string
+	for tmpl := route.Path; len(tmpl) > 0; {
+		i := strings.Index(tmpl, "{")
+		if i < 0 {
+			path += tmpl
+			rawPath += tmpl
+			break
 		}
-		if err != nil {
-			return errors.InternalServerError(req.Service(), err.Error())
+		j := strings.Index(tmpl[i:], "}")
+		if j < 0 {
+			return nil, fmt.Errorf("invalid path %s", route.Path)
 		}
+		name := tmpl[i+1 : i+j]
+		v, ok := fields[name]
+		if !ok {
+			return nil, fmt.Errorf("missing path parameter %s", name)
+		}
+		delete(fields, name)
+		path += tmpl[:i] + fmt.Sprint(v)
+		rawPath += tmpl[:i] + url.PathEscape(fmt.Sprint(v))
+		tmpl = tmpl[i+j+1:]
 	}
 
-	return nil
+	ep := *u
+	if len(path) > 0 {
+		ep.RawPath = strings.TrimSuffix(ep.EscapedPath(), "/") + rawPath
+		ep.Path = strings.TrimSuffix(ep.Path, "/") + path
+	}
+
+	var reader io.Reader
+	if hasBody {
+		// send what's left after removing the path parameters
+		if params {
+			b, err := json.Marshal(fields)
+			if err != nil {
+				return nil, err
+			}
+			body = b
+		}
+		reader = bytes.NewReader(body)
+	} else if len(fields) > 0 {
+		query := ep.Query()
+		for k, v := range fields {
+			query.Set(k, fmt.Sprint(v))
+		}
+		ep.RawQuery = query.Encode()
+	}
+
+	hreq, err := http.NewRequest(route.Method, ep.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	if hasBody {
+		hreq.Header.Set("Content-Type", contentType)
+	}
+	return hreq, nil
 }
 
 // NewSingleHostRouter returns a router which sends requests a single http backend
@@ -226,7 +518,16 @@ func NewSingleHostRouter(url string) *Router {
 	return &Router{
 		Resolver: new(Resolver),
 		Backend:  url,
-		eps:      map[string]string{},
+	}
+}
+
+// NewServiceRouter returns a router which balances requests across the
+// nodes of a http service in the registry
+func NewServiceRouter(service string, reg registry.Registry) *Router {
+	return &Router{
+		Resolver: new(Resolver),
+		Service:  service,
+		Registry: reg,
 	}
 }
 
@@ -269,3 +570,9 @@ func NewService(opts ...micro.Option) micro.Service {
 func RegisterEndpoint(rpcEp string, httpEp string) error {
 	return DefaultRouter.RegisterEndpoint(rpcEp, httpEp)
 }
+
+// RegisterRoute registers a http method and path against an RPC endpoint
+//	RegisterRoute("Users.Read", "GET", "/users/{id}")
+func RegisterRoute(rpcEp, method, path string) error {
+	return DefaultRouter.RegisterRoute(rpcEp, method, path)
+}
@@ -3,13 +3,18 @@ package http
 import (
 	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 
 	"github.com/micro/go-micro/v2"
 	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/registry"
 	"github.com/micro/go-micro/v2/registry/memory"
 	"github.com/micro/go-micro/v2/server"
 )
@@ -137,3 +142,233 @@ func TestHTTPRouterOptions(t *testing.T) {
 		t.Fatalf("Expected endpoint http://foo2.bar got %v", httpRouter.Backend)
 	}
 }
+
+type testRequest struct {
+	server.Request
+	endpoint string
+	body     []byte
+	stream   bool
+	read     bool
+}
+
+func (r *testRequest) Service() string           { return "foobar" }
+func (r *testRequest) Endpoint() string          { return r.endpoint }
+func (r *testRequest) ContentType() string       { return "application/json" }
+func (r *testRequest) Header() map[string]string { return map[string]string{} }
+func (r *testRequest) Stream() bool              { return r.stream }
+func (r *testRequest) Read() ([]byte, error) {
+	if r.read {
+		return nil, io.EOF
+	}
+	r.read = true
+	return r.body, nil
+}
+
+type testResponse struct {
+	server.Response
+	writes [][]byte
+}
+
+func (r *testResponse) WriteHeader(map[string]string) {}
+func (r *testResponse) Write(b []byte) error {
+	r.writes = append(r.writes, append([]byte(nil), b...))
+	return nil
+}
+
+func serve(p *Router, ep, body string, stream bool) (*testResponse, error) {
+	rsp := new(testResponse)
+	req := &testRequest{endpoint: ep, body: []byte(body), stream: stream}
+	return rsp, p.ServeRequest(context.Background(), req, rsp)
+}
+
+func TestHTTPRouterRoutes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s %s %s", r.Method, r.URL.Path, r.URL.RawQuery, b)
+	}))
+	defer srv.Close()
+
+	p := NewSingleHostRouter(srv.URL)
+	p.RegisterRoute("Users.Read", "GET", "/users/{id}")
+	p.RegisterRoute("Users.Update", "put", "/users/{id}")
+
+	testCases := []struct {
+		rpcEp  string
+		body   string
+		expect string
+	}{
+		{"Foo.Bar", `{"foo":"bar"}`, `POST /foo/bar  {"foo":"bar"}`},
+		{"Users.Read", `{"id":"a b","full":true}`, `GET /users/a b full=true `},
+		{"Users.Update", `{"id":1,"name":"john"}`, `PUT /users/1  {"name":"john"}`},
+	}
+
+	for _, test := range testCases {
+		rsp, err := serve(p, test.rpcEp, test.body, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(rsp.writes[0]); got != test.expect {
+			t.Fatalf("Expected %q for %s got %q", test.expect, test.rpcEp, got)
+		}
+	}
+
+	// missing path parameter
+	if _, err := serve(p, "Users.Read", `{}`, false); err == nil {
+		t.Fatal("Expected error for missing path parameter")
+	}
+}
+
+func TestHTTPRouterOpenAPI(t *testing.T) {
+	doc := `{
+		"openapi": "3.0.0",
+		"servers": [{"url": "http://example.com/v1"}],
+		"paths": {
+			"/users/{id}": {
+				"parameters": [{"name": "id", "in": "path"}],
+				"get": {"operationId": "getUser", "x-micro-endpoint": "Users.Read"},
+				"delete": {"operationId": "Users.Delete"}
+			},
+			"/health": {
+				"get": {}
+			}
+		}
+	}`
+
+	p := NewSingleHostRouter("http://localhost:10001")
+	if err := p.LoadOpenAPI(strings.NewReader(doc)); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := map[string]Route{
+		"Users.Read":   {"GET", "/v1/users/{id}"},
+		"Users.Delete": {"DELETE", "/v1/users/{id}"},
+		"Health.Check": {"POST", "/health/check"},
+	}
+
+	for ep, expect := range testCases {
+		if r := p.route(ep); *r != expect {
+			t.Fatalf("Expected %v for %s got %v", expect, ep, *r)
+		}
+	}
+}
+
+func TestHTTPRouterService(t *testing.T) {
+	srv := httptest.NewServer(new(testHandler))
+	defer srv.Close()
+
+	// a node which refuses connections
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead := l.Addr().String()
+	l.Close()
+
+	reg := memory.NewRegistry()
+	reg.Register(&registry.Service{
+		Name:    "backend",
+		Version: "latest",
+		Nodes: []*registry.Node{
+			{Id: "live", Address: strings.TrimPrefix(srv.URL, "http://")},
+			{Id: "dead", Address: dead},
+		},
+	})
+
+	p := NewServiceRouter("backend", reg)
+
+	var failures int
+	for i := 0; i < 20; i++ {
+		if _, err := serve(p, "Foo.Bar", `{}`, false); err != nil {
+			failures++
+		}
+	}
+
+	// the dead node is ejected after consecutive failures
+	if failures > DefaultMaxFailures {
+		t.Fatalf("Expected at most %d failures got %d", DefaultMaxFailures, failures)
+	}
+
+	for i := 0; i < 10; i++ {
+		rsp, err := serve(p, "Foo.Bar", `{}`, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(rsp.writes[0]); got != `{"hello": "world"}` {
+			t.Fatalf("Unexpected response %s", got)
+		}
+	}
+}
+
+func TestHTTPRouterServiceRegistry(t *testing.T) {
+	newBackend := func(body string) (*httptest.Server, registry.Registry) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}))
+		reg := memory.NewRegistry()
+		reg.Register(&registry.Service{
+			Name:    "backend",
+			Version: "latest",
+			Nodes: []*registry.Node{
+				{Id: "node", Address: strings.TrimPrefix(srv.URL, "http://")},
+			},
+		})
+		return srv, reg
+	}
+
+	srv1, reg1 := newBackend(`"one"`)
+	defer srv1.Close()
+	srv2, reg2 := newBackend(`"two"`)
+	defer srv2.Close()
+
+	// routes are registered before the registry is set, as
+	// with RegisterRoute followed by WithBackendService
+	p := &Router{Service: "backend"}
+	if err := p.RegisterRoute("Foo.Bar", "POST", "/foo/bar"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range []struct {
+		reg    registry.Registry
+		expect string
+	}{
+		{reg1, `"one"`},
+		{reg2, `"two"`},
+	} {
+		p.Registry = d.reg
+
+		rsp, err := serve(p, "Foo.Bar", `{}`, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(rsp.writes[0]); got != d.expect {
+			t.Fatalf("Expected %s got %s", d.expect, got)
+		}
+	}
+}
+
+func TestHTTPRouterStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "data: %d\n\n", i)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer srv.Close()
+
+	p := NewSingleHostRouter(srv.URL)
+
+	rsp, err := serve(p, "Foo.Events", `{}`, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rsp.writes) != 3 {
+		t.Fatalf("Expected 3 events got %d: %q", len(rsp.writes), rsp.writes)
+	}
+	for i, b := range rsp.writes {
+		if expect := fmt.Sprintf("data: %d\n\n", i); string(b) != expect {
+			t.Fatalf("Expected event %q got %q", expect, b)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// ExtensionEndpoint is the openapi operation extension naming the rpc
+// endpoint for a route. The operationId is used when it's not set.
+const ExtensionEndpoint = "x-micro-endpoint"
+
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
+// openAPI is the subset of an openapi 3 or swagger 2 document used for routing
+type openAPI struct {
+	// openapi 3
+	Servers []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	// swagger 2
+	BasePath string                                `json:"basePath"`
+	Paths    map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+type operation struct {
+	OperationID string `json:"operationId"`
+	Endpoint    string `json:"x-micro-endpoint"`
+}
+
+// LoadOpenAPI registers a route for every operation in an openapi 3 or
+// swagger 2 json document. Operations are mapped to the rpc endpoint in
+// their x-micro-endpoint extension or otherwise their operationId e.g
+//
+//	"/users/{id}": {
+//		"get": {"operationId": "Users.Read"}
+//	}
+//
+// Paths are prefixed with the path of the first server url or the basePath.
+func (p *Router) LoadOpenAPI(r io.Reader) error {
+	var doc openAPI
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return err
+	}
+
+	base := doc.BasePath
+	if len(doc.Servers) > 0 {
+		u, err := url.Parse(doc.Servers[0].URL)
+		if err != nil {
+			return err
+		}
+		base = u.Path
+	}
+	base = strings.TrimSuffix(base, "/")
+
+	for path, item := range doc.Paths {
+		for _, method := range methods {
+			raw, ok := item[method]
+			if !ok {
+				continue
+			}
+
+			var op operation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return err
+			}
+
+			ep := op.Endpoint
+			if len(ep) == 0 {
+				ep = op.OperationID
+			}
+			// can't route without a name
+			if len(ep) == 0 {
+				continue
+			}
+
+			if err := p.RegisterRoute(ep, method, base+path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// LoadOpenAPI registers the routes in an openapi document with the DefaultRouter
+func LoadOpenAPI(r io.Reader) error {
+	return DefaultRouter.LoadOpenAPI(r)
+}
@@ -24,6 +24,29 @@ func WithBackend(url string) micro.Option {
 	}
 }
 
+// WithBackendService provides an option to proxy to the nodes of a http
+// service in the registry rather than a single backend url
+func WithBackendService(name string) micro.Option {
+	return func(o *micro.Options) {
+		// get the router
+		r := o.Server.Options().Router
+
+		// not set
+		if r == nil {
+			r = DefaultRouter
+			o.Server.Init(server.WithRouter(r))
+		}
+
+		// check its a http router
+		if httpRouter, ok := r.(*Router); ok {
+			httpRouter.Service = name
+			if httpRouter.Registry == nil {
+				httpRouter.Registry = o.Registry
+			}
+		}
+	}
+}
+
 // WithRouter provides an option to set the http router
 func WithRouter(r server.Router) micro.Option {
 	return func(o *micro.Options) {
@@ -298,12 +298,23 @@ func (c *consulRegistry) Register(s *registry.Service, opts ...registry.Register
 }
 
 func (c *consulRegistry) GetService(name string, opts ...registry.GetOption) ([]*registry.Service, error) {
+	return c.getService(name, nil)
+}
+
+// getService gets the nodes of a service with all of the tags
+func (c *consulRegistry) getService(name string, tags []string) ([]*registry.Service, error) {
 	var rsp []*consul.ServiceEntry
 	var err error
 
 	// if we're connect enabled only get connect services
 	if c.connect {
-		rsp, _, err = c.Client().Health().Connect(name, "", false, c.queryOptions)
+		var tag string
+		if len(tags) > 0 {
+			tag = tags[0]
+		}
+		rsp, _, err = c.Client().Health().Connect(name, tag, false, c.queryOptions)
+	} else if len(tags) > 0 {
+		rsp, _, err = c.Client().Health().ServiceMultipleTags(name, tags, false, c.queryOptions)
 	} else {
 		rsp, _, err = c.Client().Health().Service(name, "", false, c.queryOptions)
 	}
@@ -387,6 +398,57 @@ func (c *consulRegistry) ListServices(opts ...registry.ListOption) ([]*registry.
 	return services, nil
 }
 
+// QueryNames lists the names of the services with the version and
+// metadata as tags from the catalog, which has the tags of all the nodes
+// of each service. Only nodes registered with the hex encoding of tags are
+// matched. It implements the NameQuerier interface of registry/query.
+func (c *consulRegistry) QueryNames(version string, metadata map[string]string) ([]string, error) {
+	tags := queryTags(version, metadata)
+
+	rsp, _, err := c.Client().Catalog().Services(c.queryOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name, serviceTags := range rsp {
+		if hasTags(serviceTags, tags) {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+// QueryServices gets the services with the version and metadata pushed
+// down to consul as tags, all the matching services if the name is blank.
+// It implements the Querier interface of registry/query.
+func (c *consulRegistry) QueryServices(name, version string, metadata map[string]string) ([]*registry.Service, error) {
+	tags := queryTags(version, metadata)
+
+	if len(name) > 0 {
+		return c.getService(name, tags)
+	}
+
+	names, err := c.QueryNames(version, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	var services []*registry.Service
+	for _, name := range names {
+		srvs, err := c.getService(name, tags)
+		if err == registry.ErrNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		services = append(services, srvs...)
+	}
+
+	return services, nil
+}
+
 func (c *consulRegistry) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
 	return newConsulWatcher(c, opts...)
 }
@@ -169,3 +169,29 @@ func decodeVersion(tags []string) (string, bool) {
 	}
 	return "", false
 }
+
+// queryTags are the tags of the version and metadata of a query
+func queryTags(version string, metadata map[string]string) []string {
+	var tags []string
+	if len(version) > 0 {
+		tags = append(tags, encodeVersion(version)...)
+	}
+	return append(tags, encodeMetadata(metadata)...)
+}
+
+// hasTags returns true if all of the tags are in the list
+func hasTags(list, tags []string) bool {
+	for _, tag := range tags {
+		var found bool
+		for _, t := range list {
+			if t == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -206,3 +206,50 @@ func TestConsul_GetService_WithUnhealthyServiceNodes(t *testing.T) {
 		t.Fatalf("Expected len of nodes to be `%d`, got `%d`.", exp, act)
 	}
 }
+
+func TestConsul_Query_WithTags(t *testing.T) {
+	svcs := []*consul.ServiceEntry{
+		newServiceEntry(
+			"node-name-1", "node-address-1", "service-name", "v1.0.0",
+			[]*consul.HealthCheck{
+				newHealthCheck("node-name-1", "service-name", "passing"),
+			},
+		),
+	}
+
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	tags := make(chan []string, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/health/service/service-name", func(w http.ResponseWriter, r *http.Request) {
+		tags <- r.URL.Query()["tag"]
+		w.Write(newServiceList(svcs))
+	})
+	go http.Serve(l, mux)
+
+	cfg := consul.DefaultConfig()
+	cfg.Address = l.Addr().String()
+	cr := &consulRegistry{
+		config:       cfg,
+		Address:      []string{cfg.Address},
+		queryOptions: &consul.QueryOptions{},
+	}
+	cr.Client()
+
+	services, err := cr.QueryServices("service-name", "v1.0.0", nil)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+
+	if exp, act := encodeVersion("v1.0.0"), <-tags; len(act) != 1 || act[0] != exp[0] {
+		t.Fatalf("Expected the version tag `%v`, got `%v`.", exp, act)
+	}
+
+	if exp, act := 1, len(services); exp != act {
+		t.Fatalf("Expected len of services to be `%d`, got `%d`.", exp, act)
+	}
+}
@@ -7,10 +7,23 @@ import (
 	"github.com/micro/go-micro/v2/registry/etcd"
 )
 
+type etcdRegistry struct {
+	registry.Registry
+}
+
 func init() {
-	cmd.DefaultRegistries["etcdv3"] = etcd.NewRegistry
+	cmd.DefaultRegistries["etcdv3"] = NewRegistry
+}
+
+// QueryServices reads the keys of a service or all services in a single
+// range request. It implements the Querier interface of registry/query.
+func (e *etcdRegistry) QueryServices(name, version string, metadata map[string]string) ([]*registry.Service, error) {
+	if len(name) > 0 {
+		return e.GetService(name)
+	}
+	return e.ListServices()
 }
 
 func NewRegistry(opts ...registry.Option) registry.Registry {
-	return etcd.NewRegistry(opts...)
+	return &etcdRegistry{etcd.NewRegistry(opts...)}
 }
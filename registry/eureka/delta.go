@@ -0,0 +1,207 @@
+package eureka
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/hudl/fargo"
+)
+
+const (
+	// delta actions
+	actionAdded    = "ADDED"
+	actionModified = "MODIFIED"
+	actionDeleted  = "DELETED"
+)
+
+// deltaConnection fetches the instances changed in the last few minutes
+// through the /apps/delta api rather than fetching all the apps
+type deltaConnection interface {
+	GetAppsDelta() (*appsDelta, error)
+}
+
+// appsDelta is a response of the /apps/delta api
+type appsDelta struct {
+	// Hashcode of all the apps once the changes are applied
+	Hashcode string
+	Changes  []*instanceChange
+}
+
+type instanceChange struct {
+	App      string
+	Action   string
+	Instance *fargo.Instance
+}
+
+// eurekaConn adds delta fetches to the fargo connection
+type eurekaConn struct {
+	*fargo.EurekaConnection
+}
+
+func (c *eurekaConn) GetAppsDelta() (*appsDelta, error) {
+	var err error
+
+	for _, url := range c.ServiceUrls {
+		var delta *appsDelta
+		if delta, err = getAppsDelta(strings.TrimSuffix(url, "/") + "/apps/delta"); err == nil {
+			return delta, nil
+		}
+	}
+
+	if err == nil {
+		err = errors.New("no eureka service urls")
+	}
+
+	return nil, err
+}
+
+func getAppsDelta(url string) (*appsDelta, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	rsp, err := fargo.HttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+
+	b, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, rsp.Status)
+	}
+
+	return parseAppsDelta(b)
+}
+
+func parseAppsDelta(b []byte) (*appsDelta, error) {
+	var rsp struct {
+		Applications struct {
+			Hashcode     string          `json:"apps__hashcode"`
+			Applications json.RawMessage `json:"application"`
+		} `json:"applications"`
+	}
+
+	if err := json.Unmarshal(b, &rsp); err != nil {
+		return nil, err
+	}
+
+	delta := &appsDelta{Hashcode: rsp.Applications.Hashcode}
+
+	apps, err := oneOrMany(rsp.Applications.Applications)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range apps {
+		var app struct {
+			Name      string          `json:"name"`
+			Instances json.RawMessage `json:"instance"`
+		}
+		if err := json.Unmarshal(a, &app); err != nil {
+			return nil, err
+		}
+
+		instances, err := oneOrMany(app.Instances)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, i := range instances {
+			var action struct {
+				ActionType string `json:"actionType"`
+			}
+			if err := json.Unmarshal(i, &action); err != nil {
+				return nil, err
+			}
+
+			// skip instances fargo can't decode, the hashcode then
+			// doesn't add up and the apps are fetched in full
+			instance, err := decodeInstance(i)
+			if err != nil {
+				continue
+			}
+
+			delta.Changes = append(delta.Changes, &instanceChange{
+				App:      app.Name,
+				Action:   action.ActionType,
+				Instance: instance,
+			})
+		}
+	}
+
+	return delta, nil
+}
+
+// decodeInstance decodes an instance from the json api. The data center
+// info isn't used by the registry and fargo fails to decode it as json.
+func decodeInstance(b []byte) (*fargo.Instance, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	delete(fields, "dataCenterInfo")
+
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	instance := new(fargo.Instance)
+	if err := json.Unmarshal(b, instance); err != nil {
+		return nil, err
+	}
+	return instance, nil
+}
+
+// oneOrMany splits a json array, eureka encodes a single element without the array
+func oneOrMany(b json.RawMessage) ([]json.RawMessage, error) {
+	b = json.RawMessage(strings.TrimSpace(string(b)))
+
+	switch {
+	case len(b) == 0, string(b) == "null":
+		return nil, nil
+	case b[0] == '[':
+		var many []json.RawMessage
+		if err := json.Unmarshal(b, &many); err != nil {
+			return nil, err
+		}
+		return many, nil
+	default:
+		return []json.RawMessage{b}, nil
+	}
+}
+
+// hashcode is the eureka reconcile hashcode of the instances, the number of
+// instances of each status ordered by status e.g DOWN_1_UP_2_
+func hashcode(apps map[string]map[string]*fargo.Instance) string {
+	counts := make(map[string]int)
+	for _, instances := range apps {
+		for _, instance := range instances {
+			counts[string(instance.Status)]++
+		}
+	}
+
+	statuses := make([]string, 0, len(counts))
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+
+	var b strings.Builder
+	for _, status := range statuses {
+		fmt.Fprintf(&b, "%s_%d_", status, counts[status])
+	}
+	return b.String()
+}
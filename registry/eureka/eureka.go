@@ -29,6 +29,11 @@ type fargoConnection interface {
 type eurekaRegistry struct {
 	conn fargoConnection
 	opts registry.Options
+
+	// how often watchers fetch changes
+	pollInterval time.Duration
+	// include nodes which are out of service
+	outOfService bool
 }
 
 func init() {
@@ -57,9 +62,19 @@ func configure(e *eurekaRegistry, opts ...registry.Option) error {
 		fargo.HttpClient = c
 	}
 
+	e.pollInterval = DefaultPollInterval
+	if d, ok := e.opts.Context.Value(contextPollInterval{}).(time.Duration); ok && d > 0 {
+		e.pollInterval = d
+	}
+
+	e.outOfService = false
+	if b, ok := e.opts.Context.Value(contextOutOfService{}).(bool); ok {
+		e.outOfService = b
+	}
+
 	conn := fargo.NewConn(cAddrs...)
-	conn.PollInterval = time.Second * 5
-	e.conn = &conn
+	conn.PollInterval = e.pollInterval
+	e.conn = &eurekaConn{&conn}
 	return nil
 }
 
@@ -107,7 +122,12 @@ func (e *eurekaRegistry) GetService(name string, opts ...registry.GetOption) ([]
 	if err != nil {
 		return nil, err
 	}
-	return appToService(app), nil
+
+	services := appToService(app, e.outOfService)
+	if len(services) == 0 {
+		return nil, registry.ErrNotFound
+	}
+	return services, nil
 }
 
 func (e *eurekaRegistry) ListServices(opts ...registry.ListOption) ([]*registry.Service, error) {
@@ -119,14 +139,14 @@ func (e *eurekaRegistry) ListServices(opts ...registry.ListOption) ([]*registry.
 	}
 
 	for _, app := range apps {
-		services = append(services, appToService(app)...)
+		services = append(services, appToService(app, e.outOfService)...)
 	}
 
 	return services, nil
 }
 
 func (e *eurekaRegistry) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
-	return newWatcher(e.conn, opts...), nil
+	return newWatcher(e.conn, e.pollInterval, e.outOfService, opts...), nil
 }
 
 func (e *eurekaRegistry) String() string {
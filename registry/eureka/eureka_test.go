@@ -72,3 +72,51 @@ func TestSwitchHttpClient(t *testing.T) {
 		t.Errorf("Unexpected fargo.HttpClient: got %v, want %v", fargo.HttpClient, expected)
 	}
 }
+
+func TestGetServiceOutOfService(t *testing.T) {
+	app := &fargo.Application{Name: "FOO"}
+	for _, status := range []fargo.StatusType{fargo.UP, fargo.DOWN, fargo.OUTOFSERVICE} {
+		app.Instances = append(app.Instances, testInstance(t, string(status), status))
+	}
+
+	testData := []struct {
+		opts     []registry.Option
+		statuses []string
+	}{
+		{nil, []string{"UP", "DOWN"}},
+		{[]registry.Option{OutOfService(true)}, []string{"UP", "DOWN", "OUT_OF_SERVICE"}},
+	}
+
+	for _, test := range testData {
+		eureka := NewRegistry(test.opts...).(*eurekaRegistry)
+
+		mockConn := new(mock.FargoConnection)
+		mockConn.GetAppReturns(app, nil)
+		eureka.conn = mockConn
+
+		services, err := eureka.GetService("foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(services) != 1 || len(services[0].Nodes) != len(test.statuses) {
+			t.Fatalf("expected 1 service with %d nodes got %+v", len(test.statuses), services)
+		}
+		for i, node := range services[0].Nodes {
+			if node.Metadata[statusKey] != test.statuses[i] {
+				t.Errorf("expected status %s got %s", test.statuses[i], node.Metadata[statusKey])
+			}
+		}
+	}
+
+	mockConn := new(mock.FargoConnection)
+	mockConn.GetAppReturns(&fargo.Application{
+		Name:      "FOO",
+		Instances: []*fargo.Instance{testInstance(t, "a", fargo.OUTOFSERVICE)},
+	}, nil)
+	eureka := NewRegistry().(*eurekaRegistry)
+	eureka.conn = mockConn
+
+	if _, err := eureka.GetService("foo"); err != registry.ErrNotFound {
+		t.Fatalf("expected not found got %v", err)
+	}
+}
@@ -12,20 +12,34 @@ import (
 	"github.com/micro/go-micro/v2/registry"
 )
 
-func appToService(app *fargo.Application) []*registry.Service {
+const (
+	// statusKey is the node metadata key of the eureka status
+	statusKey = "status"
+)
+
+// visible returns false for instances taken out of service unless they're included
+func visible(instance *fargo.Instance, outOfService bool) bool {
+	return outOfService || instance.Status != fargo.OUTOFSERVICE
+}
+
+func appToService(app *fargo.Application, outOfService bool) []*registry.Service {
 	serviceMap := make(map[string]*registry.Service)
 
 	for _, instance := range app.Instances {
+		if !visible(instance, outOfService) {
+			continue
+		}
+
 		id := instance.Id()
 		addr := instance.IPAddr
 		port := instance.Port
 
-		var version string
 		var metadata map[string]string
 		var endpoints []*registry.Endpoint
 
 		// get version
-		if _, err := instance.Metadata.GetString("version"); err != nil {
+		version, err := instance.Metadata.GetString("version")
+		if err != nil {
 			continue
 		}
 
@@ -37,6 +51,12 @@ func appToService(app *fargo.Application) []*registry.Service {
 			json.Unmarshal([]byte(k), &metadata)
 		}
 
+		// set status
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		metadata[statusKey] = string(instance.Status)
+
 		// get existing service
 		service, ok := serviceMap[version]
 		if !ok {
@@ -48,7 +68,7 @@ func appToService(app *fargo.Application) []*registry.Service {
 			}
 		}
 
-                host, _, _ := net.SplitHostPort(addr)
+		host, _, _ := net.SplitHostPort(addr)
 
 		// append node
 		service.Nodes = append(service.Nodes, &registry.Node{
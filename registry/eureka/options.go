@@ -3,6 +3,7 @@ package eureka
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/micro/go-micro/v2/registry"
 	"golang.org/x/oauth2"
@@ -10,6 +11,13 @@ import (
 )
 
 type contextHttpClient struct{}
+type contextPollInterval struct{}
+type contextOutOfService struct{}
+
+var (
+	// DefaultPollInterval is how often changes are fetched from eureka
+	DefaultPollInterval = time.Second * 10
+)
 
 var newOAuthClient = func(c clientcredentials.Config) *http.Client {
 	return c.Client(oauth2.NoContext)
@@ -27,3 +35,18 @@ func OAuth2ClientCredentials(clientID, clientSecret, tokenURL string) registry.O
 		o.Context = context.WithValue(o.Context, contextHttpClient{}, newOAuthClient(c))
 	}
 }
+
+// PollInterval sets how often the watchers fetch the changes to the registry
+func PollInterval(d time.Duration) registry.Option {
+	return func(o *registry.Options) {
+		o.Context = context.WithValue(o.Context, contextPollInterval{}, d)
+	}
+}
+
+// OutOfService includes the nodes which are OUT_OF_SERVICE, they're
+// excluded by default. The eureka status of a node is in its metadata.
+func OutOfService(b bool) registry.Option {
+	return func(o *registry.Options) {
+		o.Context = context.WithValue(o.Context, contextOutOfService{}, b)
+	}
+}
@@ -2,6 +2,8 @@ package eureka
 
 import (
 	"errors"
+	"reflect"
+	"strings"
 	"time"
 
 	"github.com/hudl/fargo"
@@ -9,122 +11,194 @@ import (
 )
 
 type eurekaWatcher struct {
-	conn    fargoConnection
-	exit    chan bool
-	results chan *registry.Result
+	conn         fargoConnection
+	wo           registry.WatchOptions
+	interval     time.Duration
+	outOfService bool
+	exit         chan bool
+	results      chan *registry.Result
+
+	// instances of each app by id
+	apps map[string]map[string]*fargo.Instance
+	// the apps have been fetched once
+	synced bool
 }
 
-func newWatcher(conn fargoConnection, opts ...registry.WatchOption) registry.Watcher {
+func newWatcher(conn fargoConnection, interval time.Duration, outOfService bool, opts ...registry.WatchOption) registry.Watcher {
 	var wo registry.WatchOptions
 	for _, o := range opts {
 		o(&wo)
 	}
 
 	w := &eurekaWatcher{
-		conn:    conn,
-		exit:    make(chan bool),
-		results: make(chan *registry.Result),
+		conn:         conn,
+		wo:           wo,
+		interval:     interval,
+		outOfService: outOfService,
+		exit:         make(chan bool),
+		results:      make(chan *registry.Result),
+		apps:         make(map[string]map[string]*fargo.Instance),
 	}
 
-	// watch a single service
-	if len(wo.Service) > 0 {
-		done := make(chan struct{})
-		ch := conn.ScheduleAppUpdates(wo.Service, false, done)
-		go w.watch(ch, done)
-		go func() {
-			<-w.exit
-			close(done)
-		}()
-		return w
-	}
-
-	// watch all services
 	go w.poll()
 	return w
 }
 
 func (e *eurekaWatcher) poll() {
-	// list service ticker
-	t := time.NewTicker(time.Second * 10)
+	t := time.NewTicker(e.interval)
+	defer t.Stop()
 
-	done := make(chan struct{})
-	services := make(map[string]<-chan fargo.AppUpdate)
+	// the current apps aren't sent
+	e.update()
 
 	for {
 		select {
 		case <-e.exit:
-			close(done)
 			return
 		case <-t.C:
-			apps, err := e.conn.GetApps()
-			if err != nil {
-				continue
-			}
-			for _, app := range apps {
-				if _, ok := services[app.Name]; ok {
-					continue
+			e.update()
+		}
+	}
+}
+
+// update applies the changes since the last poll. All the apps are fetched
+// the first time and whenever the delta doesn't add up to the apps in
+// eureka, e.g when a poll was missed.
+func (e *eurekaWatcher) update() {
+	if d, ok := e.conn.(deltaConnection); ok && e.synced {
+		delta, err := d.GetAppsDelta()
+		if err == nil {
+			for _, c := range delta.Changes {
+				switch c.Action {
+				case actionAdded, actionModified:
+					e.set(c.App, c.Instance)
+				case actionDeleted:
+					e.remove(c.App, c.Instance.Id())
 				}
-				ch := e.conn.ScheduleAppUpdates(app.Name, false, done)
-				services[app.Name] = ch
-				go e.watch(ch, done)
+			}
+			if hashcode(e.apps) == delta.Hashcode {
+				return
 			}
 		}
 	}
-}
 
-func (e *eurekaWatcher) watch(ch <-chan fargo.AppUpdate, done chan struct{}) {
-	for {
-		select {
-		// exit on exit
-		case <-e.exit:
-			return
-		// exit on done
-		case <-done:
-			return
-		// process updates
-		case u := <-ch:
-			if u.Err != nil {
-				continue
+	apps, err := e.conn.GetApps()
+	if err != nil {
+		return
+	}
+
+	// add and update the instances
+	seen := make(map[string]map[string]bool)
+	for _, app := range apps {
+		seen[app.Name] = make(map[string]bool)
+		for _, instance := range app.Instances {
+			seen[app.Name][instance.Id()] = true
+			e.set(app.Name, instance)
+		}
+	}
+
+	// delete the instances and apps which are gone
+	for name, instances := range e.apps {
+		for id := range instances {
+			if !seen[name][id] {
+				e.remove(name, id)
 			}
+		}
+	}
 
-			// process instances independently
-			for _, instance := range u.App.Instances {
-				var action string
-
-				switch instance.Status {
-				// update
-				case fargo.UP:
-					action = "update"
-				// delete
-				case fargo.OUTOFSERVICE, fargo.UNKNOWN, fargo.DOWN:
-					action = "delete"
-				// skip
-				default:
-					continue
-				}
+	e.synced = true
+}
 
-				// construct the service with a single node
-				service := appToService(&fargo.Application{
-					Name:      u.App.Name,
-					Instances: []*fargo.Instance{instance},
-				})
+// set adds or updates an instance. Instances which aren't visible are
+// kept to check the hashcode but are sent as deleted.
+func (e *eurekaWatcher) set(app string, instance *fargo.Instance) {
+	instances, ok := e.apps[app]
+	if !ok {
+		instances = make(map[string]*fargo.Instance)
+		e.apps[app] = instances
+	}
 
-				if len(service) == 0 {
-					continue
-				}
+	id := instance.Id()
+	old, ok := instances[id]
+	instances[id] = instance
 
-				// in case we get bounced during processing
-				// check exit channels
-				select {
-				// send the update
-				case e.results <- &registry.Result{Action: action, Service: service[0]}:
-				case <-done:
-					return
-				case <-e.exit:
-					return
-				}
-			}
+	was := ok && visible(old, e.outOfService)
+	is := visible(instance, e.outOfService)
+
+	switch {
+	case !was && is:
+		e.send("create", app, instance)
+	case was && is:
+		// skip instances which haven't changed
+		if !changed(old, instance) {
+			return
 		}
+		e.send("update", app, instance)
+	case was && !is:
+		e.send("delete", app, old)
+	}
+}
+
+func changed(old, instance *fargo.Instance) bool {
+	return old.Status != instance.Status ||
+		old.IPAddr != instance.IPAddr ||
+		old.Port != instance.Port ||
+		!reflect.DeepEqual(metadata(old), metadata(instance))
+}
+
+// metadata returns the metadata of an instance. The metadata of instances
+// fetched from eureka is only parsed from the raw json once it's read.
+func metadata(instance *fargo.Instance) map[string]interface{} {
+	if len(instance.Metadata.Raw) > 0 {
+		instance.Metadata.GetString("version")
+	}
+	return instance.Metadata.GetMap()
+}
+
+func (e *eurekaWatcher) remove(app, id string) {
+	instances, ok := e.apps[app]
+	if !ok {
+		return
+	}
+
+	old, ok := instances[id]
+	if !ok {
+		return
+	}
+
+	delete(instances, id)
+	if len(instances) == 0 {
+		delete(e.apps, app)
+	}
+
+	if visible(old, e.outOfService) {
+		e.send("delete", app, old)
+	}
+}
+
+func (e *eurekaWatcher) send(action, app string, instance *fargo.Instance) {
+	// the current apps aren't sent
+	if !e.synced {
+		return
+	}
+
+	if len(e.wo.Service) > 0 && !strings.EqualFold(app, e.wo.Service) {
+		return
+	}
+
+	// construct the service with a single node
+	service := appToService(&fargo.Application{
+		Name:      app,
+		Instances: []*fargo.Instance{instance},
+	}, true)
+
+	if len(service) == 0 {
+		return
+	}
+
+	select {
+	case e.results <- &registry.Result{Action: action, Service: service[0]}:
+	case <-e.exit:
 	}
 }
 
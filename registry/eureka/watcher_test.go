@@ -0,0 +1,248 @@
+package eureka
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hudl/fargo"
+	"github.com/micro/go-micro/v2/registry"
+	"github.com/micro/go-plugins/registry/eureka/v2/mock"
+)
+
+func testInstance(t *testing.T, id string, status fargo.StatusType) *fargo.Instance {
+	instance, err := serviceToInstance(&registry.Service{
+		Name:    "foo",
+		Version: "1.0.0",
+		Nodes:   []*registry.Node{{Id: id, Address: "10.0.0.1:8080"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	instance.Status = status
+	instance.InstanceId = instance.Id()
+
+	// decode the instance as it's fetched from eureka
+	b, err := json.Marshal(instance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetched, err := decodeInstance(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fetched
+}
+
+func testApps(instances ...*fargo.Instance) map[string]*fargo.Application {
+	if len(instances) == 0 {
+		return map[string]*fargo.Application{}
+	}
+	return map[string]*fargo.Application{
+		"FOO": {Name: "FOO", Instances: instances},
+	}
+}
+
+func expectResult(t *testing.T, w registry.Watcher, action, id string) {
+	results := make(chan *registry.Result, 1)
+	go func() {
+		if r, err := w.Next(); err == nil {
+			results <- r
+		}
+	}()
+
+	select {
+	case r := <-results:
+		if r.Action != action || r.Service.Nodes[0].Id != "10.0.0.1:8080:"+id {
+			t.Fatalf("expected %s %s got %s %s", action, id, r.Action, r.Service.Nodes[0].Id)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected %s %s", action, id)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	stages := []map[string]*fargo.Application{
+		testApps(testInstance(t, "a", fargo.UP)),
+		testApps(testInstance(t, "a", fargo.UP), testInstance(t, "b", fargo.UP)),
+		testApps(testInstance(t, "a", fargo.OUTOFSERVICE), testInstance(t, "b", fargo.UP)),
+		testApps(),
+	}
+
+	var mtx sync.Mutex
+	var stage int
+
+	conn := new(mock.FargoConnection)
+	conn.GetAppsStub = func() (map[string]*fargo.Application, error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return stages[stage], nil
+	}
+
+	next := func() {
+		mtx.Lock()
+		stage++
+		mtx.Unlock()
+	}
+
+	w := newWatcher(conn, time.Millisecond*10, false, registry.WatchService("foo"))
+	defer w.Stop()
+
+	// the current instances aren't sent
+	time.Sleep(time.Millisecond * 50)
+
+	next()
+	expectResult(t, w, "create", "b")
+
+	// out of service instances are deleted
+	next()
+	expectResult(t, w, "delete", "a")
+
+	// the removed app is deleted
+	next()
+	expectResult(t, w, "delete", "b")
+}
+
+type deltaConn struct {
+	*mock.FargoConnection
+
+	sync.Mutex
+	delta *appsDelta
+}
+
+func (d *deltaConn) GetAppsDelta() (*appsDelta, error) {
+	d.Lock()
+	defer d.Unlock()
+	return d.delta, nil
+}
+
+func (d *deltaConn) setDelta(delta *appsDelta) {
+	d.Lock()
+	d.delta = delta
+	d.Unlock()
+}
+
+func TestWatcherDelta(t *testing.T) {
+	conn := &deltaConn{
+		FargoConnection: new(mock.FargoConnection),
+		delta:           &appsDelta{Hashcode: "UP_1_"},
+	}
+	conn.GetAppsReturns(testApps(testInstance(t, "a", fargo.UP)), nil)
+
+	w := newWatcher(conn, time.Millisecond*10, false)
+	defer w.Stop()
+
+	conn.setDelta(&appsDelta{
+		Hashcode: "UP_2_",
+		Changes: []*instanceChange{
+			{App: "FOO", Action: actionAdded, Instance: testInstance(t, "b", fargo.UP)},
+		},
+	})
+	expectResult(t, w, "create", "b")
+
+	// the apps are only fetched once while the delta adds up
+	time.Sleep(time.Millisecond * 50)
+	if n := conn.GetAppsCallCount(); n != 1 {
+		t.Fatalf("expected 1 call to GetApps got %d", n)
+	}
+
+	conn.setDelta(&appsDelta{
+		Hashcode: "UP_1_",
+		Changes: []*instanceChange{
+			{App: "FOO", Action: actionDeleted, Instance: testInstance(t, "a", fargo.UP)},
+		},
+	})
+	expectResult(t, w, "delete", "a")
+}
+
+func TestChanged(t *testing.T) {
+	old := testInstance(t, "a", fargo.UP)
+	// the metadata of the old instance has been read
+	appToService(&fargo.Application{Name: "FOO", Instances: []*fargo.Instance{old}}, false)
+
+	if changed(old, testInstance(t, "a", fargo.UP)) {
+		t.Fatal("expected the instance to be unchanged")
+	}
+
+	instance, err := serviceToInstance(&registry.Service{
+		Name:    "foo",
+		Version: "2.0.0",
+		Nodes:   []*registry.Node{{Id: "a", Address: "10.0.0.1:8080"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	instance.Status = fargo.UP
+	if !changed(old, instance) {
+		t.Fatal("expected the version change to be seen")
+	}
+}
+
+func TestParseAppsDelta(t *testing.T) {
+	// the second instance has no port and can't be decoded
+	b := []byte(`{
+		"applications": {
+			"versions__delta": "3",
+			"apps__hashcode": "DOWN_1_UP_2_",
+			"application": {
+				"name": "FOO",
+				"instance": [{
+					"instanceId": "10.0.0.1:8080:c",
+					"hostName": "10.0.0.1",
+					"app": "FOO",
+					"ipAddr": "10.0.0.1",
+					"status": "DOWN",
+					"overriddenstatus": "UNKNOWN",
+					"port": {"$": 8080, "@enabled": "true"},
+					"securePort": {"$": 443, "@enabled": "false"},
+					"countryId": 1,
+					"dataCenterInfo": {
+						"@class": "com.netflix.appinfo.InstanceInfo$DefaultDataCenterInfo",
+						"name": "MyOwn"
+					},
+					"metadata": {"version": "1.0.0", "instanceId": "c"},
+					"vipAddress": "foo",
+					"actionType": "MODIFIED"
+				}, {
+					"instanceId": "10.0.0.2:8080:d",
+					"hostName": "10.0.0.2",
+					"app": "FOO",
+					"status": "UP",
+					"actionType": "ADDED"
+				}]
+			}
+		}
+	}`)
+
+	delta, err := parseAppsDelta(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if delta.Hashcode != "DOWN_1_UP_2_" {
+		t.Fatalf("expected hashcode DOWN_1_UP_2_ got %s", delta.Hashcode)
+	}
+	if len(delta.Changes) != 1 {
+		t.Fatalf("expected 1 change got %d", len(delta.Changes))
+	}
+
+	c := delta.Changes[0]
+	if c.App != "FOO" || c.Action != actionModified || c.Instance.Status != fargo.DOWN || c.Instance.Port != 8080 {
+		t.Fatalf("unexpected change %s %s %s %d", c.App, c.Action, c.Instance.Status, c.Instance.Port)
+	}
+	if v, _ := c.Instance.Metadata.GetString("version"); v != "1.0.0" {
+		t.Fatalf("expected version 1.0.0 got %s", v)
+	}
+
+	apps := map[string]map[string]*fargo.Instance{
+		"FOO": {
+			"a": testInstance(t, "a", fargo.UP),
+			"b": testInstance(t, "b", fargo.UP),
+			"c": c.Instance,
+		},
+	}
+	if h := hashcode(apps); h != delta.Hashcode {
+		t.Fatalf("expected hashcode %s got %s", delta.Hashcode, h)
+	}
+}
@@ -7,5 +7,4 @@ require (
 	github.com/google/uuid v1.1.1
 	github.com/hashicorp/memberlist v0.1.5
 	github.com/micro/go-micro/v2 v2.9.1
-	github.com/mitchellh/hashstructure v1.0.0
 )
@@ -3,6 +3,7 @@ package gossip
 
 import (
 	"context"
+	"crypto/ed25519"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -21,7 +22,6 @@ import (
 	"github.com/micro/go-micro/v2/registry"
 	regutil "github.com/micro/go-micro/v2/util/registry"
 	pb "github.com/micro/go-plugins/registry/gossip/v2/proto"
-	"github.com/mitchellh/hashstructure"
 )
 
 // use registry.Result int32 values after it switches from string to int32 types
@@ -60,6 +60,7 @@ func actionTypeString(t int32) string {
 const (
 	updateTypeInvalid int32 = iota
 	updateTypeService
+	updateTypeKey
 )
 
 type broadcast struct {
@@ -68,17 +69,25 @@ type broadcast struct {
 }
 
 type delegate struct {
-	queue   *memberlist.TransmitLimitedQueue
-	updates chan *update
+	queue    *memberlist.TransmitLimitedQueue
+	registry *gossipRegistry
 }
 
 type event struct {
 	action int32
 	node   string
+	name   string
 }
 
 type eventDelegate struct {
-	events chan *event
+	pipeline *pipeline
+}
+
+// digest is the local state exchanged during anti-entropy, the version
+// of each entry so the other member can send the entries it's missing
+type digest struct {
+	Node     string            `json:"node"`
+	Versions map[string]uint64 `json:"versions"`
 }
 
 func init() {
@@ -86,19 +95,19 @@ func init() {
 }
 
 func (ed *eventDelegate) NotifyJoin(n *memberlist.Node) {
-	ed.events <- &event{action: nodeActionJoin, node: n.Address()}
+	ed.pipeline.pushEvent(&event{action: nodeActionJoin, node: n.Address(), name: n.Name})
 }
 func (ed *eventDelegate) NotifyLeave(n *memberlist.Node) {
-	ed.events <- &event{action: nodeActionLeave, node: n.Address()}
+	ed.pipeline.pushEvent(&event{action: nodeActionLeave, node: n.Address(), name: n.Name})
 }
 func (ed *eventDelegate) NotifyUpdate(n *memberlist.Node) {
-	ed.events <- &event{action: nodeActionUpdate, node: n.Address()}
+	ed.pipeline.pushEvent(&event{action: nodeActionUpdate, node: n.Address(), name: n.Name})
 }
 
 type gossipRegistry struct {
 	queue       *memberlist.TransmitLimitedQueue
-	updates     chan *update
-	events      chan *event
+	pipeline    *pipeline
+	state       *state
 	options     registry.Options
 	member      *memberlist.Memberlist
 	interval    time.Duration
@@ -109,29 +118,34 @@ type gossipRegistry struct {
 	sync.RWMutex
 	services map[string][]*registry.Service
 
+	// encryption keys, nil if not encrypted
+	keyring *memberlist.Keyring
+	// node identity and signing of updates
+	identity ed25519.PrivateKey
+	sign     bool
+	trusted  map[string]bool
+	// identity owning each service node
+	owners map[string]string
+
 	watchers map[string]chan *registry.Result
 
 	mtu     int
 	addrs   []string
 	members map[string]int32
-	done    chan bool
+	// state of each member by name
+	nodes map[string]*Member
+	done  chan bool
 }
 
 type update struct {
 	Update  *pb.Update
 	Service *registry.Service
-	sync    chan *registry.Service
-}
-
-type updates struct {
-	sync.RWMutex
-	services map[uint64]*update
+	// Identity which signed the update
+	Identity string
 }
 
 var (
-	// You should change this if using secure
-	DefaultSecret = []byte("micro-gossip-key") // exactly 16 bytes
-	ExpiryTick    = time.Second * 1            // needs to be smaller than registry.RegisterTTL
+	ExpiryTick    = time.Second * 1 // needs to be smaller than registry.RegisterTTL
 	MaxPacketSize = 512
 )
 
@@ -158,6 +172,12 @@ func configure(g *gossipRegistry, opts ...registry.Option) error {
 	// new address list
 	newAddrs := addrs(g.options.Addrs)
 
+	// the keyring if encrypted
+	keyring, err := newKeyring(g.options)
+	if err != nil {
+		return err
+	}
+
 	// no new nodes and existing member. no configure
 	if (len(newAddrs) == len(curAddrs)) && g.member != nil {
 		return nil
@@ -221,14 +241,29 @@ func configure(g *gossipRegistry, opts ...registry.Option) error {
 	// set the name
 	c.Name = strings.Join([]string{"micro", hostname, uuid.New().String()}, "-")
 
-	// set a secret key if secure
-	if g.options.Secure {
-		k, ok := g.options.Context.Value(secretKey{}).([]byte)
-		if !ok {
-			// use the default secret
-			k = DefaultSecret
+	// set the keyring if encrypted
+	if keyring != nil {
+		c.Keyring = keyring
+	}
+
+	// set the node identity
+	if k, ok := g.options.Context.Value(identityKey{}).(ed25519.PrivateKey); ok && len(k) == ed25519.PrivateKeySize {
+		g.identity = k
+	} else if g.identity == nil {
+		g.identity = newIdentity()
+	}
+
+	// set signing of updates
+	if v, ok := g.options.Context.Value(signKey{}).(bool); ok {
+		g.sign = v
+	}
+
+	// set trusted identities
+	if keys, ok := g.options.Context.Value(trustedKey{}).([]ed25519.PublicKey); ok {
+		g.trusted = make(map[string]bool)
+		for _, k := range keys {
+			g.trusted[identityString(k)] = true
 		}
-		c.SecretKey = k
 	}
 
 	// set connect retry
@@ -241,6 +276,11 @@ func configure(g *gossipRegistry, opts ...registry.Option) error {
 		g.connectTimeout = td
 	}
 
+	// set the anti-entropy interval
+	if td, ok := g.options.Context.Value(syncIntervalKey{}).(time.Duration); ok {
+		c.PushPullInterval = td
+	}
+
 	// create a queue
 	queue := &memberlist.TransmitLimitedQueue{
 		NumNodes: func() int {
@@ -251,18 +291,19 @@ func configure(g *gossipRegistry, opts ...registry.Option) error {
 
 	// set the delegate
 	c.Delegate = &delegate{
-		updates: g.updates,
-		queue:   queue,
+		queue:    queue,
+		registry: g,
 	}
 
-	if g.connectRetry {
-		c.Events = &eventDelegate{
-			events: g.events,
-		}
+	// track member events
+	c.Events = &eventDelegate{
+		pipeline: g.pipeline,
 	}
+
 	// create the memberlist
 	m, err := memberlist.Create(c)
 	if err != nil {
+		g.Unlock()
 		return err
 	}
 
@@ -277,10 +318,13 @@ func configure(g *gossipRegistry, opts ...registry.Option) error {
 	g.queue = queue
 	g.member = m
 	g.interval = c.GossipInterval
+	// memberlist creates a keyring for a config secret key
+	g.keyring = c.Keyring
 
 	g.Unlock()
 
 	log.Infof("[gossip] Registry Listening on %s", m.LocalNode().Address())
+	log.Infof("[gossip] Registry identity %s", identityString(g.identity.Public().(ed25519.PublicKey)))
 
 	// try connect
 	return g.connect(curAddrs)
@@ -318,35 +362,46 @@ func (d *delegate) NotifyMsg(b []byte) {
 		return
 	}
 
-	go func() {
-		up := new(pb.Update)
-		if err := proto.Unmarshal(b, up); err != nil {
-			return
-		}
+	up := new(pb.Update)
+	if err := proto.Unmarshal(b, up); err != nil {
+		return
+	}
 
-		// only process service action
-		if up.Type != updateTypeService {
-			return
-		}
+	// key changes
+	if up.Type == updateTypeKey {
+		d.registry.handleKey(up)
+		return
+	}
 
-		var service *registry.Service
+	// only process service action
+	if up.Type != updateTypeService {
+		return
+	}
 
-		switch up.Metadata["Content-Type"] {
-		case "application/json":
-			if err := json.Unmarshal(up.Data, &service); err != nil {
-				return
-			}
-		// no other content type
-		default:
+	identity, err := d.registry.verify(up)
+	if err != nil {
+		log.Debugf("[gossip] Registry rejected update: %v", err)
+		return
+	}
+
+	var service *registry.Service
+
+	switch up.Metadata["Content-Type"] {
+	case "application/json":
+		if err := json.Unmarshal(up.Data, &service); err != nil {
 			return
 		}
+	// no other content type
+	default:
+		return
+	}
 
-		// send update
-		d.updates <- &update{
-			Update:  up,
-			Service: service,
-		}
-	}()
+	// send update
+	d.registry.pipeline.push(&update{
+		Update:   up,
+		Service:  service,
+		Identity: identity,
+	})
 }
 
 func (d *delegate) GetBroadcasts(overhead, limit int) [][]byte {
@@ -354,25 +409,18 @@ func (d *delegate) GetBroadcasts(overhead, limit int) [][]byte {
 }
 
 func (d *delegate) LocalState(join bool) []byte {
-	if !join {
-		return []byte{}
-	}
-
-	syncCh := make(chan *registry.Service, 1)
-	services := map[string][]*registry.Service{}
-
-	d.updates <- &update{
-		Update: &pb.Update{
-			Action: actionTypeSync,
-		},
-		sync: syncCh,
-	}
+	d.registry.RLock()
+	member := d.registry.member
+	d.registry.RUnlock()
 
-	for srv := range syncCh {
-		services[srv.Name] = append(services[srv.Name], srv)
+	if member == nil {
+		return []byte{}
 	}
 
-	b, _ := json.Marshal(services)
+	b, _ := json.Marshal(&digest{
+		Node:     member.LocalNode().Name,
+		Versions: d.registry.state.digest(),
+	})
 	return b
 }
 
@@ -380,7 +428,22 @@ func (d *delegate) MergeRemoteState(buf []byte, join bool) {
 	if len(buf) == 0 {
 		return
 	}
-	if !join {
+
+	var dg digest
+	if err := json.Unmarshal(buf, &dg); err != nil || len(dg.Node) == 0 {
+		// full state from a member without anti-entropy
+		d.mergeServices(buf)
+		return
+	}
+
+	// send the member the entries it's missing
+	go d.registry.sendDelta(dg)
+}
+
+// mergeServices merges the full service state of a member
+func (d *delegate) mergeServices(buf []byte) {
+	// unsigned services can't be verified
+	if d.registry.signing() {
 		return
 	}
 
@@ -390,11 +453,43 @@ func (d *delegate) MergeRemoteState(buf []byte, join bool) {
 	}
 	for _, service := range services {
 		for _, srv := range service {
-			d.updates <- &update{
+			d.registry.pipeline.push(&update{
 				Update:  &pb.Update{Action: actionTypeCreate},
 				Service: srv,
-				sync:    nil,
-			}
+			})
+		}
+	}
+}
+
+// sendDelta sends a member the updates newer than those in its digest
+func (g *gossipRegistry) sendDelta(dg digest) {
+	g.RLock()
+	member := g.member
+	g.RUnlock()
+
+	if member == nil || dg.Node == member.LocalNode().Name {
+		return
+	}
+
+	var node *memberlist.Node
+	for _, n := range member.Members() {
+		if n.Name == dg.Node {
+			node = n
+			break
+		}
+	}
+	if node == nil {
+		return
+	}
+
+	for _, up := range g.state.delta(dg.Versions) {
+		b, err := proto.Marshal(up)
+		if err != nil {
+			continue
+		}
+		if err := member.SendReliable(node, b); err != nil {
+			log.Debugf("[gossip] Registry anti-entropy with %s failed: %v", node.Name, err)
+			return
 		}
 	}
 }
@@ -549,69 +644,37 @@ func (g *gossipRegistry) connectLoop() {
 	}
 }
 
-func (g *gossipRegistry) expiryLoop(updates *updates) {
+// expiryLoop removes the services past their expiry
+func (g *gossipRegistry) expiryLoop() {
 	ticker := time.NewTicker(ExpiryTick)
 	defer ticker.Stop()
 
-	g.RLock()
-	done := g.done
-	g.RUnlock()
-
-	for {
-		select {
-		case <-done:
-			return
-		case <-ticker.C:
-			now := uint64(time.Now().UnixNano())
-
-			updates.Lock()
-
-			// process all the updates
-			for k, v := range updates.services {
-				// check if expiry time has passed
-				if d := (v.Update.Expires); d < now {
-					// delete from records
-					delete(updates.services, k)
-					// set to delete
-					v.Update.Action = actionTypeDelete
-					// fire a new update
-					g.updates <- v
-				}
-			}
-
-			updates.Unlock()
+	for range ticker.C {
+		for _, e := range g.state.expire(uint64(time.Now().UnixNano())) {
+			g.expire(e.service, e.identity)
 		}
 	}
 }
 
 // process member events
 func (g *gossipRegistry) eventLoop() {
-	g.RLock()
-	done := g.done
-	g.RUnlock()
-	for {
-		select {
-		// return when done
-		case <-done:
-			return
-		case ev := <-g.events:
-			// TODO: nonblocking update
-			g.Lock()
+	for range g.pipeline.signal {
+		events := g.pipeline.pullEvents()
+
+		g.Lock()
+		for _, ev := range events {
 			if _, ok := g.members[ev.node]; ok {
 				g.members[ev.node] = ev.action
 			}
-			g.Unlock()
+			g.updateMember(ev)
 		}
+		g.Unlock()
 	}
 }
 
 func (g *gossipRegistry) run() {
-	updates := &updates{
-		services: make(map[uint64]*update),
-	}
-
 	// expiry loop
-	go g.expiryLoop(updates)
+	go g.expiryLoop()
 
 	// event loop
 	go g.eventLoop()
@@ -624,76 +687,114 @@ func (g *gossipRegistry) run() {
 	g.RUnlock()
 
 	// process the updates
-	for u := range g.updates {
-		switch u.Update.Action {
-		case actionTypeCreate:
-			g.Lock()
-			if service, ok := g.services[u.Service.Name]; !ok {
-				g.services[u.Service.Name] = []*registry.Service{u.Service}
+	for range g.pipeline.notify {
+		for _, u := range g.pipeline.pull() {
+			g.process(u)
+		}
+	}
+}
 
-			} else {
-				g.services[u.Service.Name] = regutil.Merge(service, []*registry.Service{u.Service})
-			}
-			g.Unlock()
+// process applies an update unless it's superseded, expired or from
+// an identity which doesn't own the service nodes
+func (g *gossipRegistry) process(u *update) {
+	version, origin := updateVersion(u.Update)
 
-			// publish update to watchers
-			go g.publish(actionTypeString(actionTypeCreate), []*registry.Service{u.Service})
+	e := &entry{
+		key:      entryKey(u.Service),
+		update:   u.Update,
+		service:  u.Service,
+		identity: u.Identity,
+		origin:   origin,
+		version:  version,
+	}
 
-			// we need to expire the node at some point in the future
-			if u.Update.Expires > 0 {
-				// create a hash of this service
-				if hash, err := hashstructure.Hash(u.Service, nil); err == nil {
-					updates.Lock()
-					updates.services[hash] = u
-					updates.Unlock()
-				}
-			}
-		case actionTypeDelete:
-			g.Lock()
-			if service, ok := g.services[u.Service.Name]; ok {
-				if services := regutil.Remove(service, []*registry.Service{u.Service}); len(services) == 0 {
-					delete(g.services, u.Service.Name)
-				} else {
-					g.services[u.Service.Name] = services
-				}
-			}
+	switch u.Update.Action {
+	case actionTypeCreate:
+		// we need to expire the node at some point in the future
+		if e.deadline = u.Update.Expires; e.deadline > 0 && e.deadline < uint64(time.Now().UnixNano()) {
+			return
+		}
+
+		g.Lock()
+		// only the owner can announce a node
+		if !g.owns(u.Service, u.Identity) {
 			g.Unlock()
+			log.Debugf("[gossip] Registry rejected %s from %s: %v", u.Service.Name, u.Identity, ErrNotOwner)
+			return
+		}
+		if !g.state.apply(e) {
+			g.Unlock()
+			return
+		}
+		g.claim(u.Service, u.Identity)
+		if service, ok := g.services[u.Service.Name]; !ok {
+			g.services[u.Service.Name] = []*registry.Service{u.Service}
 
-			// publish update to watchers
-			go g.publish(actionTypeString(actionTypeDelete), []*registry.Service{u.Service})
+		} else {
+			g.services[u.Service.Name] = regutil.Merge(service, []*registry.Service{u.Service})
+		}
+		g.Unlock()
 
-			// delete from expiry checks
-			if hash, err := hashstructure.Hash(u.Service, nil); err == nil {
-				updates.Lock()
-				delete(updates.services, hash)
-				updates.Unlock()
-			}
-		case actionTypeSync:
-			// no sync channel provided
-			if u.sync == nil {
-				continue
-			}
+		// publish update to watchers
+		go g.publish(actionTypeString(actionTypeCreate), []*registry.Service{u.Service})
+	case actionTypeDelete:
+		// keep a tombstone so older updates aren't applied
+		e.deadline = uint64(time.Now().Add(TombstoneTTL).UnixNano())
 
-			g.RLock()
+		g.Lock()
+		// only the owner can remove a node
+		if !g.owns(u.Service, u.Identity) {
+			g.Unlock()
+			log.Debugf("[gossip] Registry rejected %s from %s: %v", u.Service.Name, u.Identity, ErrNotOwner)
+			return
+		}
+		if !g.state.apply(e) {
+			g.Unlock()
+			return
+		}
+		g.release(u.Service, u.Identity)
+		g.removeService(u.Service)
+		g.Unlock()
 
-			// push all services through the sync chan
-			for _, service := range g.services {
-				for _, srv := range service {
-					u.sync <- srv
-				}
+		// publish update to watchers
+		go g.publish(actionTypeString(actionTypeDelete), []*registry.Service{u.Service})
+	}
+}
 
-				// publish to watchers
-				go g.publish(actionTypeString(actionTypeCreate), service)
-			}
+// expire removes the nodes of an expired service
+func (g *gossipRegistry) expire(s *registry.Service, identity string) {
+	g.Lock()
+	g.release(s, identity)
+	g.removeService(s)
+	g.Unlock()
 
-			g.RUnlock()
+	// publish update to watchers
+	go g.publish(actionTypeString(actionTypeDelete), []*registry.Service{s})
+}
 
-			// close the sync chan
-			close(u.sync)
+// removeService removes the nodes of a service, it must be called with the lock held
+func (g *gossipRegistry) removeService(s *registry.Service) {
+	if service, ok := g.services[s.Name]; ok {
+		if services := regutil.Remove(service, []*registry.Service{s}); len(services) == 0 {
+			delete(g.services, s.Name)
+		} else {
+			g.services[s.Name] = services
 		}
 	}
 }
 
+// stamp versions an update
+func (g *gossipRegistry) stamp(up *pb.Update) {
+	g.RLock()
+	member := g.member
+	g.RUnlock()
+
+	if member != nil {
+		up.Metadata[metadataOrigin] = member.LocalNode().Name
+	}
+	up.Metadata[metadataVersion] = strconv.FormatUint(g.state.next(), 10)
+}
+
 func (g *gossipRegistry) Init(opts ...registry.Option) error {
 	return configure(g, opts...)
 }
@@ -710,7 +811,13 @@ func (g *gossipRegistry) Register(s *registry.Service, opts ...registry.Register
 		return err
 	}
 
+	id := g.id()
+
 	g.Lock()
+	if !g.claim(s, id) {
+		g.Unlock()
+		return ErrNotOwner
+	}
 	if service, ok := g.services[s.Name]; !ok {
 		g.services[s.Name] = []*registry.Service{s}
 	} else {
@@ -728,14 +835,19 @@ func (g *gossipRegistry) Register(s *registry.Service, opts ...registry.Register
 	}
 
 	up := &pb.Update{
-		Expires: uint64(time.Now().Add(options.TTL).UnixNano()),
-		Action:  actionTypeCreate,
-		Type:    updateTypeService,
+		Action: actionTypeCreate,
+		Type:   updateTypeService,
 		Metadata: map[string]string{
 			"Content-Type": "application/json",
 		},
 		Data: b,
 	}
+	// without a ttl the service is kept until deregistered
+	if options.TTL > 0 {
+		up.Expires = uint64(time.Now().Add(options.TTL).UnixNano())
+	}
+	g.stamp(up)
+	g.signUpdate(up)
 
 	g.queue.QueueBroadcast(&broadcast{
 		update: up,
@@ -743,10 +855,11 @@ func (g *gossipRegistry) Register(s *registry.Service, opts ...registry.Register
 	})
 
 	// send update to local watchers
-	g.updates <- &update{
-		Update:  up,
-		Service: s,
-	}
+	g.pipeline.push(&update{
+		Update:   up,
+		Service:  s,
+		Identity: id,
+	})
 
 	// wait
 	<-time.After(g.interval * 2)
@@ -763,14 +876,14 @@ func (g *gossipRegistry) Deregister(s *registry.Service, opts ...registry.Deregi
 		return err
 	}
 
+	id := g.id()
+
 	g.Lock()
-	if service, ok := g.services[s.Name]; ok {
-		if services := regutil.Remove(service, []*registry.Service{s}); len(services) == 0 {
-			delete(g.services, s.Name)
-		} else {
-			g.services[s.Name] = services
-		}
+	if !g.release(s, id) {
+		g.Unlock()
+		return ErrNotOwner
 	}
+	g.removeService(s)
 	g.Unlock()
 
 	up := &pb.Update{
@@ -781,6 +894,8 @@ func (g *gossipRegistry) Deregister(s *registry.Service, opts ...registry.Deregi
 		},
 		Data: b,
 	}
+	g.stamp(up)
+	g.signUpdate(up)
 
 	g.queue.QueueBroadcast(&broadcast{
 		update: up,
@@ -788,10 +903,11 @@ func (g *gossipRegistry) Deregister(s *registry.Service, opts ...registry.Deregi
 	})
 
 	// send update to local watchers
-	g.updates <- &update{
-		Update:  up,
-		Service: s,
-	}
+	g.pipeline.push(&update{
+		Update:   up,
+		Service:  s,
+		Identity: id,
+	})
 
 	// wait
 	<-time.After(g.interval * 2)
@@ -819,6 +935,24 @@ func (g *gossipRegistry) ListServices(opts ...registry.ListOption) ([]*registry.
 	return services, nil
 }
 
+// QueryServices returns the services held in memory, the query is
+// filtered in process. It implements the Querier interface of registry/query.
+func (g *gossipRegistry) QueryServices(name, version string, metadata map[string]string) ([]*registry.Service, error) {
+	var services []*registry.Service
+
+	g.RLock()
+	if len(name) > 0 {
+		services = append(services, g.services[name]...)
+	} else {
+		for _, service := range g.services {
+			services = append(services, service...)
+		}
+	}
+	g.RUnlock()
+
+	return services, nil
+}
+
 func (g *gossipRegistry) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
 	n, e := g.subscribe()
 	return newGossipWatcher(n, e, opts...)
@@ -834,11 +968,13 @@ func NewRegistry(opts ...registry.Option) registry.Registry {
 			Context: context.Background(),
 		},
 		done:     make(chan bool),
-		events:   make(chan *event, 100),
-		updates:  make(chan *update, 100),
+		pipeline: newPipeline(),
+		state:    newState(),
 		services: make(map[string][]*registry.Service),
 		watchers: make(map[string]chan *registry.Result),
 		members:  make(map[string]int32),
+		nodes:    make(map[string]*Member),
+		owners:   make(map[string]string),
 	}
 	// run the updater
 	go g.run()
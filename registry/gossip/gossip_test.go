@@ -1,7 +1,10 @@
 package gossip
 
 import (
+	"context"
+	"crypto/ed25519"
 	"os"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -9,6 +12,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/hashicorp/memberlist"
 	"github.com/micro/go-micro/v2/registry"
+	pb "github.com/micro/go-plugins/registry/gossip/v2/proto"
 )
 
 func newMemberlistConfig() *memberlist.Config {
@@ -214,3 +218,316 @@ func TestGossipRegistryRetry(t *testing.T) {
 	r1.(*gossipRegistry).Stop()
 	r2.(*gossipRegistry).Stop()
 }
+
+func newSigningRegistry(opts ...registry.Option) *gossipRegistry {
+	g := &gossipRegistry{
+		identity: newIdentity(),
+		sign:     true,
+		owners:   make(map[string]string),
+	}
+	g.options.Context = context.Background()
+	for _, o := range opts {
+		o(&g.options)
+	}
+	if keys, ok := g.options.Context.Value(trustedKey{}).([]ed25519.PublicKey); ok {
+		g.trusted = make(map[string]bool)
+		for _, k := range keys {
+			g.trusted[identityString(k)] = true
+		}
+	}
+	return g
+}
+
+func TestGossipRegistrySignature(t *testing.T) {
+	g1 := newSigningRegistry()
+	g2 := newSigningRegistry()
+
+	up := &pb.Update{
+		Expires: uint64(time.Now().UnixNano()),
+		Action:  actionTypeCreate,
+		Type:    updateTypeService,
+		Data:    []byte(`{"name":"service.1"}`),
+	}
+	g1.signUpdate(up)
+
+	id, err := g2.verify(up)
+	if err != nil {
+		t.Fatalf("expected valid signature: %v", err)
+	}
+	if id != g1.id() {
+		t.Fatalf("expected identity %s got %s", g1.id(), id)
+	}
+
+	// tampering breaks the signature
+	up.Action = actionTypeDelete
+	if _, err := g2.verify(up); err != ErrInvalidSignature {
+		t.Fatalf("expected invalid signature got %v", err)
+	}
+
+	// unsigned updates are rejected
+	if _, err := g2.verify(&pb.Update{Type: updateTypeService}); err != ErrInvalidSignature {
+		t.Fatalf("expected invalid signature got %v", err)
+	}
+
+	// only trusted identities are accepted
+	g3 := newSigningRegistry(Trusted(g2.identity.Public().(ed25519.PublicKey)))
+	up.Action = actionTypeCreate
+	if _, err := g3.verify(up); err != ErrUntrusted {
+		t.Fatalf("expected untrusted identity got %v", err)
+	}
+}
+
+func TestGossipRegistryOwnership(t *testing.T) {
+	g := newSigningRegistry()
+
+	svc := &registry.Service{
+		Name:  "service.1",
+		Nodes: []*registry.Node{{Id: "node-1"}},
+	}
+
+	if !g.claim(svc, "a") {
+		t.Fatal("expected first identity to claim the node")
+	}
+	if !g.claim(svc, "a") {
+		t.Fatal("expected owner to announce the node again")
+	}
+	if g.claim(svc, "b") {
+		t.Fatal("expected another identity to be rejected")
+	}
+	if g.release(svc, "b") {
+		t.Fatal("expected another identity not to remove the node")
+	}
+	if !g.release(svc, "a") {
+		t.Fatal("expected owner to remove the node")
+	}
+	if !g.claim(svc, "b") {
+		t.Fatal("expected a released node to be claimed")
+	}
+}
+
+func TestGossipRegistryKeyring(t *testing.T) {
+	key1 := []byte("0123456789abcdef")
+	key2 := []byte("fedcba9876543210")
+
+	// secure requires a key
+	if _, err := newKeyring(registry.Options{Context: context.Background(), Secure: true}); err != ErrNoSecret {
+		t.Fatalf("expected no secret error got %v", err)
+	}
+
+	var opts registry.Options
+	SecretKeys(key1)(&opts)
+
+	keyring, err := newKeyring(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := &gossipRegistry{
+		keyring: keyring,
+		queue:   &memberlist.TransmitLimitedQueue{NumNodes: func() int { return 1 }},
+	}
+
+	// rotate key1 to key2
+	if err := g.InstallKey(key2); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.UseKey(key2); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.RemoveKey(key2); err == nil {
+		t.Fatal("expected the key in use not to be removed")
+	}
+	if err := g.RemoveKey(key1); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := g.ListKeys()
+	if len(keys) != 1 || string(keys[0]) != string(key2) {
+		t.Fatalf("expected only key2 got %q", keys)
+	}
+
+	// without encryption keys can't be managed
+	if err := new(gossipRegistry).InstallKey(key1); err != ErrNoKeyring {
+		t.Fatalf("expected no keyring error got %v", err)
+	}
+}
+
+func newUpdate(action int32, version uint64, origin string, s *registry.Service) *update {
+	return &update{
+		Update: &pb.Update{
+			Action: action,
+			Type:   updateTypeService,
+			Metadata: map[string]string{
+				metadataVersion: strconv.FormatUint(version, 10),
+				metadataOrigin:  origin,
+			},
+		},
+		Service: s,
+	}
+}
+
+func TestGossipRegistryVersions(t *testing.T) {
+	g := &gossipRegistry{
+		pipeline: newPipeline(),
+		state:    newState(),
+		services: make(map[string][]*registry.Service),
+		watchers: make(map[string]chan *registry.Result),
+		nodes:    make(map[string]*Member),
+	}
+
+	svc := &registry.Service{
+		Name:    "service.1",
+		Version: "1",
+		Nodes:   []*registry.Node{{Id: "node-1", Address: "10.0.0.1:8080"}},
+	}
+
+	g.process(newUpdate(actionTypeCreate, 2, "a", svc))
+	if _, err := g.GetService("service.1"); err != nil {
+		t.Fatalf("expected service.1 got %v", err)
+	}
+
+	// an older delete doesn't remove the newer service
+	g.process(newUpdate(actionTypeDelete, 1, "b", svc))
+	if _, err := g.GetService("service.1"); err != nil {
+		t.Fatalf("expected stale delete to be ignored got %v", err)
+	}
+
+	g.process(newUpdate(actionTypeDelete, 3, "a", svc))
+	if _, err := g.GetService("service.1"); err != registry.ErrNotFound {
+		t.Fatalf("expected service.1 to be deleted got %v", err)
+	}
+
+	// the tombstone stops an older create bringing it back
+	g.process(newUpdate(actionTypeCreate, 2, "a", svc))
+	if _, err := g.GetService("service.1"); err != registry.ErrNotFound {
+		t.Fatalf("expected stale create to be ignored got %v", err)
+	}
+
+	status := g.Inspect()
+	if v := status.Services["service.1"]["a"]; v != 3 {
+		t.Fatalf("expected version 3 from a got %d", v)
+	}
+
+	// a member is sent the entries newer than its digest
+	if delta := g.state.delta(map[string]uint64{entryKey(svc): 3}); len(delta) != 0 {
+		t.Fatalf("expected no delta got %d", len(delta))
+	}
+	if delta := g.state.delta(map[string]uint64{}); len(delta) != 1 || delta[0].Action != actionTypeDelete {
+		t.Fatalf("expected the tombstone in the delta got %v", delta)
+	}
+}
+
+func TestGossipRegistryExpiry(t *testing.T) {
+	g := &gossipRegistry{
+		pipeline: newPipeline(),
+		state:    newState(),
+		services: make(map[string][]*registry.Service),
+		watchers: make(map[string]chan *registry.Result),
+		nodes:    make(map[string]*Member),
+	}
+
+	svc := &registry.Service{
+		Name:  "service.1",
+		Nodes: []*registry.Node{{Id: "node-1"}},
+	}
+
+	now := time.Now()
+
+	u := newUpdate(actionTypeCreate, 1, "a", svc)
+	u.Update.Expires = uint64(now.Add(time.Second).UnixNano())
+	g.process(u)
+
+	// refreshed with a later expiry
+	u = newUpdate(actionTypeCreate, 2, "a", svc)
+	u.Update.Expires = uint64(now.Add(time.Minute).UnixNano())
+	g.process(u)
+
+	if expired := g.state.expire(uint64(now.Add(time.Second * 2).UnixNano())); len(expired) != 0 {
+		t.Fatalf("expected refreshed entry not to expire got %d", len(expired))
+	}
+
+	expired := g.state.expire(uint64(now.Add(time.Minute * 2).UnixNano()))
+	if len(expired) != 1 {
+		t.Fatalf("expected 1 expired entry got %d", len(expired))
+	}
+	g.expire(expired[0].service, expired[0].identity)
+
+	if _, err := g.GetService("service.1"); err != registry.ErrNotFound {
+		t.Fatalf("expected service.1 to expire got %v", err)
+	}
+
+	// updates which already expired are dropped
+	g.process(newUpdate(actionTypeCreate, 3, "a", svc))
+	u = newUpdate(actionTypeCreate, 4, "a", svc)
+	u.Update.Expires = uint64(now.Add(-time.Second).UnixNano())
+	g.process(u)
+	if v := g.Inspect().Services["service.1"]["a"]; v != 3 {
+		t.Fatalf("expected version 3 got %d", v)
+	}
+}
+
+func TestGossipRegistryPipeline(t *testing.T) {
+	p := newPipeline()
+
+	depth := MaxQueueDepth
+	MaxQueueDepth = 2
+	defer func() {
+		MaxQueueDepth = depth
+	}()
+
+	for i := 0; i < 3; i++ {
+		p.push(&update{})
+	}
+	if queued, dropped := p.depth(); queued != 2 || dropped != 1 {
+		t.Fatalf("expected 2 queued and 1 dropped got %d and %d", queued, dropped)
+	}
+	if updates := p.pull(); len(updates) != 2 {
+		t.Fatalf("expected 2 updates got %d", len(updates))
+	}
+
+	// member events are coalesced
+	p.pushEvent(&event{name: "a", action: nodeActionJoin})
+	p.pushEvent(&event{name: "b", action: nodeActionJoin})
+	p.pushEvent(&event{name: "a", action: nodeActionLeave})
+
+	events := p.pullEvents()
+	if len(events) != 2 || events[0].name != "a" || events[0].action != nodeActionLeave {
+		t.Fatalf("expected the latest event of each member got %v", events)
+	}
+}
+
+func TestGossipRegistryQuery(t *testing.T) {
+	g := &gossipRegistry{
+		services: map[string][]*registry.Service{
+			"service.1": {
+				{Name: "service.1", Version: "1", Nodes: []*registry.Node{{Id: "1"}}},
+				{Name: "service.1", Version: "2", Nodes: []*registry.Node{{Id: "2"}}},
+			},
+			"service.2": {
+				{Name: "service.2", Version: "1", Nodes: []*registry.Node{{Id: "3"}}},
+			},
+		},
+	}
+
+	services, err := g.QueryServices("", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 3 {
+		t.Fatalf("expected 3 services got %+v", services)
+	}
+
+	services, err = g.QueryServices("service.2", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || services[0].Name != "service.2" {
+		t.Fatalf("expected service.2 got %+v", services)
+	}
+
+	// the services held are not reordered by the query
+	services[0] = nil
+	if g.services["service.2"][0] == nil {
+		t.Fatal("expected the services to be copied")
+	}
+}
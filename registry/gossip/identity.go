@@ -0,0 +1,182 @@
+package gossip
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+
+	"github.com/micro/go-micro/v2/registry"
+	pb "github.com/micro/go-plugins/registry/gossip/v2/proto"
+)
+
+const (
+	// metadata of signed updates
+	metadataIdentity  = "Identity"
+	metadataSignature = "Signature"
+)
+
+var (
+	// ErrInvalidSignature is returned for an update which isn't signed by its identity
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrUntrusted is returned for an update signed by an identity which isn't trusted
+	ErrUntrusted = errors.New("untrusted identity")
+	// ErrNotOwner is returned when announcing a node owned by another identity
+	ErrNotOwner = errors.New("service node is owned by another identity")
+)
+
+// identityString encodes a public key as an identity
+func identityString(pub ed25519.PublicKey) string {
+	return base64.RawURLEncoding.EncodeToString(pub)
+}
+
+// newIdentity generates a new node identity
+func newIdentity() ed25519.PrivateKey {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return priv
+}
+
+// signedPayload is the part of an update covered by the signature
+func signedPayload(up *pb.Update) []byte {
+	version, origin := updateVersion(up)
+	b := make([]byte, 28, 28+len(origin)+len(up.Data))
+	binary.BigEndian.PutUint64(b[0:8], up.Expires)
+	binary.BigEndian.PutUint32(b[8:12], uint32(up.Type))
+	binary.BigEndian.PutUint32(b[12:16], uint32(up.Action))
+	binary.BigEndian.PutUint64(b[16:24], version)
+	binary.BigEndian.PutUint32(b[24:28], uint32(len(origin)))
+	b = append(b, origin...)
+	return append(b, up.Data...)
+}
+
+// signUpdate signs an update with the node identity when signing is enabled
+func (g *gossipRegistry) signUpdate(up *pb.Update) {
+	g.RLock()
+	sign, identity := g.sign, g.identity
+	g.RUnlock()
+
+	if !sign {
+		return
+	}
+
+	if up.Metadata == nil {
+		up.Metadata = make(map[string]string)
+	}
+	up.Metadata[metadataIdentity] = identityString(identity.Public().(ed25519.PublicKey))
+	up.Metadata[metadataSignature] = base64.RawURLEncoding.EncodeToString(ed25519.Sign(identity, signedPayload(up)))
+}
+
+// verify checks the signature of an update when signing is enabled,
+// returning the identity which signed it
+func (g *gossipRegistry) verify(up *pb.Update) (string, error) {
+	g.RLock()
+	sign, trusted := g.sign, g.trusted
+	g.RUnlock()
+
+	if !sign {
+		return "", nil
+	}
+
+	id := up.Metadata[metadataIdentity]
+	pub, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return "", ErrInvalidSignature
+	}
+
+	if len(trusted) > 0 && !trusted[id] {
+		return "", ErrUntrusted
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(up.Metadata[metadataSignature])
+	if err != nil || !ed25519.Verify(ed25519.PublicKey(pub), signedPayload(up), sig) {
+		return "", ErrInvalidSignature
+	}
+
+	return id, nil
+}
+
+// ownerKeys returns the keys nodes of a service are owned by
+func ownerKeys(s *registry.Service) []string {
+	if len(s.Nodes) == 0 {
+		return []string{s.Name + "@" + s.Version}
+	}
+
+	keys := make([]string, 0, len(s.Nodes))
+	for _, node := range s.Nodes {
+		keys = append(keys, s.Name+"/"+node.Id)
+	}
+	return keys
+}
+
+// claim records identity as the owner of the nodes of a service, the first
+// identity to announce a node owns it until the node is deleted or expires.
+// It returns false if any of the nodes are owned by another identity. It
+// must be called with the lock held.
+func (g *gossipRegistry) claim(s *registry.Service, identity string) bool {
+	if !g.sign {
+		return true
+	}
+
+	if !g.owns(s, identity) {
+		return false
+	}
+
+	for _, k := range ownerKeys(s) {
+		g.owners[k] = identity
+	}
+	return true
+}
+
+// release removes identity as the owner of the nodes of a service. It
+// returns false if any of the nodes are owned by another identity. It
+// must be called with the lock held.
+func (g *gossipRegistry) release(s *registry.Service, identity string) bool {
+	if !g.sign {
+		return true
+	}
+
+	if !g.owns(s, identity) {
+		return false
+	}
+
+	for _, k := range ownerKeys(s) {
+		delete(g.owners, k)
+	}
+	return true
+}
+
+// owns returns false if any of the nodes of a service are owned by
+// another identity. It must be called with the lock held.
+func (g *gossipRegistry) owns(s *registry.Service, identity string) bool {
+	if !g.sign {
+		return true
+	}
+
+	for _, k := range ownerKeys(s) {
+		if owner, ok := g.owners[k]; ok && owner != identity {
+			return false
+		}
+	}
+	return true
+}
+
+// id returns the identity of this node if updates are signed
+func (g *gossipRegistry) id() string {
+	g.RLock()
+	defer g.RUnlock()
+	if !g.sign {
+		return ""
+	}
+	return identityString(g.identity.Public().(ed25519.PublicKey))
+}
+
+// signing returns true if updates are signed
+func (g *gossipRegistry) signing() bool {
+	g.RLock()
+	defer g.RUnlock()
+	return g.sign
+}
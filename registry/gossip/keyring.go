@@ -0,0 +1,143 @@
+package gossip
+
+import (
+	"errors"
+
+	"github.com/hashicorp/memberlist"
+	log "github.com/micro/go-micro/v2/logger"
+	"github.com/micro/go-micro/v2/registry"
+	pb "github.com/micro/go-plugins/registry/gossip/v2/proto"
+)
+
+var (
+	// ErrNoSecret is returned when the registry is secure without a secret key
+	ErrNoSecret = errors.New("secure gossip requires a secret key")
+	// ErrNoKeyring is returned when managing keys without encryption enabled
+	ErrNoKeyring = errors.New("gossip encryption is not enabled")
+)
+
+// KeyManager manages the keys used to encrypt gossip. Changes are made
+// locally and broadcast to the rest of the cluster. To rotate keys install
+// the new key, use it once it has spread and then remove the old key.
+//
+//	km := r.(gossip.KeyManager)
+//	km.InstallKey(newKey)
+//	km.UseKey(newKey)
+//	km.RemoveKey(oldKey)
+type KeyManager interface {
+	// InstallKey adds a key used to decrypt messages
+	InstallKey(key []byte) error
+	// UseKey sets the installed key used to encrypt messages
+	UseKey(key []byte) error
+	// RemoveKey removes a key, the key in use can't be removed
+	RemoveKey(key []byte) error
+	// ListKeys returns the installed keys, the key in use first
+	ListKeys() [][]byte
+}
+
+// newKeyring returns the keyring for the secret keys in the options, or
+// nil if there are none. The first key is used to encrypt messages.
+func newKeyring(opts registry.Options) (*memberlist.Keyring, error) {
+	var keys [][]byte
+
+	if k, ok := opts.Context.Value(secretKey{}).([]byte); ok && len(k) > 0 {
+		keys = append(keys, k)
+	}
+	if k, ok := opts.Context.Value(secretKeysKey{}).([][]byte); ok {
+		keys = append(keys, k...)
+	}
+
+	if len(keys) == 0 {
+		if opts.Secure {
+			return nil, ErrNoSecret
+		}
+		return nil, nil
+	}
+
+	return memberlist.NewKeyring(keys[1:], keys[0])
+}
+
+// applyKey makes a change to the keyring
+func applyKey(keyring *memberlist.Keyring, action int32, key []byte) error {
+	switch action {
+	case actionTypeCreate:
+		return keyring.AddKey(key)
+	case actionTypeUpdate:
+		return keyring.UseKey(key)
+	case actionTypeDelete:
+		return keyring.RemoveKey(key)
+	}
+	return errors.New("invalid key action")
+}
+
+// changeKey changes the local keyring and broadcasts the change
+func (g *gossipRegistry) changeKey(action int32, key []byte) error {
+	g.RLock()
+	keyring, queue := g.keyring, g.queue
+	g.RUnlock()
+
+	if keyring == nil {
+		return ErrNoKeyring
+	}
+
+	if err := applyKey(keyring, action, key); err != nil {
+		return err
+	}
+
+	up := &pb.Update{
+		Action: action,
+		Type:   updateTypeKey,
+		Data:   key,
+	}
+	g.signUpdate(up)
+
+	queue.QueueBroadcast(&broadcast{
+		update: up,
+		notify: nil,
+	})
+
+	return nil
+}
+
+// handleKey applies a key change broadcast by another member
+func (g *gossipRegistry) handleKey(up *pb.Update) {
+	if _, err := g.verify(up); err != nil {
+		log.Errorf("[gossip] Registry rejected key change: %v", err)
+		return
+	}
+
+	g.RLock()
+	keyring := g.keyring
+	g.RUnlock()
+
+	if keyring == nil {
+		return
+	}
+
+	if err := applyKey(keyring, up.Action, up.Data); err != nil {
+		log.Errorf("[gossip] Registry key change failed: %v", err)
+	}
+}
+
+func (g *gossipRegistry) InstallKey(key []byte) error {
+	return g.changeKey(actionTypeCreate, key)
+}
+
+func (g *gossipRegistry) UseKey(key []byte) error {
+	return g.changeKey(actionTypeUpdate, key)
+}
+
+func (g *gossipRegistry) RemoveKey(key []byte) error {
+	return g.changeKey(actionTypeDelete, key)
+}
+
+func (g *gossipRegistry) ListKeys() [][]byte {
+	g.RLock()
+	keyring := g.keyring
+	g.RUnlock()
+
+	if keyring == nil {
+		return nil
+	}
+	return keyring.GetKeys()
+}
@@ -2,6 +2,7 @@ package gossip
 
 import (
 	"context"
+	"crypto/ed25519"
 	"time"
 
 	"github.com/hashicorp/memberlist"
@@ -14,6 +15,11 @@ type configKey struct{}
 type advertiseKey struct{}
 type connectTimeoutKey struct{}
 type connectRetryKey struct{}
+type secretKeysKey struct{}
+type identityKey struct{}
+type signKey struct{}
+type trustedKey struct{}
+type syncIntervalKey struct{}
 
 // helper for setting registry options
 func setRegistryOption(k, v interface{}) registry.Option {
@@ -31,6 +37,29 @@ func Secret(k []byte) registry.Option {
 	return setRegistryOption(secretKey{}, k)
 }
 
+// SecretKeys installs a keyring of encryption keys. The first key is used to
+// encrypt messages and any of them can decrypt, so keys can be rotated.
+func SecretKeys(keys ...[]byte) registry.Option {
+	return setRegistryOption(secretKeysKey{}, keys)
+}
+
+// Identity sets the key identifying this node, one is generated by default
+func Identity(k ed25519.PrivateKey) registry.Option {
+	return setRegistryOption(identityKey{}, k)
+}
+
+// Sign signs service updates with the node identity. Updates which aren't
+// signed are dropped and a service node can only be announced or removed by
+// the identity which first announced it.
+func Sign(b bool) registry.Option {
+	return setRegistryOption(signKey{}, b)
+}
+
+// Trusted limits signed updates to those from the given identities
+func Trusted(keys ...ed25519.PublicKey) registry.Option {
+	return setRegistryOption(trustedKey{}, keys)
+}
+
 // Address to bind to - host:port
 func Address(a string) registry.Option {
 	return setRegistryOption(addressKey{}, a)
@@ -56,3 +85,11 @@ func ConnectTimeout(td time.Duration) registry.Option {
 func ConnectRetry(v bool) registry.Option {
 	return setRegistryOption(connectRetryKey{}, v)
 }
+
+// SyncInterval sets how often members exchange state to repair missed
+// updates. Only the versions of entries are exchanged and each member is
+// sent the entries it's missing. It's disabled by default and the state
+// is only exchanged when joining.
+func SyncInterval(d time.Duration) registry.Option {
+	return setRegistryOption(syncIntervalKey{}, d)
+}
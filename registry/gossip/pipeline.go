@@ -0,0 +1,96 @@
+package gossip
+
+import (
+	"sync"
+)
+
+var (
+	// MaxQueueDepth is the number of updates queued before they're dropped,
+	// dropped updates are recovered by anti-entropy
+	MaxQueueDepth = 10000
+)
+
+// pipeline queues updates and member events so memberlist callbacks and
+// registry calls never block on the update loop
+type pipeline struct {
+	sync.Mutex
+	updates []*update
+	dropped uint64
+	notify  chan struct{}
+
+	// the latest event of each member
+	events map[string]*event
+	order  []string
+	signal chan struct{}
+}
+
+func newPipeline() *pipeline {
+	return &pipeline{
+		notify: make(chan struct{}, 1),
+		events: make(map[string]*event),
+		signal: make(chan struct{}, 1),
+	}
+}
+
+func wake(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// push queues an update, returning false if the queue is full
+func (p *pipeline) push(u *update) bool {
+	p.Lock()
+	if len(p.updates) >= MaxQueueDepth {
+		p.dropped++
+		p.Unlock()
+		return false
+	}
+	p.updates = append(p.updates, u)
+	p.Unlock()
+
+	wake(p.notify)
+	return true
+}
+
+// pull takes the queued updates
+func (p *pipeline) pull() []*update {
+	p.Lock()
+	defer p.Unlock()
+	updates := p.updates
+	p.updates = nil
+	return updates
+}
+
+// pushEvent queues a member event replacing any pending event of the member
+func (p *pipeline) pushEvent(ev *event) {
+	p.Lock()
+	if _, ok := p.events[ev.name]; !ok {
+		p.order = append(p.order, ev.name)
+	}
+	p.events[ev.name] = ev
+	p.Unlock()
+
+	wake(p.signal)
+}
+
+// pullEvents takes the pending member events in the order they arrived
+func (p *pipeline) pullEvents() []*event {
+	p.Lock()
+	defer p.Unlock()
+	events := make([]*event, 0, len(p.order))
+	for _, name := range p.order {
+		events = append(events, p.events[name])
+	}
+	p.events = make(map[string]*event)
+	p.order = nil
+	return events
+}
+
+// depth returns the number of queued updates and the number dropped
+func (p *pipeline) depth() (int, uint64) {
+	p.Lock()
+	defer p.Unlock()
+	return len(p.updates), p.dropped
+}
@@ -0,0 +1,195 @@
+package gossip
+
+import (
+	"container/heap"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v2/registry"
+	pb "github.com/micro/go-plugins/registry/gossip/v2/proto"
+)
+
+const (
+	// metadata of versioned updates
+	metadataVersion = "Version"
+	metadataOrigin  = "Origin"
+)
+
+var (
+	// TombstoneTTL is how long a deleted entry is kept so an older update
+	// received during anti-entropy doesn't bring it back
+	TombstoneTTL = time.Minute
+)
+
+// entry is the latest update for the nodes of a service
+type entry struct {
+	key      string
+	update   *pb.Update
+	service  *registry.Service
+	identity string
+	origin   string
+	version  uint64
+	// unix nano time the entry expires, 0 never
+	deadline uint64
+}
+
+// state holds the latest versioned entries which are exchanged as deltas
+// during anti-entropy. Entries are expired from a heap ordered by deadline
+// so only expired entries are visited.
+type state struct {
+	sync.RWMutex
+	entries map[string]*entry
+	expiry  expiryHeap
+	// last version issued locally
+	clock uint64
+}
+
+type expiryHeap []*entry
+
+func (h expiryHeap) Len() int            { return len(h) }
+func (h expiryHeap) Less(i, j int) bool  { return h[i].deadline < h[j].deadline }
+func (h expiryHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x interface{}) { *h = append(*h, x.(*entry)) }
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
+
+func newState() *state {
+	return &state{
+		entries: make(map[string]*entry),
+	}
+}
+
+// entryKey identifies the nodes of a service
+func entryKey(s *registry.Service) string {
+	ids := make([]string, 0, len(s.Nodes))
+	for _, node := range s.Nodes {
+		ids = append(ids, node.Id)
+	}
+	sort.Strings(ids)
+	return s.Name + "/" + s.Version + "/" + strings.Join(ids, ",")
+}
+
+// updateVersion returns the version and origin of an update, 0 if it isn't versioned
+func updateVersion(up *pb.Update) (uint64, string) {
+	v, err := strconv.ParseUint(up.Metadata[metadataVersion], 10, 64)
+	if err != nil {
+		return 0, ""
+	}
+	return v, up.Metadata[metadataOrigin]
+}
+
+// newer returns true if version a from origin x supersedes version b from origin y
+func newer(a uint64, x string, b uint64, y string) bool {
+	if a != b {
+		return a > b
+	}
+	return x > y
+}
+
+// next returns a new version, versions are the time issued so they're
+// ordered across nodes and increase if the clock goes backwards
+func (s *state) next() uint64 {
+	s.Lock()
+	defer s.Unlock()
+	v := uint64(time.Now().UnixNano())
+	if v <= s.clock {
+		v = s.clock + 1
+	}
+	s.clock = v
+	return v
+}
+
+// apply records an update, returning false if it's superseded by the entry
+// already held. Updates without a version are always applied.
+func (s *state) apply(e *entry) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	if old, ok := s.entries[e.key]; ok && e.version > 0 {
+		if !newer(e.version, e.origin, old.version, old.origin) {
+			return false
+		}
+	}
+
+	s.entries[e.key] = e
+	if e.deadline > 0 {
+		heap.Push(&s.expiry, e)
+	}
+	return true
+}
+
+// expire removes the entries past their deadline, returning those which
+// weren't deleted
+func (s *state) expire(now uint64) []*entry {
+	var expired []*entry
+
+	s.Lock()
+	defer s.Unlock()
+
+	for len(s.expiry) > 0 && s.expiry[0].deadline < now {
+		e := heap.Pop(&s.expiry).(*entry)
+		// superseded by a later update
+		if s.entries[e.key] != e {
+			continue
+		}
+		delete(s.entries, e.key)
+		if e.update.Action != actionTypeDelete {
+			expired = append(expired, e)
+		}
+	}
+
+	return expired
+}
+
+// digest returns the version of each entry
+func (s *state) digest() map[string]uint64 {
+	s.RLock()
+	defer s.RUnlock()
+	versions := make(map[string]uint64, len(s.entries))
+	for k, e := range s.entries {
+		versions[k] = e.version
+	}
+	return versions
+}
+
+// delta returns the updates newer than the versions of a digest
+func (s *state) delta(versions map[string]uint64) []*pb.Update {
+	s.RLock()
+	defer s.RUnlock()
+	var updates []*pb.Update
+	for k, e := range s.entries {
+		if v, ok := versions[k]; ok && v >= e.version {
+			continue
+		}
+		updates = append(updates, e.update)
+	}
+	return updates
+}
+
+// vectors returns the version vector of each service, the latest version
+// from each origin
+func (s *state) vectors() map[string]map[string]uint64 {
+	s.RLock()
+	defer s.RUnlock()
+	vectors := make(map[string]map[string]uint64)
+	for _, e := range s.entries {
+		v, ok := vectors[e.service.Name]
+		if !ok {
+			v = make(map[string]uint64)
+			vectors[e.service.Name] = v
+		}
+		if e.version > v[e.origin] {
+			v[e.origin] = e.version
+		}
+	}
+	return vectors
+}
@@ -0,0 +1,93 @@
+package gossip
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// member states
+	memberAlive = "alive"
+	memberLeft  = "left"
+)
+
+// Inspector is implemented by the gossip registry to inspect the state of
+// the cluster e.g for debugging a split brain
+type Inspector interface {
+	Inspect() *Status
+}
+
+// Status is a snapshot of the gossip registry
+type Status struct {
+	// Name of the local member
+	Name string
+	// Members of the cluster
+	Members []*Member
+	// Services is the version vector of each service, the latest
+	// version of its nodes announced by each member
+	Services map[string]map[string]uint64
+	// Queue is the number of updates waiting to be processed
+	Queue int
+	// Dropped is the number of updates dropped when the queue was full
+	Dropped uint64
+	// Broadcasts is the number of updates waiting to be gossiped
+	Broadcasts int
+}
+
+// Member is a member of the cluster
+type Member struct {
+	Name    string
+	Address string
+	// State is alive or left
+	State string
+	// Updated is when the state last changed
+	Updated time.Time
+}
+
+// updateMember records the state of a member, it must be called with the lock held
+func (g *gossipRegistry) updateMember(ev *event) {
+	state := memberAlive
+	if ev.action == nodeActionLeave {
+		state = memberLeft
+	}
+
+	if m, ok := g.nodes[ev.name]; ok && m.State == state && m.Address == ev.node {
+		return
+	}
+
+	g.nodes[ev.name] = &Member{
+		Name:    ev.name,
+		Address: ev.node,
+		State:   state,
+		Updated: time.Now(),
+	}
+}
+
+func (g *gossipRegistry) Inspect() *Status {
+	queued, dropped := g.pipeline.depth()
+
+	status := &Status{
+		Services: g.state.vectors(),
+		Queue:    queued,
+		Dropped:  dropped,
+	}
+
+	g.RLock()
+	if g.member != nil {
+		status.Name = g.member.LocalNode().Name
+	}
+	if g.queue != nil {
+		status.Broadcasts = g.queue.NumQueued()
+	}
+	for _, m := range g.nodes {
+		member := *m
+		status.Members = append(status.Members, &member)
+	}
+	g.RUnlock()
+
+	sort.Slice(status.Members, func(i, j int) bool {
+		return status.Members[i].Name < status.Members[j].Name
+	})
+
+	return status
+}
@@ -19,6 +19,7 @@ type Request struct {
 	method    string
 	host      string
 	namespace string
+	group     string
 
 	resource     string
 	resourceName *string
@@ -72,6 +73,13 @@ func (r *Request) Namespace(s string) *Request {
 	return r
 }
 
+// Group sets the api group and version of the resource
+// e.g discovery.k8s.io/v1, the core api is used by default
+func (r *Request) Group(s string) *Request {
+	r.group = s
+	return r
+}
+
 // Resource is the type of resource the operation is
 // for, such as "services", "endpoints" or "pods"
 func (r *Request) Resource(s string) *Request {
@@ -122,7 +130,12 @@ func (r *Request) SetHeader(key, value string) *Request {
 
 // request builds the http.Request from the options
 func (r *Request) request() (*http.Request, error) {
-	url := fmt.Sprintf("%s/api/v1/namespaces/%s/%s/", r.host, r.namespace, r.resource)
+	api := "api/v1"
+	if len(r.group) > 0 {
+		api = "apis/" + r.group
+	}
+
+	url := fmt.Sprintf("%s/%s/namespaces/%s/%s/", r.host, api, r.namespace, r.resource)
 
 	// append resourceName if it is present
 	if r.resourceName != nil {
@@ -16,6 +16,9 @@ import (
 var (
 	serviceAccountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
 
+	// api group of endpoint slices
+	discoveryGroup = "discovery.k8s.io/v1"
+
 	ErrReadNamespace = errors.New("Could not read namespace from service account secret")
 )
 
@@ -24,6 +27,13 @@ type client struct {
 	opts *api.Options
 }
 
+// GetPod ...
+func (c *client) GetPod(name string) (*Pod, error) {
+	var pod Pod
+	err := api.NewRequest(c.opts).Get().Resource("pods").Name(name).Do().Into(&pod)
+	return &pod, err
+}
+
 // ListPods ...
 func (c *client) ListPods(labels map[string]string) (*PodList, error) {
 	var pods PodList
@@ -43,6 +53,32 @@ func (c *client) WatchPods(labels map[string]string) (watch.Watch, error) {
 	return api.NewRequest(c.opts).Get().Resource("pods").Params(&api.Params{LabelSelector: labels}).Watch()
 }
 
+// GetService ...
+func (c *client) GetService(name string) (*Service, error) {
+	var svc Service
+	err := api.NewRequest(c.opts).Get().Resource("services").Name(name).Do().Into(&svc)
+	return &svc, err
+}
+
+// ListServices ...
+func (c *client) ListServices(labels map[string]string) (*ServiceList, error) {
+	var svcs ServiceList
+	err := api.NewRequest(c.opts).Get().Resource("services").Params(&api.Params{LabelSelector: labels}).Do().Into(&svcs)
+	return &svcs, err
+}
+
+// ListEndpointSlices ...
+func (c *client) ListEndpointSlices(labels map[string]string) (*EndpointSliceList, error) {
+	var slices EndpointSliceList
+	err := api.NewRequest(c.opts).Get().Group(discoveryGroup).Resource("endpointslices").Params(&api.Params{LabelSelector: labels}).Do().Into(&slices)
+	return &slices, err
+}
+
+// WatchEndpointSlices ...
+func (c *client) WatchEndpointSlices(labels map[string]string) (watch.Watch, error) {
+	return api.NewRequest(c.opts).Get().Group(discoveryGroup).Resource("endpointslices").Params(&api.Params{LabelSelector: labels}).Watch()
+}
+
 func detectNamespace() (string, error) {
 	nsPath := path.Join(serviceAccountPath, "namespace")
 
@@ -61,8 +97,18 @@ func detectNamespace() (string, error) {
 	}
 }
 
+// Option sets the api options of a client
+type Option func(o *api.Options)
+
+// Namespace sets the namespace the client operates on
+func Namespace(ns string) Option {
+	return func(o *api.Options) {
+		o.Namespace = ns
+	}
+}
+
 // NewClientByHost sets up a client by host
-func NewClientByHost(host string) Kubernetes {
+func NewClientByHost(host string, opts ...Option) Kubernetes {
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{
 			InsecureSkipVerify: true,
@@ -74,19 +120,24 @@ func NewClientByHost(host string) Kubernetes {
 		Transport: tr,
 	}
 
+	options := &api.Options{
+		Client:    c,
+		Host:      host,
+		Namespace: "default",
+	}
+	for _, o := range opts {
+		o(options)
+	}
+
 	return &client{
-		opts: &api.Options{
-			Client:    c,
-			Host:      host,
-			Namespace: "default",
-		},
+		opts: options,
 	}
 }
 
 // NewClientInCluster should work similarily to the official api
 // NewInClient by setting up a client configuration for use within
 // a k8s pod.
-func NewClientInCluster() Kubernetes {
+func NewClientInCluster(opts ...Option) Kubernetes {
 	host := "https://" + os.Getenv("KUBERNETES_SERVICE_HOST") + ":" + os.Getenv("KUBERNETES_SERVICE_PORT")
 
 	s, err := os.Stat(serviceAccountPath)
@@ -122,12 +173,17 @@ func NewClientInCluster() Kubernetes {
 		},
 	}
 
+	options := &api.Options{
+		Client:      c,
+		Host:        host,
+		Namespace:   ns,
+		BearerToken: &t,
+	}
+	for _, o := range opts {
+		o(options)
+	}
+
 	return &client{
-		opts: &api.Options{
-			Client:      c,
-			Host:        host,
-			Namespace:   ns,
-			BearerToken: &t,
-		},
+		opts: options,
 	}
 }
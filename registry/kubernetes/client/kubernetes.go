@@ -4,9 +4,14 @@ import "github.com/micro/go-plugins/registry/kubernetes/v2/client/watch"
 
 // Kubernetes ...
 type Kubernetes interface {
+	GetPod(podName string) (*Pod, error)
 	ListPods(labels map[string]string) (*PodList, error)
 	UpdatePod(podName string, pod *Pod) (*Pod, error)
 	WatchPods(labels map[string]string) (watch.Watch, error)
+	GetService(name string) (*Service, error)
+	ListServices(labels map[string]string) (*ServiceList, error)
+	ListEndpointSlices(labels map[string]string) (*EndpointSliceList, error)
+	WatchEndpointSlices(labels map[string]string) (watch.Watch, error)
 }
 
 // PodList ...
@@ -23,12 +28,89 @@ type Pod struct {
 // Meta ...
 type Meta struct {
 	Name        string             `json:"name,omitempty"`
+	Namespace   string             `json:"namespace,omitempty"`
 	Labels      map[string]*string `json:"labels,omitempty"`
 	Annotations map[string]*string `json:"annotations,omitempty"`
+	// DeletionTimestamp is set when the pod is terminating
+	DeletionTimestamp string `json:"deletionTimestamp,omitempty"`
 }
 
 // Status ...
 type Status struct {
-	PodIP string `json:"podIP"`
-	Phase string `json:"phase"`
+	PodIP      string      `json:"podIP"`
+	Phase      string      `json:"phase"`
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// Condition is the state of a pod e.g Ready
+type Condition struct {
+	Type   string `json:"type"`
+	Status string `json:"status"`
+}
+
+// ServiceList ...
+type ServiceList struct {
+	Items []Service `json:"items"`
+}
+
+// Service is a kubernetes service
+type Service struct {
+	Metadata *Meta       `json:"metadata"`
+	Spec     ServiceSpec `json:"spec"`
+}
+
+// ServiceSpec ...
+type ServiceSpec struct {
+	Ports []ServicePort `json:"ports,omitempty"`
+}
+
+// ServicePort ...
+type ServicePort struct {
+	Name     string `json:"name,omitempty"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol,omitempty"`
+}
+
+// EndpointSliceList ...
+type EndpointSliceList struct {
+	Items []EndpointSlice `json:"items"`
+}
+
+// EndpointSlice holds a subset of the endpoints of a service
+type EndpointSlice struct {
+	Metadata    *Meta          `json:"metadata"`
+	AddressType string         `json:"addressType"`
+	Endpoints   []Endpoint     `json:"endpoints"`
+	Ports       []EndpointPort `json:"ports"`
+}
+
+// Endpoint is a backend of a service, usually a pod
+type Endpoint struct {
+	Addresses  []string           `json:"addresses"`
+	Conditions EndpointConditions `json:"conditions"`
+	Hostname   *string            `json:"hostname,omitempty"`
+	NodeName   *string            `json:"nodeName,omitempty"`
+	Zone       *string            `json:"zone,omitempty"`
+	TargetRef  *ObjectReference   `json:"targetRef,omitempty"`
+}
+
+// EndpointConditions are nil when unknown
+type EndpointConditions struct {
+	Ready       *bool `json:"ready,omitempty"`
+	Serving     *bool `json:"serving,omitempty"`
+	Terminating *bool `json:"terminating,omitempty"`
+}
+
+// EndpointPort ...
+type EndpointPort struct {
+	Name     *string `json:"name,omitempty"`
+	Port     *int    `json:"port,omitempty"`
+	Protocol *string `json:"protocol,omitempty"`
+}
+
+// ObjectReference ...
+type ObjectReference struct {
+	Kind      string `json:"kind,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
 }
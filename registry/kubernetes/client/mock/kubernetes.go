@@ -9,12 +9,35 @@ import (
 	"github.com/micro/go-plugins/registry/kubernetes/v2/client/watch"
 )
 
+// kinds of resource watched
+const (
+	kindPod           = "pod"
+	kindEndpointSlice = "endpointslice"
+)
+
 // Client ...
 type Client struct {
 	sync.Mutex
-	Pods     map[string]*client.Pod
-	events   chan watch.Event
-	watchers []*mockWatcher
+	Pods           map[string]*client.Pod
+	Services       map[string]*client.Service
+	EndpointSlices map[string]*client.EndpointSlice
+	events         chan event
+	watchers       []*mockWatcher
+}
+
+// event is a watch event for a kind of resource
+type event struct {
+	kind string
+	watch.Event
+}
+
+// GetPod ...
+func (m *Client) GetPod(podName string) (*client.Pod, error) {
+	p, ok := m.Pods[podName]
+	if !ok {
+		return nil, api.ErrNotFound
+	}
+	return p, nil
 }
 
 // UpdatePod ...
@@ -28,10 +51,10 @@ func (m *Client) UpdatePod(podName string, pod *client.Pod) (*client.Pod, error)
 
 	pstr, _ := json.Marshal(p)
 
-	m.events <- watch.Event{
+	m.events <- event{kindPod, watch.Event{
 		Type:   watch.Modified,
 		Object: json.RawMessage(pstr),
-	}
+	}}
 
 	return nil, nil
 }
@@ -52,7 +75,87 @@ func (m *Client) ListPods(labels map[string]string) (*client.PodList, error) {
 
 // WatchPods ...
 func (m *Client) WatchPods(labels map[string]string) (watch.Watch, error) {
+	return m.watch(kindPod)
+}
+
+// GetService ...
+func (m *Client) GetService(name string) (*client.Service, error) {
+	s, ok := m.Services[name]
+	if !ok {
+		return nil, api.ErrNotFound
+	}
+	return s, nil
+}
+
+// ListServices ...
+func (m *Client) ListServices(labels map[string]string) (*client.ServiceList, error) {
+	var svcs []client.Service
+
+	for _, v := range m.Services {
+		if labelFilterMatch(v.Metadata.Labels, labels) {
+			svcs = append(svcs, *v)
+		}
+	}
+	return &client.ServiceList{
+		Items: svcs,
+	}, nil
+}
+
+// ListEndpointSlices ...
+func (m *Client) ListEndpointSlices(labels map[string]string) (*client.EndpointSliceList, error) {
+	var slices []client.EndpointSlice
+
+	for _, v := range m.EndpointSlices {
+		if labelFilterMatch(v.Metadata.Labels, labels) {
+			slices = append(slices, *v)
+		}
+	}
+	return &client.EndpointSliceList{
+		Items: slices,
+	}, nil
+}
+
+// WatchEndpointSlices ...
+func (m *Client) WatchEndpointSlices(labels map[string]string) (watch.Watch, error) {
+	return m.watch(kindEndpointSlice)
+}
+
+// UpdateEndpointSlice adds or replaces an endpoint slice and notifies watchers
+func (m *Client) UpdateEndpointSlice(slice *client.EndpointSlice) {
+	typ := watch.Added
+	if _, ok := m.EndpointSlices[slice.Metadata.Name]; ok {
+		typ = watch.Modified
+	}
+	m.EndpointSlices[slice.Metadata.Name] = slice
+
+	sstr, _ := json.Marshal(slice)
+
+	m.events <- event{kindEndpointSlice, watch.Event{
+		Type:   typ,
+		Object: json.RawMessage(sstr),
+	}}
+}
+
+// DeleteEndpointSlice removes an endpoint slice and notifies watchers
+func (m *Client) DeleteEndpointSlice(name string) {
+	slice, ok := m.EndpointSlices[name]
+	if !ok {
+		return
+	}
+	delete(m.EndpointSlices, name)
+
+	sstr, _ := json.Marshal(slice)
+
+	m.events <- event{kindEndpointSlice, watch.Event{
+		Type:   watch.Deleted,
+		Object: json.RawMessage(sstr),
+	}}
+}
+
+// watch returns a watcher for a kind of resource
+func (m *Client) watch(kind string) (watch.Watch, error) {
 	w := &mockWatcher{
+		kind:    kind,
 		results: make(chan watch.Event),
 		stop:    make(chan bool),
 	}
@@ -76,15 +179,19 @@ func newClient() client.Kubernetes {
 // NewClient ...
 func NewClient() *Client {
 	c := &Client{
-		Pods:   make(map[string]*client.Pod),
-		events: make(chan watch.Event),
+		Pods:           make(map[string]*client.Pod),
+		Services:       make(map[string]*client.Service),
+		EndpointSlices: make(map[string]*client.EndpointSlice),
+		events:         make(chan event),
 	}
 
 	// broadcast events to watchers
 	go func() {
 		for e := range c.events {
 			for _, w := range c.watchers {
-				w.results <- e
+				if w.kind == e.kind {
+					w.results <- e.Event
+				}
 			}
 		}
 	}()
@@ -98,11 +205,16 @@ func Teardown(c *Client) {
 	for _, p := range c.Pods {
 		pstr, _ := json.Marshal(p)
 
-		c.events <- watch.Event{
+		c.events <- event{kindPod, watch.Event{
 			Type:   watch.Deleted,
 			Object: json.RawMessage(pstr),
-		}
+		}}
+	}
+
+	for name := range c.EndpointSlices {
+		c.DeleteEndpointSlice(name)
 	}
 
 	c.Pods = make(map[string]*client.Pod)
+	c.Services = make(map[string]*client.Service)
 }
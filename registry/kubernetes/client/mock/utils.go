@@ -6,6 +6,7 @@ import (
 )
 
 type mockWatcher struct {
+	kind    string
 	results chan watch.Event
 	stop    chan bool
 }
@@ -0,0 +1,241 @@
+package kubernetes
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/micro/go-micro/v2/registry"
+	"github.com/micro/go-plugins/registry/kubernetes/v2/client"
+)
+
+var (
+	// label set on endpoint slices by kubernetes with the name of their service
+	labelServiceName = "kubernetes.io/service-name"
+
+	// used on k8s services in read only mode to set the micro
+	// service name, version and the port of the nodes by name
+	annotationNameKey    = "micro.mu/name"
+	annotationVersionKey = "micro.mu/version"
+	annotationPortKey    = "micro.mu/port"
+)
+
+// annotation returns the value of an annotation or an empty string
+func annotation(meta *client.Meta, key string) string {
+	if meta == nil {
+		return ""
+	}
+	if v, ok := meta.Annotations[key]; ok && v != nil {
+		return *v
+	}
+	return ""
+}
+
+// microName returns the micro service name of a k8s service
+func microName(svc *client.Service) string {
+	if name := annotation(svc.Metadata, annotationNameKey); len(name) > 0 {
+		return name
+	}
+	if svc.Metadata == nil {
+		return ""
+	}
+	return svc.Metadata.Name
+}
+
+// ready returns true if an endpoint can receive traffic, conditions
+// which aren't set are taken as ready and not terminating
+func ready(ep client.Endpoint) bool {
+	if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+		return false
+	}
+	if ep.Conditions.Terminating != nil && *ep.Conditions.Terminating {
+		return false
+	}
+	return true
+}
+
+// sliceNodes builds the nodes for the ready endpoints of a slice. The node
+// address uses the port named by the service annotation or the first port,
+// all the ports are added to the metadata along with the topology.
+func sliceNodes(svc *client.Service, slice *client.EndpointSlice) []*registry.Node {
+	if len(slice.Ports) == 0 {
+		return nil
+	}
+
+	portName := annotation(svc.Metadata, annotationPortKey)
+	port := -1
+	ports := make(map[string]string)
+
+	for _, p := range slice.Ports {
+		if p.Port == nil {
+			continue
+		}
+
+		var name string
+		if p.Name != nil {
+			name = *p.Name
+		}
+
+		key := "port"
+		if len(name) > 0 {
+			key = "port." + name
+		}
+		ports[key] = strconv.Itoa(*p.Port)
+
+		if port < 0 || (len(portName) > 0 && name == portName) {
+			port = *p.Port
+		}
+	}
+
+	if port < 0 {
+		return nil
+	}
+
+	name := microName(svc)
+
+	var nodes []*registry.Node
+	for _, ep := range slice.Endpoints {
+		if !ready(ep) {
+			continue
+		}
+
+		md := make(map[string]string)
+		for k, v := range ports {
+			md[k] = v
+		}
+		if ep.Zone != nil {
+			md["zone"] = *ep.Zone
+		}
+		if ep.NodeName != nil {
+			md["node"] = *ep.NodeName
+		}
+		if ep.Hostname != nil {
+			md["hostname"] = *ep.Hostname
+		}
+		if ep.TargetRef != nil && ep.TargetRef.Kind == "Pod" {
+			md["pod"] = ep.TargetRef.Name
+		}
+
+		for _, addr := range ep.Addresses {
+			id := addr
+			if ep.TargetRef != nil && len(ep.TargetRef.Name) > 0 {
+				id = ep.TargetRef.Name
+			}
+
+			nodes = append(nodes, &registry.Node{
+				Id:       name + "-" + id,
+				Address:  net.JoinHostPort(addr, strconv.Itoa(port)),
+				Metadata: md,
+			})
+		}
+	}
+
+	return nodes
+}
+
+// sliceService builds a micro service from a k8s service and its endpoint slices
+func sliceService(svc *client.Service, slices ...client.EndpointSlice) *registry.Service {
+	service := &registry.Service{
+		Name:    microName(svc),
+		Version: annotation(svc.Metadata, annotationVersionKey),
+	}
+
+	for i := range slices {
+		service.Nodes = append(service.Nodes, sliceNodes(svc, &slices[i])...)
+	}
+
+	return service
+}
+
+// getSliceService builds the services with the given name from the
+// k8s services with the service label and their endpoint slices
+func (c *kregistry) getSliceService(name string) ([]*registry.Service, error) {
+	list, err := c.sliceServices(func(svc *client.Service) bool {
+		return microName(svc) == name
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, registry.ErrNotFound
+	}
+	return list, nil
+}
+
+// querySlices builds the services of a query. The k8s services are
+// matched on name and version before their endpoint slices are listed.
+func (c *kregistry) querySlices(name, version string) ([]*registry.Service, error) {
+	return c.sliceServices(func(svc *client.Service) bool {
+		if len(name) > 0 && microName(svc) != name {
+			return false
+		}
+		if len(version) > 0 && annotation(svc.Metadata, annotationVersionKey) != version {
+			return false
+		}
+		return true
+	})
+}
+
+// sliceServices builds the services from the matching k8s services with
+// the service label and their endpoint slices
+func (c *kregistry) sliceServices(match func(svc *client.Service) bool) ([]*registry.Service, error) {
+	svcs, err := c.client.ListServices(podSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	// services mapped by name and version
+	versions := make(map[string]*registry.Service)
+
+	for i := range svcs.Items {
+		svc := &svcs.Items[i]
+		if !match(svc) {
+			continue
+		}
+
+		slices, err := c.client.ListEndpointSlices(map[string]string{
+			labelServiceName: svc.Metadata.Name,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		service := sliceService(svc, slices.Items...)
+		if len(service.Nodes) == 0 {
+			continue
+		}
+
+		vs, ok := versions[service.Name+":"+service.Version]
+		if !ok {
+			versions[service.Name+":"+service.Version] = service
+			continue
+		}
+
+		vs.Nodes = append(vs.Nodes, service.Nodes...)
+	}
+
+	list := make([]*registry.Service, 0, len(versions))
+	for _, val := range versions {
+		list = append(list, val)
+	}
+	return list, nil
+}
+
+// listSliceServices lists the names of the k8s services with the service label
+func (c *kregistry) listSliceServices() ([]*registry.Service, error) {
+	svcs, err := c.client.ListServices(podSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	// svcs mapped by name
+	names := make(map[string]bool)
+	for i := range svcs.Items {
+		names[microName(&svcs.Items[i])] = true
+	}
+
+	var list []*registry.Service
+	for name := range names {
+		list = append(list, &registry.Service{Name: name})
+	}
+	return list, nil
+}
@@ -0,0 +1,209 @@
+package kubernetes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v2/registry"
+	"github.com/micro/go-plugins/registry/kubernetes/v2/client"
+	"github.com/micro/go-plugins/registry/kubernetes/v2/client/mock"
+)
+
+func str(s string) *string { return &s }
+func boolean(b bool) *bool { return &b }
+func port(p int) *int      { return &p }
+
+func setupSliceRegistry() (*mock.Client, registry.Registry) {
+	c := mock.NewClient()
+
+	c.Services["foo"] = &client.Service{
+		Metadata: &client.Meta{
+			Name: "foo",
+			Labels: map[string]*string{
+				labelTypeKey: &labelTypeValueService,
+			},
+			Annotations: map[string]*string{
+				annotationNameKey:    str("foo.service"),
+				annotationVersionKey: str("1"),
+				annotationPortKey:    str("grpc"),
+			},
+		},
+	}
+
+	return c, &kregistry{
+		client:   c,
+		timeout:  time.Second,
+		readOnly: true,
+	}
+}
+
+func endpoint(pod, addr string, ready, terminating *bool) client.Endpoint {
+	return client.Endpoint{
+		Addresses: []string{addr},
+		Conditions: client.EndpointConditions{
+			Ready:       ready,
+			Terminating: terminating,
+		},
+		Zone:      str("zone-a"),
+		TargetRef: &client.ObjectReference{Kind: "Pod", Name: pod},
+	}
+}
+
+func slice(endpoints ...client.Endpoint) *client.EndpointSlice {
+	return &client.EndpointSlice{
+		Metadata: &client.Meta{
+			Name: "foo-abcde",
+			Labels: map[string]*string{
+				labelTypeKey:     &labelTypeValueService,
+				labelServiceName: str("foo"),
+			},
+		},
+		AddressType: "IPv4",
+		Endpoints:   endpoints,
+		Ports: []client.EndpointPort{
+			{Name: str("http"), Port: port(8080)},
+			{Name: str("grpc"), Port: port(9090)},
+		},
+	}
+}
+
+func TestReadOnlyGetService(t *testing.T) {
+	c, r := setupSliceRegistry()
+
+	c.EndpointSlices["foo-abcde"] = slice(
+		endpoint("pod-a", "10.0.0.1", boolean(true), nil),
+		endpoint("pod-b", "10.0.0.2", boolean(false), nil),
+		endpoint("pod-c", "10.0.0.3", boolean(true), boolean(true)),
+		endpoint("pod-d", "10.0.0.4", nil, nil),
+	)
+
+	services, err := r.GetService("foo.service")
+	if err != nil {
+		t.Fatalf("did not expect GetService to fail %v", err)
+	}
+	if len(services) != 1 {
+		t.Fatalf("expected 1 service got %d", len(services))
+	}
+
+	svc := services[0]
+	if svc.Version != "1" {
+		t.Fatalf("expected version 1 got %s", svc.Version)
+	}
+
+	// only the ready endpoints which aren't terminating
+	if !hasNodes(svc.Nodes, []*registry.Node{
+		{Id: "foo.service-pod-a"},
+		{Id: "foo.service-pod-d"},
+	}) || len(svc.Nodes) != 2 {
+		t.Fatalf("expected ready nodes got %+v", svc.Nodes)
+	}
+
+	node := svc.Nodes[0]
+	if node.Address != "10.0.0.1:9090" && node.Address != "10.0.0.4:9090" {
+		t.Fatalf("expected address on the grpc port got %s", node.Address)
+	}
+	if node.Metadata["zone"] != "zone-a" || node.Metadata["port.http"] != "8080" {
+		t.Fatalf("expected zone and port metadata got %v", node.Metadata)
+	}
+
+	if _, err := r.GetService("bar.service"); err != registry.ErrNotFound {
+		t.Fatalf("expected not found got %v", err)
+	}
+}
+
+func TestReadOnlyRegister(t *testing.T) {
+	c, r := setupSliceRegistry()
+
+	svc := &registry.Service{
+		Name:  "foo.service",
+		Nodes: []*registry.Node{{Id: "foo-1", Address: "10.0.0.1:9090"}},
+	}
+
+	// there are no pods to patch
+	if err := r.Register(svc); err != nil {
+		t.Fatalf("did not expect Register to fail %v", err)
+	}
+	if err := r.Deregister(svc); err != nil {
+		t.Fatalf("did not expect Deregister to fail %v", err)
+	}
+	if len(c.Pods) != 0 {
+		t.Fatal("expected no pods to be changed")
+	}
+
+	services, err := r.ListServices()
+	if err != nil {
+		t.Fatalf("did not expect ListServices to fail %v", err)
+	}
+	if !hasServices(services, []*registry.Service{{Name: "foo.service"}}) {
+		t.Fatalf("expected foo.service got %+v", services)
+	}
+}
+
+func TestReadOnlyWatcher(t *testing.T) {
+	c, r := setupSliceRegistry()
+
+	w, err := r.Watch(registry.WatchService("foo.service"))
+	if err != nil {
+		t.Fatalf("did not expect Watch to fail %v", err)
+	}
+	defer w.Stop()
+
+	go c.UpdateEndpointSlice(slice(
+		endpoint("pod-a", "10.0.0.1", boolean(true), nil),
+		endpoint("pod-b", "10.0.0.2", boolean(true), nil),
+	))
+
+	res, err := w.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Action != "create" || len(res.Service.Nodes) != 2 {
+		t.Fatalf("expected create with 2 nodes got %s %+v", res.Action, res.Service.Nodes)
+	}
+
+	// pod-b is no longer ready
+	go c.UpdateEndpointSlice(slice(
+		endpoint("pod-a", "10.0.0.1", boolean(true), nil),
+		endpoint("pod-b", "10.0.0.2", boolean(false), nil),
+	))
+
+	res, err = w.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Action != "delete" || len(res.Service.Nodes) != 1 || res.Service.Nodes[0].Id != "foo.service-pod-b" {
+		t.Fatalf("expected delete of pod-b got %s %+v", res.Action, res.Service.Nodes)
+	}
+
+	res, err = w.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Action != "update" || len(res.Service.Nodes) != 1 {
+		t.Fatalf("expected update with 1 node got %s %+v", res.Action, res.Service.Nodes)
+	}
+}
+
+func TestReadOnlyQuery(t *testing.T) {
+	c, r := setupSliceRegistry()
+
+	c.EndpointSlices["foo-abcde"] = slice(
+		endpoint("pod-a", "10.0.0.1", boolean(true), nil),
+	)
+
+	services, err := r.(querier).QueryServices("", "", nil)
+	if err != nil {
+		t.Fatalf("did not expect QueryServices to fail %v", err)
+	}
+	if len(services) != 1 || len(services[0].Nodes) != 1 {
+		t.Fatalf("expected 1 service with 1 node got %+v", services)
+	}
+
+	services, err = r.(querier).QueryServices("", "2", nil)
+	if err != nil {
+		t.Fatalf("did not expect QueryServices to fail %v", err)
+	}
+	if len(services) != 0 {
+		t.Fatalf("expected no services got %+v", services)
+	}
+}
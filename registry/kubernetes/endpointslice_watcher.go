@@ -0,0 +1,198 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	log "github.com/micro/go-micro/v2/logger"
+	"github.com/micro/go-micro/v2/registry"
+	"github.com/micro/go-plugins/registry/kubernetes/v2/client"
+	"github.com/micro/go-plugins/registry/kubernetes/v2/client/watch"
+)
+
+// sliceWatcher watches endpoint slices in read only mode
+type sliceWatcher struct {
+	registry *kregistry
+	watcher  watch.Watch
+	service  string
+	next     chan *registry.Result
+
+	sync.RWMutex
+	// services built from each slice, by slice name
+	slices map[string]*registry.Service
+}
+
+// build a cache of slices when the watcher starts.
+func (k *sliceWatcher) updateCache() error {
+	sliceList, err := k.registry.client.ListEndpointSlices(podSelector)
+	if err != nil {
+		return err
+	}
+
+	for i := range sliceList.Items {
+		slice := &sliceList.Items[i]
+
+		svc, err := k.sliceService(slice)
+		if err != nil {
+			continue
+		}
+
+		k.Lock()
+		k.slices[slice.Metadata.Name] = svc
+		k.Unlock()
+	}
+
+	return nil
+}
+
+// sliceService builds the micro service for a single slice
+func (k *sliceWatcher) sliceService(slice *client.EndpointSlice) (*registry.Service, error) {
+	if slice.Metadata == nil {
+		return nil, errors.New("endpoint slice has no metadata")
+	}
+
+	name, ok := slice.Metadata.Labels[labelServiceName]
+	if !ok || name == nil {
+		return nil, errors.New("endpoint slice has no service")
+	}
+
+	svc, err := k.registry.client.GetService(*name)
+	if err != nil {
+		return nil, err
+	}
+
+	return sliceService(svc, *slice), nil
+}
+
+// handleEvent compares the nodes of a slice against the cache, sending
+// a delete for nodes which have gone and an update for the others.
+func (k *sliceWatcher) handleEvent(event watch.Event) {
+	var slice client.EndpointSlice
+	if err := json.Unmarshal([]byte(event.Object), &slice); err != nil || slice.Metadata == nil {
+		log.Error("K8s Watcher: Couldnt unmarshal event object from endpoint slice")
+		return
+	}
+
+	k.RLock()
+	cache := k.slices[slice.Metadata.Name]
+	k.RUnlock()
+
+	var svc *registry.Service
+
+	switch event.Type {
+	case watch.Added, watch.Modified:
+		s, err := k.sliceService(&slice)
+		if err != nil {
+			log.Errorf("K8s Watcher: Couldnt get service of endpoint slice %s: %v", slice.Metadata.Name, err)
+			return
+		}
+		svc = s
+	case watch.Deleted:
+	default:
+		return
+	}
+
+	// nodes in the cache which have gone
+	if cache != nil {
+		ids := make(map[string]bool)
+		if svc != nil {
+			for _, node := range svc.Nodes {
+				ids[node.Id] = true
+			}
+		}
+
+		var nodes []*registry.Node
+		for _, node := range cache.Nodes {
+			if !ids[node.Id] {
+				nodes = append(nodes, node)
+			}
+		}
+
+		if len(nodes) > 0 && k.watching(cache) {
+			deleted := new(registry.Service)
+			*deleted = *cache
+			deleted.Nodes = nodes
+			k.next <- &registry.Result{Action: "delete", Service: deleted}
+		}
+	}
+
+	if svc != nil && len(svc.Nodes) > 0 && k.watching(svc) {
+		action := "create"
+		if cache != nil {
+			action = "update"
+		}
+		k.next <- &registry.Result{Action: action, Service: svc}
+	}
+
+	k.Lock()
+	if svc != nil {
+		k.slices[slice.Metadata.Name] = svc
+	} else {
+		delete(k.slices, slice.Metadata.Name)
+	}
+	k.Unlock()
+}
+
+// watching returns true if results for the service should be sent
+func (k *sliceWatcher) watching(svc *registry.Service) bool {
+	return len(k.service) == 0 || k.service == svc.Name
+}
+
+// Next will block until a new result comes in
+func (k *sliceWatcher) Next() (*registry.Result, error) {
+	r, ok := <-k.next
+	if !ok {
+		return nil, errors.New("result chan closed")
+	}
+	return r, nil
+}
+
+// Stop will cancel any requests, and close channels
+func (k *sliceWatcher) Stop() {
+	k.watcher.Stop()
+
+	select {
+	case <-k.next:
+		return
+	default:
+		close(k.next)
+	}
+}
+
+func newSliceWatcher(kr *kregistry, opts ...registry.WatchOption) (registry.Watcher, error) {
+	var wo registry.WatchOptions
+	for _, o := range opts {
+		o(&wo)
+	}
+
+	// Create watch request
+	watcher, err := kr.client.WatchEndpointSlices(podSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	k := &sliceWatcher{
+		registry: kr,
+		watcher:  watcher,
+		service:  wo.Service,
+		next:     make(chan *registry.Result),
+		slices:   make(map[string]*registry.Service),
+	}
+
+	// update cache, but dont emit changes
+	if err := k.updateCache(); err != nil {
+		return nil, err
+	}
+
+	// range over watch request changes, and invoke
+	// the update event
+	go func() {
+		for event := range watcher.ResultChan() {
+			k.handleEvent(event)
+		}
+		k.Stop()
+	}()
+
+	return k, nil
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/micro/go-plugins/registry/kubernetes/v2/client"
@@ -20,6 +21,14 @@ type kregistry struct {
 	client  client.Kubernetes
 	timeout time.Duration
 	options registry.Options
+
+	// name of the pod services are registered against
+	podName string
+	// discover services from endpoint slices without registering
+	readOnly bool
+
+	// serialises updates to the pod annotations
+	sync.Mutex
 }
 
 var (
@@ -35,8 +44,17 @@ var (
 	// micro service by pod name
 	annotationServiceKeyPrefix = "micro.mu/service-"
 
+	// used on pods to store the expiry of nodes registered
+	// with a ttl, as unix seconds by node id
+	annotationExpiryKeyPrefix = "micro.mu/expiry-"
+
+	// environment variables set from the downward api
+	podNameEnv      = "POD_NAME"
+	podNamespaceEnv = "POD_NAMESPACE"
+
 	// Pod status
 	podRunning = "Running"
+	podReady   = "Ready"
 
 	// label name regex
 	labelRe = regexp.MustCompilePOSIX("[-A-Za-z0-9_.]")
@@ -66,12 +84,32 @@ func configure(k *kregistry, opts ...registry.Option) error {
 		k.options.Timeout = time.Second * 1
 	}
 
+	// namespace from the options or the downward api
+	namespace := os.Getenv(podNamespaceEnv)
+
+	if k.options.Context != nil {
+		if name, ok := k.options.Context.Value(podNameKey{}).(string); ok && len(name) > 0 {
+			k.podName = name
+		}
+		if ns, ok := k.options.Context.Value(namespaceKey{}).(string); ok && len(ns) > 0 {
+			namespace = ns
+		}
+		if b, ok := k.options.Context.Value(readOnlyKey{}).(bool); ok {
+			k.readOnly = b
+		}
+	}
+
+	var copts []client.Option
+	if len(namespace) > 0 {
+		copts = append(copts, client.Namespace(namespace))
+	}
+
 	// if no hosts setup, assume InCluster
 	var c client.Kubernetes
 	if len(host) == 0 {
-		c = client.NewClientInCluster()
+		c = client.NewClientInCluster(copts...)
 	} else {
-		c = client.NewClientByHost(host)
+		c = client.NewClientByHost(host, copts...)
 	}
 
 	k.client = c
@@ -105,33 +143,114 @@ func (c *kregistry) Options() registry.Options {
 	return c.options
 }
 
-// Register sets a service selector label and an annotation with a
-// serialised version of the service passed in.
-func (c *kregistry) Register(s *registry.Service, opts ...registry.RegisterOption) error {
-	if len(s.Nodes) == 0 {
-		return errors.New("you must register at least one node")
+// pod returns the name of the pod to register against from the
+// options, the downward api or otherwise the hostname
+func (c *kregistry) pod() string {
+	if len(c.podName) > 0 {
+		return c.podName
+	}
+	if name := os.Getenv(podNameEnv); len(name) > 0 {
+		return name
 	}
+	return os.Getenv("HOSTNAME")
+}
 
-	// TODO: grab podname from somewhere better than this.
-	podName := os.Getenv("HOSTNAME")
-	svcName := s.Name
+// healthy returns true if a pod is running, ready and not terminating
+func healthy(pod *client.Pod) bool {
+	if pod.Status == nil || pod.Status.Phase != podRunning {
+		return false
+	}
+	if pod.Metadata != nil && len(pod.Metadata.DeletionTimestamp) > 0 {
+		return false
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == podReady {
+			return cond.Status == "True"
+		}
+	}
+	return false
+}
 
-	// encode micro service
-	b, err := json.Marshal(s)
-	if err != nil {
-		return err
+// podService returns the service registered on a pod and the
+// expiry of its nodes. The service is nil if there isn't one.
+func podService(pod *client.Pod, name string) (*registry.Service, map[string]int64, error) {
+	expiry := make(map[string]int64)
+
+	if pod == nil || pod.Metadata == nil {
+		return nil, expiry, nil
+	}
+
+	svcStr, ok := pod.Metadata.Annotations[annotationServiceKeyPrefix+serviceName(name)]
+	if !ok || svcStr == nil {
+		return nil, expiry, nil
+	}
+
+	var svc *registry.Service
+	if err := json.Unmarshal([]byte(*svcStr), &svc); err != nil {
+		return nil, expiry, fmt.Errorf("could not unmarshal service '%s' from pod annotation", name)
+	}
+
+	if exp, ok := pod.Metadata.Annotations[annotationExpiryKeyPrefix+serviceName(name)]; ok && exp != nil {
+		if err := json.Unmarshal([]byte(*exp), &expiry); err != nil {
+			return nil, expiry, fmt.Errorf("could not unmarshal expiry of '%s' from pod annotation", name)
+		}
+	}
+
+	return svc, expiry, nil
+}
+
+// liveNodes removes the nodes of a service which have expired
+func liveNodes(svc *registry.Service, expiry map[string]int64) {
+	now := time.Now().Unix()
+
+	var nodes []*registry.Node
+	for _, node := range svc.Nodes {
+		if exp, ok := expiry[node.Id]; ok && exp < now {
+			delete(expiry, node.Id)
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	svc.Nodes = nodes
+}
+
+// update patches the pod with the service and the expiry of its
+// nodes, or removes the service if it has no nodes left.
+func (c *kregistry) update(podName, name string, svc *registry.Service, expiry map[string]int64) error {
+	labels := map[string]*string{
+		svcSelectorPrefix + serviceName(name): nil,
+	}
+	annotations := map[string]*string{
+		annotationServiceKeyPrefix + serviceName(name): nil,
+		annotationExpiryKeyPrefix + serviceName(name):  nil,
+	}
+
+	if svc != nil && len(svc.Nodes) > 0 {
+		// encode micro service
+		b, err := json.Marshal(svc)
+		if err != nil {
+			return err
+		}
+		svcStr := string(b)
+
+		labels[labelTypeKey] = &labelTypeValueService
+		labels[svcSelectorPrefix+serviceName(name)] = &svcSelectorValue
+		annotations[annotationServiceKeyPrefix+serviceName(name)] = &svcStr
+
+		if len(expiry) > 0 {
+			e, err := json.Marshal(expiry)
+			if err != nil {
+				return err
+			}
+			expStr := string(e)
+			annotations[annotationExpiryKeyPrefix+serviceName(name)] = &expStr
+		}
 	}
-	svc := string(b)
 
 	pod := &client.Pod{
 		Metadata: &client.Meta{
-			Labels: map[string]*string{
-				labelTypeKey:                             &labelTypeValueService,
-				svcSelectorPrefix + serviceName(svcName): &svcSelectorValue,
-			},
-			Annotations: map[string]*string{
-				annotationServiceKeyPrefix + serviceName(svcName): &svc,
-			},
+			Labels:      labels,
+			Annotations: annotations,
 		},
 	}
 
@@ -140,41 +259,125 @@ func (c *kregistry) Register(s *registry.Service, opts ...registry.RegisterOptio
 	}
 
 	return nil
+}
+
+// Register sets a service selector label and an annotation with a
+// serialised version of the service passed in. Nodes already registered
+// on the pod are kept so a pod can run many nodes of a service. Nodes
+// registered with a ttl expire unless they're registered again in time.
+func (c *kregistry) Register(s *registry.Service, opts ...registry.RegisterOption) error {
+	if len(s.Nodes) == 0 {
+		return errors.New("you must register at least one node")
+	}
+
+	// services are registered by kubernetes
+	if c.readOnly {
+		return nil
+	}
+
+	var options registry.RegisterOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	podName := c.pod()
+
+	c.Lock()
+	defer c.Unlock()
 
+	pod, err := c.client.GetPod(podName)
+	if err != nil {
+		return err
+	}
+
+	old, expiry, err := podService(pod, s.Name)
+	if err != nil {
+		return err
+	}
+
+	// copy the service, keeping the other live nodes on the pod
+	svc := new(registry.Service)
+	*svc = *s
+	svc.Nodes = nil
+
+	ids := make(map[string]bool)
+	for _, node := range s.Nodes {
+		ids[node.Id] = true
+		svc.Nodes = append(svc.Nodes, node)
+
+		if options.TTL > 0 {
+			expiry[node.Id] = time.Now().Add(options.TTL).Unix()
+		} else {
+			delete(expiry, node.Id)
+		}
+	}
+
+	if old != nil {
+		liveNodes(old, expiry)
+		for _, node := range old.Nodes {
+			if !ids[node.Id] {
+				svc.Nodes = append(svc.Nodes, node)
+			}
+		}
+	}
+
+	return c.update(podName, s.Name, svc, expiry)
 }
 
-// Deregister nils out any things set in Register
+// Deregister removes the nodes passed in, and the label and
+// annotation once there are no nodes left on the pod
 func (c *kregistry) Deregister(s *registry.Service, opts ...registry.DeregisterOption) error {
 	if len(s.Nodes) == 0 {
 		return errors.New("you must deregister at least one node")
 	}
 
-	// TODO: grab podname from somewhere better than this.
-	podName := os.Getenv("HOSTNAME")
-	svcName := s.Name
-
-	pod := &client.Pod{
-		Metadata: &client.Meta{
-			Labels: map[string]*string{
-				svcSelectorPrefix + serviceName(svcName): nil,
-			},
-			Annotations: map[string]*string{
-				annotationServiceKeyPrefix + serviceName(svcName): nil,
-			},
-		},
+	if c.readOnly {
+		return nil
 	}
 
-	if _, err := c.client.UpdatePod(podName, pod); err != nil {
+	podName := c.pod()
+
+	c.Lock()
+	defer c.Unlock()
+
+	pod, err := c.client.GetPod(podName)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	svc, expiry, err := podService(pod, s.Name)
+	if err != nil || svc == nil {
+		// nothing we can keep, remove it all
+		return c.update(podName, s.Name, nil, nil)
+	}
+
+	ids := make(map[string]bool)
+	for _, node := range s.Nodes {
+		ids[node.Id] = true
+		delete(expiry, node.Id)
+	}
+
+	liveNodes(svc, expiry)
 
+	var nodes []*registry.Node
+	for _, node := range svc.Nodes {
+		if !ids[node.Id] {
+			nodes = append(nodes, node)
+		}
+	}
+	svc.Nodes = nodes
+
+	return c.update(podName, s.Name, svc, expiry)
 }
 
 // GetService will get all the pods with the given service selector,
-// and build services from the annotations.
+// and build services from the annotations. Pods which aren't ready or
+// are terminating and nodes which have expired are left out.
 func (c *kregistry) GetService(name string, opts ...registry.GetOption) ([]*registry.Service, error) {
+	if c.readOnly {
+		return c.getSliceService(name)
+	}
+
 	pods, err := c.client.ListPods(map[string]string{
 		svcSelectorPrefix + serviceName(name): svcSelectorValue,
 	})
@@ -186,35 +389,58 @@ func (c *kregistry) GetService(name string, opts ...registry.GetOption) ([]*regi
 		return nil, registry.ErrNotFound
 	}
 
-	// svcs mapped by version
+	list, err := podServices(pods.Items, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, registry.ErrNotFound
+	}
+	return list, nil
+}
+
+// podServices builds the services with the given name, or all the
+// services if the name is blank, from the annotations of the pods.
+func podServices(pods []client.Pod, name string) ([]*registry.Service, error) {
+	// svcs mapped by name and version
 	svcs := make(map[string]*registry.Service)
 
 	// loop through items
-	for _, pod := range pods.Items {
-		if pod.Status.Phase != podRunning {
-			continue
-		}
-		// get serialised service from annotation
-		svcStr, ok := pod.Metadata.Annotations[annotationServiceKeyPrefix+serviceName(name)]
-		if !ok {
+	for i := range pods {
+		pod := &pods[i]
+		if !healthy(pod) {
 			continue
 		}
 
-		// unmarshal service string
-		var svc registry.Service
-		err := json.Unmarshal([]byte(*svcStr), &svc)
-		if err != nil {
-			return nil, fmt.Errorf("could not unmarshal service '%s' from pod annotation", name)
+		names := []string{name}
+		if len(name) == 0 {
+			names = podServiceNames(pod)
 		}
 
-		// merge up pod service & ip with versioned service.
-		vs, ok := svcs[svc.Version]
-		if !ok {
-			svcs[svc.Version] = &svc
-			continue
-		}
+		for _, name := range names {
+			// get serialised service from annotation
+			svc, expiry, err := podService(pod, name)
+			if err != nil {
+				return nil, err
+			}
+			if svc == nil {
+				continue
+			}
+
+			liveNodes(svc, expiry)
+			if len(svc.Nodes) == 0 {
+				continue
+			}
 
-		vs.Nodes = append(vs.Nodes, svc.Nodes...)
+			// merge up pod service & ip with versioned service.
+			vs, ok := svcs[svc.Name+":"+svc.Version]
+			if !ok {
+				svcs[svc.Name+":"+svc.Version] = svc
+				continue
+			}
+
+			vs.Nodes = append(vs.Nodes, svc.Nodes...)
+		}
 	}
 
 	list := make([]*registry.Service, 0, len(svcs))
@@ -224,8 +450,31 @@ func (c *kregistry) GetService(name string, opts ...registry.GetOption) ([]*regi
 	return list, nil
 }
 
+// podServiceNames returns the names of the services registered on a pod
+func podServiceNames(pod *client.Pod) []string {
+	var names []string
+	for k, v := range pod.Metadata.Annotations {
+		if !strings.HasPrefix(k, annotationServiceKeyPrefix) || v == nil {
+			continue
+		}
+
+		// we have to unmarshal the annotation itself since the
+		// key is encoded to match the regex restriction.
+		var svc registry.Service
+		if err := json.Unmarshal([]byte(*v), &svc); err != nil {
+			continue
+		}
+		names = append(names, svc.Name)
+	}
+	return names
+}
+
 // ListServices will list all the service names
 func (c *kregistry) ListServices(opts ...registry.ListOption) ([]*registry.Service, error) {
+	if c.readOnly {
+		return c.listSliceServices()
+	}
+
 	pods, err := c.client.ListPods(podSelector)
 	if err != nil {
 		return nil, err
@@ -234,22 +483,13 @@ func (c *kregistry) ListServices(opts ...registry.ListOption) ([]*registry.Servi
 	// svcs mapped by name
 	svcs := make(map[string]bool)
 
-	for _, pod := range pods.Items {
-		if pod.Status.Phase != podRunning {
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !healthy(pod) {
 			continue
 		}
-		for k, v := range pod.Metadata.Annotations {
-			if !strings.HasPrefix(k, annotationServiceKeyPrefix) {
-				continue
-			}
-
-			// we have to unmarshal the annotation itself since the
-			// key is encoded to match the regex restriction.
-			var svc registry.Service
-			if err := json.Unmarshal([]byte(*v), &svc); err != nil {
-				continue
-			}
-			svcs[svc.Name] = true
+		for _, name := range podServiceNames(pod) {
+			svcs[name] = true
 		}
 	}
 
@@ -260,8 +500,35 @@ func (c *kregistry) ListServices(opts ...registry.ListOption) ([]*registry.Servi
 	return list, nil
 }
 
+// QueryServices selects the pods with a label selector, all the pods with
+// the service label or those of a single service, and builds the services
+// from a single list. The nodes are filtered in process. It implements the
+// Querier interface of registry/query.
+func (c *kregistry) QueryServices(name, version string, metadata map[string]string) ([]*registry.Service, error) {
+	if c.readOnly {
+		return c.querySlices(name, version)
+	}
+
+	selector := podSelector
+	if len(name) > 0 {
+		selector = map[string]string{
+			svcSelectorPrefix + serviceName(name): svcSelectorValue,
+		}
+	}
+
+	pods, err := c.client.ListPods(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	return podServices(pods.Items, name)
+}
+
 // Watch returns a kubernetes watcher
 func (c *kregistry) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
+	if c.readOnly {
+		return newSliceWatcher(c, opts...)
+	}
 	return newWatcher(c, opts...)
 }
 
@@ -39,6 +39,9 @@ func setupPod(name string) *client.Pod {
 		Status: &client.Status{
 			PodIP: "10.0.0." + strconv.Itoa(podIP),
 			Phase: podRunning,
+			Conditions: []client.Condition{
+				{Type: podReady, Status: "True"},
+			},
 		},
 	}
 
@@ -423,6 +426,136 @@ func TestListServices(t *testing.T) {
 
 }
 
+func TestRegisterTwoNodesOnePod(t *testing.T) {
+	r := setupRegistry()
+	defer teardownRegistry()
+
+	pod := setupPod("pod-1")
+	os.Setenv("HOSTNAME", "pod-1")
+	defer os.Setenv("HOSTNAME", "")
+
+	for _, port := range []int{80, 81} {
+		svc := &registry.Service{
+			Name:    "foo.service",
+			Version: "1",
+			Nodes: []*registry.Node{{
+				Id:      fmt.Sprintf("foo.service:%d", port),
+				Address: fmt.Sprintf("%s:%d", pod.Status.PodIP, port),
+			}},
+		}
+		if err := r.Register(svc); err != nil {
+			t.Fatalf("did not expect Register() to fail: %v", err)
+		}
+	}
+
+	service, err := r.GetService("foo.service")
+	if err != nil {
+		t.Fatalf("did not expect GetService to fail %v", err)
+	}
+	if len(service) != 1 || len(service[0].Nodes) != 2 {
+		t.Fatalf("expected 1 service with 2 nodes got %+v", service)
+	}
+
+	// deregister one of the nodes
+	if err := r.Deregister(&registry.Service{
+		Name:  "foo.service",
+		Nodes: []*registry.Node{{Id: "foo.service:80"}},
+	}); err != nil {
+		t.Fatalf("did not expect Deregister to fail %v", err)
+	}
+
+	service, err = r.GetService("foo.service")
+	if err != nil {
+		t.Fatalf("did not expect GetService to fail %v", err)
+	}
+	if len(service) != 1 || len(service[0].Nodes) != 1 || service[0].Nodes[0].Id != "foo.service:81" {
+		t.Fatalf("expected only node foo.service:81 got %+v", service)
+	}
+}
+
+func TestRegisterTTL(t *testing.T) {
+	r := setupRegistry()
+	defer teardownRegistry()
+
+	pod := setupPod("pod-1")
+	os.Setenv("HOSTNAME", "pod-1")
+	defer os.Setenv("HOSTNAME", "")
+
+	svc := &registry.Service{
+		Name: "foo.service",
+		Nodes: []*registry.Node{{
+			Id:      "foo.service:pod-1",
+			Address: pod.Status.PodIP + ":80",
+		}},
+	}
+	if err := r.Register(svc, registry.RegisterTTL(time.Minute)); err != nil {
+		t.Fatalf("did not expect Register() to fail: %v", err)
+	}
+
+	if _, err := r.GetService("foo.service"); err != nil {
+		t.Fatalf("did not expect GetService to fail %v", err)
+	}
+
+	// expire the node
+	expired := fmt.Sprintf(`{"foo.service:pod-1":%d}`, time.Now().Add(-time.Second).Unix())
+	pod.Metadata.Annotations[annotationExpiryKeyPrefix+"foo.service"] = &expired
+
+	if _, err := r.GetService("foo.service"); err != registry.ErrNotFound {
+		t.Fatalf("expected expired node to be removed got %v", err)
+	}
+
+	// registering again keeps the node alive
+	if err := r.Register(svc, registry.RegisterTTL(time.Minute)); err != nil {
+		t.Fatalf("did not expect Register() to fail: %v", err)
+	}
+	if _, err := r.GetService("foo.service"); err != nil {
+		t.Fatalf("did not expect GetService to fail %v", err)
+	}
+}
+
+func TestGetServiceUnhealthyPods(t *testing.T) {
+	r := setupRegistry()
+	defer teardownRegistry()
+
+	svc1 := &registry.Service{Name: "foo.service", Version: "1"}
+	svc2 := &registry.Service{Name: "foo.service", Version: "1"}
+	svc3 := &registry.Service{Name: "foo.service", Version: "1"}
+	register(r, "pod-1", svc1)
+	register(r, "pod-2", svc2)
+	register(r, "pod-3", svc3)
+
+	// pod-2 is not ready and pod-3 is terminating
+	mockClient.Pods["pod-2"].Status.Conditions[0].Status = "False"
+	mockClient.Pods["pod-3"].Metadata.DeletionTimestamp = time.Now().Format(time.RFC3339)
+
+	service, err := r.GetService("foo.service")
+	if err != nil {
+		t.Fatalf("did not expect GetService to fail %v", err)
+	}
+	if len(service) != 1 || len(service[0].Nodes) != 1 {
+		t.Fatalf("expected 1 service with 1 node got %+v", service)
+	}
+	if !hasNodes(service[0].Nodes, svc1.Nodes) {
+		t.Fatal("expected node of the ready pod")
+	}
+}
+
+func TestPodNameOption(t *testing.T) {
+	os.Setenv(podNameEnv, "pod-env")
+	defer os.Setenv(podNameEnv, "")
+
+	k := NewRegistry(registry.Addrs("http://localhost:8080")).(*kregistry)
+	if name := k.pod(); name != "pod-env" {
+		t.Fatalf("expected pod name from the downward api got %s", name)
+	}
+
+	// the option takes precedence
+	k = NewRegistry(registry.Addrs("http://localhost:8080"), PodName("pod-opt")).(*kregistry)
+	if name := k.pod(); name != "pod-opt" {
+		t.Fatalf("expected pod name from the option got %s", name)
+	}
+}
+
 func TestWatcher(t *testing.T) {
 	r := setupRegistry()
 	rtr := router.NewRouter(router.Registry(r))
@@ -495,3 +628,33 @@ func hasServices(a, b []*registry.Service) bool {
 	}
 	return found == len(b)
 }
+
+// querier is the Querier interface of registry/query
+type querier interface {
+	QueryServices(name, version string, metadata map[string]string) ([]*registry.Service, error)
+}
+
+func TestQuery(t *testing.T) {
+	r := setupRegistry()
+	defer teardownRegistry()
+
+	register(r, "pod-1", &registry.Service{Name: "foo.service", Version: "1"})
+	register(r, "pod-2", &registry.Service{Name: "foo.service", Version: "2"})
+	register(r, "pod-2", &registry.Service{Name: "bar.service", Version: "1"})
+
+	services, err := r.(querier).QueryServices("", "", nil)
+	if err != nil {
+		t.Fatalf("did not expect QueryServices to fail %v", err)
+	}
+	if len(services) != 3 {
+		t.Fatalf("expected 3 services got %+v", services)
+	}
+
+	services, err = r.(querier).QueryServices("foo.service", "", nil)
+	if err != nil {
+		t.Fatalf("did not expect QueryServices to fail %v", err)
+	}
+	if len(services) != 2 || services[0].Name != "foo.service" || services[1].Name != "foo.service" {
+		t.Fatalf("expected the versions of foo.service got %+v", services)
+	}
+}
@@ -0,0 +1,44 @@
+package kubernetes
+
+import (
+	"context"
+
+	"github.com/micro/go-micro/v2/registry"
+)
+
+type podNameKey struct{}
+type namespaceKey struct{}
+type readOnlyKey struct{}
+
+// PodName sets the name of the pod services are registered against. It
+// defaults to the POD_NAME environment variable set from the downward api
+// and then to HOSTNAME.
+func PodName(name string) registry.Option {
+	return setRegistryOption(podNameKey{}, name)
+}
+
+// Namespace sets the namespace of the pods. It defaults to the POD_NAMESPACE
+// environment variable set from the downward api and then to the namespace
+// of the service account.
+func Namespace(ns string) registry.Option {
+	return setRegistryOption(namespaceKey{}, ns)
+}
+
+// ReadOnly discovers services from kubernetes services and their endpoint
+// slices rather than pod annotations, so no write access to pods is needed.
+// Services with the label micro.mu/type=service are discovered, named by the
+// micro.mu/name annotation or otherwise the service name. Register and
+// Deregister do nothing.
+func ReadOnly() registry.Option {
+	return setRegistryOption(readOnlyKey{}, true)
+}
+
+// setRegistryOption returns a function to setup a context with given value
+func setRegistryOption(k, v interface{}) registry.Option {
+	return func(o *registry.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
@@ -146,7 +146,10 @@ func (k *k8sWatcher) handleEvent(event watch.Event) {
 		// service could have been added, edited or removed.
 		var results []*registry.Result
 
-		if pod.Status.Phase == podRunning {
+		// pods which aren't ready or are terminating are removed
+		ready := healthy(&pod)
+
+		if ready {
 			results = k.buildPodResults(&pod, cache)
 		} else {
 			// passing in cache might not return all results
@@ -154,8 +157,8 @@ func (k *k8sWatcher) handleEvent(event watch.Event) {
 		}
 
 		for _, result := range results {
-			// pod isnt running
-			if pod.Status.Phase != podRunning {
+			// pod isnt ready
+			if !ready {
 				result.Action = "delete"
 			}
 			k.next <- result
@@ -2,18 +2,53 @@ package multi
 
 import (
 	"context"
-	"sync"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	log "github.com/micro/go-micro/v2/logger"
 	"github.com/micro/go-micro/v2/registry"
 )
 
+var (
+	// ErrTimeout is returned for a registry which doesn't answer within the backend timeout
+	ErrTimeout = errors.New("registry timed out")
+
+	// MetadataRegistry is the node metadata key set to the registry which returned the node
+	MetadataRegistry = "multi.registry"
+)
+
+// Errors aggregates the failures of the underlying registries
+type Errors []error
+
+func (e Errors) Error() string {
+	errs := make([]string, len(e))
+	for i, err := range e {
+		errs[i] = err.Error()
+	}
+	return strings.Join(errs, "; ")
+}
+
 type multiRegistry struct {
 	r    []registry.Registry
 	w    []registry.Registry
 	opts registry.Options
+
+	write   WritePolicy
+	read    ReadPolicy
+	timeout time.Duration
+}
+
+// result of a call to a single registry
+type result struct {
+	reg  registry.Registry
+	svcs []*registry.Service
+	err  error
 }
 
+type callFunc func(r registry.Registry) ([]*registry.Service, error)
+
 func (m *multiRegistry) Init(opts ...registry.Option) error {
 	return configure(m, opts...)
 }
@@ -22,195 +57,245 @@ func (m *multiRegistry) Options() registry.Options {
 	return m.opts
 }
 
-func (m *multiRegistry) Register(s *registry.Service, opts ...registry.RegisterOption) error {
-	var wg sync.WaitGroup
-	var errs []error
-
-	done := make(chan bool)
-	cerr := make(chan error)
-
-	wg.Add(len(m.w))
+// do calls a single registry, giving up after the backend timeout
+func (m *multiRegistry) do(r registry.Registry, fn callFunc) result {
+	if m.timeout <= 0 {
+		svcs, err := fn(r)
+		return result{reg: r, svcs: svcs, err: err}
+	}
 
+	// buffered so the call can finish after we've given up on it
+	ch := make(chan result, 1)
 	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			case err := <-cerr:
-				errs = append(errs, err)
-				wg.Done()
-			}
-		}
+		svcs, err := fn(r)
+		ch <- result{reg: r, svcs: svcs, err: err}
 	}()
 
-	for _, mw := range m.w {
-		go func(w registry.Registry) {
-			if err := w.Register(s, opts...); err != nil {
-				cerr <- err
-			} else {
-				wg.Done()
-			}
-		}(mw)
+	t := time.NewTimer(m.timeout)
+	defer t.Stop()
+
+	select {
+	case res := <-ch:
+		return res
+	case <-t.C:
+		return result{reg: r, err: ErrTimeout}
 	}
+}
 
-	wg.Wait()
-	defer close(done)
+// all calls the registries in parallel, returning the results in order
+func (m *multiRegistry) all(regs []registry.Registry, fn callFunc) []result {
+	ch := make(chan int, len(regs))
+	results := make([]result, len(regs))
 
-	if len(errs) > 0 {
-		return errs[0]
+	for i, r := range regs {
+		go func(i int, r registry.Registry) {
+			results[i] = m.do(r, fn)
+			ch <- i
+		}(i, r)
 	}
 
-	return nil
+	for range regs {
+		<-ch
+	}
 
+	return results
 }
 
-func (m *multiRegistry) Deregister(s *registry.Service, opts ...registry.DeregisterOption) error {
-	var wg sync.WaitGroup
-	var errs []error
-
-	done := make(chan bool)
-	cerr := make(chan error)
-
-	wg.Add(len(m.w))
+// wrap names the registry an error came from
+func wrap(r registry.Registry, err error) error {
+	return fmt.Errorf("%s: %v", r.String(), err)
+}
 
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			case err := <-cerr:
-				errs = append(errs, err)
-				wg.Done()
-			}
+// written checks the results of a write against the write policy
+func (m *multiRegistry) written(results []result) error {
+	var errs Errors
+	for _, res := range results {
+		if res.err != nil {
+			errs = append(errs, wrap(res.reg, res.err))
 		}
-	}()
+	}
 
-	for _, mw := range m.w {
-		go func(w registry.Registry) {
-			if err := w.Deregister(s); err != nil {
-				cerr <- err
-			} else {
-				wg.Done()
-			}
-		}(mw)
+	ok := len(results) - len(errs)
+
+	var met bool
+	switch m.write {
+	case WriteQuorum:
+		met = ok > len(results)/2
+	case WriteAny:
+		met = ok > 0 || len(results) == 0
+	default:
+		met = len(errs) == 0
 	}
 
-	wg.Wait()
-	defer close(done)
+	if !met {
+		return errs
+	}
 
-	if len(errs) > 0 {
-		return errs[0]
+	for _, err := range errs {
+		log.Errorf("[multi] write failed: %v", err)
 	}
 
 	return nil
 }
 
-func (m *multiRegistry) GetService(n string, opts ...registry.GetOption) ([]*registry.Service, error) {
-	var wg sync.WaitGroup
-	var errs []error
-	var svcs []*registry.Service
-	var mu sync.Mutex
+func (m *multiRegistry) Register(s *registry.Service, opts ...registry.RegisterOption) error {
+	return m.written(m.all(m.w, func(r registry.Registry) ([]*registry.Service, error) {
+		return nil, r.Register(s, opts...)
+	}))
+}
 
-	done := make(chan bool)
-	cerr := make(chan error)
-	csvc := make(chan []*registry.Service)
+func (m *multiRegistry) Deregister(s *registry.Service, opts ...registry.DeregisterOption) error {
+	return m.written(m.all(m.w, func(r registry.Registry) ([]*registry.Service, error) {
+		return nil, r.Deregister(s, opts...)
+	}))
+}
 
-	wg.Add(len(m.r))
+// fallback calls the registries in order until one finds something
+func (m *multiRegistry) fallback(fn callFunc) ([]result, error) {
+	var errs Errors
 
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			case err := <-cerr:
-				errs = append(errs, err)
-				wg.Done()
-			case svc := <-csvc:
-				mu.Lock()
-				svcs = append(svcs, svc...)
-				mu.Unlock()
-				wg.Done()
-			}
+	for _, r := range m.r {
+		res := m.do(r, fn)
+		if res.err == registry.ErrNotFound {
+			continue
 		}
-	}()
+		if res.err != nil {
+			errs = append(errs, wrap(r, res.err))
+			continue
+		}
+		if len(res.svcs) == 0 {
+			continue
+		}
+		return []result{res}, nil
+	}
 
-	for _, mr := range m.r {
-		go func(r registry.Registry) {
-			svc, err := r.GetService(n)
-			if err != nil && err != registry.ErrNotFound {
-				cerr <- err
-			} else {
-				csvc <- svc
-			}
-		}(mr)
+	if len(errs) > 0 {
+		return nil, errs
 	}
 
-	wg.Wait()
-	defer close(done)
+	return nil, nil
+}
+
+// merge calls the registries in parallel, failing only if they all fail
+func (m *multiRegistry) merge(fn callFunc) ([]result, error) {
+	var errs Errors
+	var found []result
 
-	if len(errs) > 0 {
-		return nil, errs[0]
+	for _, res := range m.all(m.r, fn) {
+		if res.err == registry.ErrNotFound {
+			continue
+		}
+		if res.err != nil {
+			errs = append(errs, wrap(res.reg, res.err))
+			continue
+		}
+		found = append(found, res)
 	}
 
-	mu.Lock()
-	if len(svcs) == 0 {
-		return nil, registry.ErrNotFound
+	// everything we asked failed
+	if len(errs) > 0 && len(errs) == len(m.r) {
+		return nil, errs
 	}
-	mu.Unlock()
 
-	return svcs, nil
+	for _, err := range errs {
+		log.Errorf("[multi] read failed: %v", err)
+	}
+
+	return found, nil
 }
 
-func (m *multiRegistry) ListServices(opts ...registry.ListOption) ([]*registry.Service, error) {
-	var wg sync.WaitGroup
-	var errs []error
-	var svcs []*registry.Service
-	var mu sync.Mutex
+// readAll reads from the registries using the read policy
+func (m *multiRegistry) readAll(fn callFunc) ([]result, error) {
+	if m.read == ReadFallback {
+		return m.fallback(fn)
+	}
+	return m.merge(fn)
+}
 
-	done := make(chan bool)
-	cerr := make(chan error)
-	csvc := make(chan []*registry.Service)
+// GetService returns the service from the read registries. Services of the
+// same version are merged and every node has the name of the registry it
+// came from in its metadata.
+func (m *multiRegistry) GetService(n string, opts ...registry.GetOption) ([]*registry.Service, error) {
+	results, err := m.readAll(func(r registry.Registry) ([]*registry.Service, error) {
+		return r.GetService(n, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	wg.Add(len(m.r))
+	// services by version
+	versions := make(map[string]*registry.Service)
+	var svcs []*registry.Service
 
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			case err := <-cerr:
-				errs = append(errs, err)
-				wg.Done()
-			case svc := <-csvc:
-				mu.Lock()
-				svcs = append(svcs, svc...)
-				mu.Unlock()
-				wg.Done()
+	for _, res := range results {
+		for _, svc := range res.svcs {
+			s, ok := versions[svc.Version]
+			if !ok {
+				s = new(registry.Service)
+				*s = *svc
+				s.Nodes = nil
+				versions[svc.Version] = s
+				svcs = append(svcs, s)
 			}
-		}
-	}()
 
-	for _, mr := range m.r {
-		go func(r registry.Registry) {
-			if svc, err := r.ListServices(); err != nil {
-				cerr <- err
-			} else {
-				csvc <- svc
+			for _, node := range svc.Nodes {
+				if hasNode(s.Nodes, node.Id) {
+					continue
+				}
+
+				// copy so we don't change the registry's node
+				nd := new(registry.Node)
+				*nd = *node
+				nd.Metadata = make(map[string]string, len(node.Metadata)+1)
+				for k, v := range node.Metadata {
+					nd.Metadata[k] = v
+				}
+				nd.Metadata[MetadataRegistry] = res.reg.String()
+
+				s.Nodes = append(s.Nodes, nd)
 			}
-		}(mr)
+		}
 	}
 
-	wg.Wait()
-	defer close(done)
+	if len(svcs) == 0 {
+		return nil, registry.ErrNotFound
+	}
 
-	if len(errs) > 0 {
-		return nil, errs[0]
+	return svcs, nil
+}
+
+func hasNode(nodes []*registry.Node, id string) bool {
+	for _, node := range nodes {
+		if node.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *multiRegistry) ListServices(opts ...registry.ListOption) ([]*registry.Service, error) {
+	results, err := m.readAll(func(r registry.Registry) ([]*registry.Service, error) {
+		return r.ListServices(opts...)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	mu.Lock()
-	ret := svcs
-	mu.Unlock()
-	return ret, nil
+	seen := make(map[string]bool)
+	var svcs []*registry.Service
+
+	for _, res := range results {
+		for _, svc := range res.svcs {
+			key := svc.Name + ":" + svc.Version
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			svcs = append(svcs, svc)
+		}
+	}
+
+	return svcs, nil
 }
 
 func (m *multiRegistry) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
@@ -248,7 +333,18 @@ func configure(m *multiRegistry, opts ...registry.Option) error {
 	m.r = m.w
 
 	if r, ok := m.opts.Context.Value(readKey{}).([]registry.Registry); ok && r != nil {
-		m.r = append(m.r, r...)
+		m.r = append(append([]registry.Registry{}, m.r...), r...)
 	}
+
+	if p, ok := m.opts.Context.Value(writePolicyKey{}).(WritePolicy); ok {
+		m.write = p
+	}
+	if p, ok := m.opts.Context.Value(readPolicyKey{}).(ReadPolicy); ok {
+		m.read = p
+	}
+	if d, ok := m.opts.Context.Value(backendTimeoutKey{}).(time.Duration); ok {
+		m.timeout = d
+	}
+
 	return nil
 }
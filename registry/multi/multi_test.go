@@ -0,0 +1,177 @@
+package multi
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v2/registry"
+	"github.com/micro/go-micro/v2/registry/memory"
+)
+
+// downRegistry fails or hangs every call
+type downRegistry struct {
+	registry.Registry
+	hang time.Duration
+}
+
+var errDown = errors.New("down")
+
+func (d *downRegistry) fail() error {
+	time.Sleep(d.hang)
+	return errDown
+}
+
+func (d *downRegistry) Register(*registry.Service, ...registry.RegisterOption) error {
+	return d.fail()
+}
+
+func (d *downRegistry) Deregister(*registry.Service, ...registry.DeregisterOption) error {
+	return d.fail()
+}
+
+func (d *downRegistry) GetService(string, ...registry.GetOption) ([]*registry.Service, error) {
+	return nil, d.fail()
+}
+
+func (d *downRegistry) ListServices(...registry.ListOption) ([]*registry.Service, error) {
+	return nil, d.fail()
+}
+
+func (d *downRegistry) String() string {
+	return "down"
+}
+
+var testService = &registry.Service{
+	Name:    "foo",
+	Version: "1",
+	Nodes: []*registry.Node{
+		{Id: "foo-1", Address: "localhost:9090"},
+	},
+}
+
+func TestWritePolicy(t *testing.T) {
+	testCases := []struct {
+		policy WritePolicy
+		down   int
+		err    bool
+	}{
+		{WriteAll, 0, false},
+		{WriteAll, 1, true},
+		{WriteQuorum, 1, false},
+		{WriteQuorum, 2, true},
+		{WriteAny, 2, false},
+		{WriteAny, 3, true},
+	}
+
+	for _, test := range testCases {
+		var regs []registry.Registry
+		for i := 0; i < 3; i++ {
+			if i < test.down {
+				regs = append(regs, &downRegistry{})
+			} else {
+				regs = append(regs, memory.NewRegistry())
+			}
+		}
+
+		r := NewRegistry(WriteRegistry(regs...), Write(test.policy))
+		err := r.Register(testService)
+		if test.err && err == nil {
+			t.Fatalf("expected error for policy %d with %d down", test.policy, test.down)
+		}
+		if !test.err && err != nil {
+			t.Fatalf("unexpected error for policy %d with %d down: %v", test.policy, test.down, err)
+		}
+
+		if errs, ok := err.(Errors); test.err && (!ok || len(errs) != test.down) {
+			t.Fatalf("expected %d errors got %v", test.down, err)
+		}
+	}
+}
+
+func TestReadPolicy(t *testing.T) {
+	first := memory.NewRegistry()
+	second := memory.NewRegistry()
+
+	second.Register(testService)
+	first.Register(&registry.Service{
+		Name:    "foo",
+		Version: "1",
+		Nodes:   []*registry.Node{{Id: "foo-2", Address: "localhost:9091"}},
+	})
+
+	// a hung registry times out and the others still answer
+	down := &downRegistry{hang: time.Second}
+
+	r := NewRegistry(
+		WriteRegistry(first),
+		ReadRegistry(down, second),
+		BackendTimeout(time.Millisecond*50),
+	)
+
+	svcs, err := r.GetService("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(svcs) != 1 || len(svcs[0].Nodes) != 2 {
+		t.Fatalf("expected 1 service with 2 nodes got %+v", svcs)
+	}
+	for _, node := range svcs[0].Nodes {
+		if node.Metadata[MetadataRegistry] != "memory" {
+			t.Fatalf("expected node from the memory registry got %v", node.Metadata)
+		}
+	}
+
+	// fallback returns the first registry with the service
+	r = NewRegistry(
+		WriteRegistry(first),
+		ReadRegistry(down, second),
+		Read(ReadFallback),
+		BackendTimeout(time.Millisecond*50),
+	)
+
+	svcs, err = r.GetService("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(svcs) != 1 || len(svcs[0].Nodes) != 1 || svcs[0].Nodes[0].Id != "foo-2" {
+		t.Fatalf("expected node foo-2 from the first registry got %+v", svcs)
+	}
+
+	// every registry failing is an error
+	r = NewRegistry(ReadRegistry(down, &downRegistry{}), BackendTimeout(time.Millisecond*50))
+	if _, err := r.GetService("foo"); err == nil {
+		t.Fatal("expected error when every registry fails")
+	} else if errs, ok := err.(Errors); !ok || len(errs) != 2 {
+		t.Fatalf("expected 2 errors got %v", err)
+	}
+}
+
+func TestNodeRegistry(t *testing.T) {
+	// nodes registered by go-micro carry the name of their own registry
+	reg := memory.NewRegistry()
+	reg.Register(&registry.Service{
+		Name:    "foo",
+		Version: "1",
+		Nodes: []*registry.Node{{
+			Id:       "foo-1",
+			Address:  "localhost:9090",
+			Metadata: map[string]string{"registry": "mdns"},
+		}},
+	})
+
+	r := NewRegistry(WriteRegistry(reg))
+
+	svcs, err := r.GetService("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(svcs) != 1 || len(svcs[0].Nodes) != 1 {
+		t.Fatalf("expected 1 service with 1 node got %+v", svcs)
+	}
+
+	md := svcs[0].Nodes[0].Metadata
+	if md[MetadataRegistry] != "memory" || md["registry"] != "mdns" {
+		t.Fatalf("expected the node from the memory registry with its own metadata got %v", md)
+	}
+}
@@ -2,12 +2,41 @@ package multi
 
 import (
 	"context"
+	"time"
 
 	"github.com/micro/go-micro/v2/registry"
 )
 
+// WritePolicy is how many write registries must succeed for
+// Register and Deregister to succeed
+type WritePolicy int
+
+const (
+	// WriteAll requires every write registry to succeed
+	WriteAll WritePolicy = iota
+	// WriteQuorum requires a majority of the write registries to succeed
+	WriteQuorum
+	// WriteAny requires a single write registry to succeed
+	WriteAny
+)
+
+// ReadPolicy is how the registries are used for GetService and ListServices
+type ReadPolicy int
+
+const (
+	// ReadMerge reads from every registry in parallel and merges the
+	// results, failing only if every registry fails
+	ReadMerge ReadPolicy = iota
+	// ReadFallback reads from the registries in order, write registries
+	// first, returning the first result found
+	ReadFallback
+)
+
 type writeKey struct{}
 type readKey struct{}
+type writePolicyKey struct{}
+type readPolicyKey struct{}
+type backendTimeoutKey struct{}
 
 // helper for setting registry options
 func setRegistryOption(k, v interface{}) registry.Option {
@@ -28,3 +57,19 @@ func WriteRegistry(w ...registry.Registry) registry.Option {
 func ReadRegistry(r ...registry.Registry) registry.Option {
 	return setRegistryOption(readKey{}, r)
 }
+
+// Write sets the write policy, defaults to WriteAll
+func Write(p WritePolicy) registry.Option {
+	return setRegistryOption(writePolicyKey{}, p)
+}
+
+// Read sets the read policy, defaults to ReadMerge
+func Read(p ReadPolicy) registry.Option {
+	return setRegistryOption(readPolicyKey{}, p)
+}
+
+// BackendTimeout limits each call to an underlying registry, a registry
+// which doesn't answer in time is treated as failed
+func BackendTimeout(d time.Duration) registry.Option {
+	return setRegistryOption(backendTimeoutKey{}, d)
+}
@@ -0,0 +1,271 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/micro/go-micro/v2/registry"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+var (
+	// how long the bucket keeps the delete marker of an expired key,
+	// watchers see the expiry as a delete
+	limitMarkerTTL = time.Minute
+)
+
+// kvStore keeps the registrations in a key value bucket. Each node is
+// stored under its own key so nodes expire independently.
+type kvStore struct {
+	js      jetstream.JetStream
+	kv      jetstream.KeyValue
+	bucket  string
+	timeout time.Duration
+}
+
+func newStore(conn *nats.Conn, bucket string, timeout time.Duration) (*kvStore, error) {
+	js, err := jetstream.New(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	kv, err := js.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{
+		Bucket:         bucket,
+		Description:    "micro registry",
+		History:        1,
+		LimitMarkerTTL: limitMarkerTTL,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &kvStore{
+		js:      js,
+		kv:      kv,
+		bucket:  bucket,
+		timeout: timeout,
+	}, nil
+}
+
+func (k *kvStore) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), k.timeout)
+}
+
+func (k *kvStore) register(s *registry.Service, ttl time.Duration) error {
+	ctx, cancel := k.context()
+	defer cancel()
+
+	for key, srv := range nodeServices(s) {
+		b, err := json.Marshal(srv)
+		if err != nil {
+			return err
+		}
+		if err := k.put(ctx, key, b, ttl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// put sets a key. Create only sets the ttl of new keys so the key is
+// published directly to refresh the ttl of an existing registration.
+func (k *kvStore) put(ctx context.Context, key string, b []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		_, err := k.kv.Put(ctx, key, b)
+		return err
+	}
+
+	_, err := k.js.PublishMsg(ctx, &nats.Msg{
+		Subject: "$KV." + k.bucket + "." + key,
+		Data:    b,
+	}, jetstream.WithMsgTTL(ttl))
+	return err
+}
+
+func (k *kvStore) deregister(s *registry.Service) error {
+	ctx, cancel := k.context()
+	defer cancel()
+
+	for key := range nodeServices(s) {
+		if err := k.kv.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (k *kvStore) getService(name string) ([]*registry.Service, error) {
+	ctx, cancel := k.context()
+	defer cancel()
+
+	w, err := k.kv.Watch(ctx, encodeKey(name)+".>", jetstream.IgnoreDeletes())
+	if err != nil {
+		return nil, err
+	}
+	defer w.Stop()
+
+	var services []*registry.Service
+
+	for {
+		select {
+		case entry := <-w.Updates():
+			// nil once the current values have been read
+			if entry == nil {
+				return services, nil
+			}
+			var s *registry.Service
+			if err := json.Unmarshal(entry.Value(), &s); err != nil {
+				continue
+			}
+			services = addServices(services, []*registry.Service{s})
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (k *kvStore) listServices() ([]*registry.Service, error) {
+	ctx, cancel := k.context()
+	defer cancel()
+
+	lister, err := k.kv.ListKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer lister.Stop()
+
+	var services []*registry.Service
+	seen := make(map[string]bool)
+
+	// the keys are streamed from the bucket
+	for key := range lister.Keys() {
+		name, _, _, err := decodeNodeKey(key)
+		if err != nil || seen[name] {
+			continue
+		}
+		seen[name] = true
+		services = append(services, &registry.Service{Name: name})
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return services, nil
+}
+
+func (k *kvStore) watch(wo registry.WatchOptions) (registry.Watcher, error) {
+	keys := jetstream.AllKeys
+	if len(wo.Service) > 0 {
+		keys = encodeKey(wo.Service) + ".>"
+	}
+
+	w, err := k.kv.Watch(context.Background(), keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kvWatcher{
+		w:        w,
+		wo:       wo,
+		services: make(map[string]*registry.Service),
+	}, nil
+}
+
+// nodeServices splits a service into a service for each node keyed by
+// the node, a service without nodes is stored under an empty node id
+func nodeServices(s *registry.Service) map[string]*registry.Service {
+	services := make(map[string]*registry.Service)
+
+	if len(s.Nodes) == 0 {
+		srv := cp([]*registry.Service{s})[0]
+		services[nodeKey(s.Name, s.Version, "")] = srv
+		return services
+	}
+
+	for _, node := range s.Nodes {
+		srv := cp([]*registry.Service{s})[0]
+		n := *node
+		srv.Nodes = []*registry.Node{&n}
+		services[nodeKey(s.Name, s.Version, node.Id)] = srv
+	}
+
+	return services
+}
+
+// nodeKey is the key of a node, the name, version and node id are
+// encoded as tokens of the key so a service can be watched by name
+func nodeKey(name, version, id string) string {
+	return encodeKey(name) + "." + encodeKey(version) + "." + encodeKey(id)
+}
+
+func decodeNodeKey(key string) (name, version, id string, err error) {
+	parts := strings.Split(key, ".")
+	if len(parts) != 3 {
+		return "", "", "", fmt.Errorf("invalid key %s", key)
+	}
+	if name, err = decodeKey(parts[0]); err != nil {
+		return "", "", "", err
+	}
+	if version, err = decodeKey(parts[1]); err != nil {
+		return "", "", "", err
+	}
+	if id, err = decodeKey(parts[2]); err != nil {
+		return "", "", "", err
+	}
+	return name, version, id, nil
+}
+
+// encodeKey escapes the characters not allowed in a token of a key as
+// =XX, an empty string is encoded as = since tokens can't be empty
+func encodeKey(s string) string {
+	if len(s) == 0 {
+		return "="
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "=%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func decodeKey(s string) (string, error) {
+	if s == "=" {
+		return "", nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '=' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+3 > len(s) {
+			return "", fmt.Errorf("invalid key token %s", s)
+		}
+		c, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("invalid key token %s", s)
+		}
+		b.WriteByte(byte(c))
+		i += 2
+	}
+	return b.String(), nil
+}
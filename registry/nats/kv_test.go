@@ -0,0 +1,143 @@
+package nats
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v2/registry"
+)
+
+func TestKeyEncoding(t *testing.T) {
+	testData := []struct {
+		name    string
+		version string
+		id      string
+		key     string
+	}{
+		{"go.micro.srv.foo", "latest", "foo-1", "go=2Emicro=2Esrv=2Efoo.latest.foo-1"},
+		{"foo", "", "", "foo.=.="},
+		{"foo bar", "1.0.0", "a:b", "foo=20bar.1=2E0=2E0.a=3Ab"},
+	}
+
+	for _, d := range testData {
+		key := nodeKey(d.name, d.version, d.id)
+		if key != d.key {
+			t.Fatalf("expected key %s got %s", d.key, key)
+		}
+		name, version, id, err := decodeNodeKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != d.name || version != d.version || id != d.id {
+			t.Fatalf("expected %s %s %s got %s %s %s", d.name, d.version, d.id, name, version, id)
+		}
+	}
+
+	for _, key := range []string{"foo", "foo.bar", "foo=2.bar.baz", "foo=ZZ.bar.baz"} {
+		if _, _, _, err := decodeNodeKey(key); err == nil {
+			t.Fatalf("expected an error decoding %s", key)
+		}
+	}
+}
+
+func TestKeyValue(t *testing.T) {
+	natsURL := os.Getenv("NATS_URL")
+	if natsURL == "" {
+		t.Skip("NATS_URL is undefined")
+	}
+
+	// the registries still answer queries for the services they
+	// registered, keep those away from the query mode tests
+	opts := []registry.Option{
+		registry.Addrs(natsURL),
+		KeyValue("micro-registry-test"),
+		QueryTopic("micro.registry.kv.test.query"),
+		WatchTopic("micro.registry.kv.test.watch"),
+	}
+
+	r1 := NewRegistry(opts...)
+	r2 := NewRegistry(opts...)
+
+	w, err := r2.Watch(registry.WatchService("kv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	service := &registry.Service{
+		Name:    "kv",
+		Version: "1.0.0",
+		Nodes: []*registry.Node{
+			{Id: "kv-1", Address: "10.0.0.1:8080"},
+			{Id: "kv-2", Address: "10.0.0.2:8080"},
+		},
+	}
+
+	if err := r1.Register(service, registry.RegisterTTL(time.Second*2)); err != nil {
+		t.Fatal(err)
+	}
+
+	services, err := r2.GetService("kv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || len(services[0].Nodes) != 2 {
+		t.Fatalf("expected 1 service with 2 nodes got %+v", services)
+	}
+
+	list, err := r2.ListServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, s := range list {
+		found = found || s.Name == "kv"
+	}
+	if !found {
+		t.Fatalf("expected kv in %+v", list)
+	}
+
+	for i := 0; i < 2; i++ {
+		res, err := w.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Action != "create" || res.Service.Name != "kv" {
+			t.Fatalf("expected create kv got %s %s", res.Action, res.Service.Name)
+		}
+	}
+
+	if err := r1.Deregister(&registry.Service{
+		Name:    "kv",
+		Version: "1.0.0",
+		Nodes:   service.Nodes[:1],
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := w.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Action != "delete" || res.Service.Nodes[0].Id != "kv-1" {
+		t.Fatalf("expected delete kv-1 got %s %+v", res.Action, res.Service.Nodes)
+	}
+
+	// the other node expires
+	res, err = w.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Action != "delete" || res.Service.Nodes[0].Id != "kv-2" {
+		t.Fatalf("expected delete kv-2 got %s %+v", res.Action, res.Service.Nodes)
+	}
+
+	services, err = r2.GetService("kv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 0 {
+		t.Fatalf("expected no services got %+v", services)
+	}
+}
@@ -1,4 +1,5 @@
-// Package nats provides a NATS registry using broadcast queries
+// Package nats provides a NATS registry using broadcast queries, or a
+// JetStream key value bucket with the KeyValue option
 package nats
 
 import (
@@ -19,9 +20,11 @@ type natsRegistry struct {
 	nopts      nats.Options
 	queryTopic string
 	watchTopic string
+	bucket     string
 
 	sync.RWMutex
 	conn      *nats.Conn
+	store     *kvStore
 	services  map[string][]*registry.Service
 	listeners map[string]chan bool
 }
@@ -55,6 +58,8 @@ func configure(n *natsRegistry, opts ...registry.Option) error {
 		watchTopic = wt
 	}
 
+	bucket, _ := n.opts.Context.Value(bucketKey{}).(string)
+
 	// registry.Options have higher priority than nats.Options
 	// only if Addrs, Secure or TLSConfig were not set through a registry.Option
 	// we read them from nats.Option
@@ -78,6 +83,7 @@ func configure(n *natsRegistry, opts ...registry.Option) error {
 	n.nopts = natsOptions
 	n.queryTopic = queryTopic
 	n.watchTopic = watchTopic
+	n.bucket = bucket
 
 	return nil
 }
@@ -129,6 +135,38 @@ func (n *natsRegistry) getConn() (*nats.Conn, error) {
 	return n.conn, nil
 }
 
+// getStore returns the key value store, nil unless the KeyValue option is set
+func (n *natsRegistry) getStore() (*kvStore, error) {
+	if len(n.bucket) == 0 {
+		return nil, nil
+	}
+
+	conn, err := n.getConn()
+	if err != nil {
+		return nil, err
+	}
+
+	n.Lock()
+	defer n.Unlock()
+
+	if n.store != nil && n.store.bucket == n.bucket {
+		return n.store, nil
+	}
+
+	timeout := n.nopts.Timeout
+	if timeout <= 0 {
+		timeout = nats.DefaultTimeout
+	}
+
+	store, err := newStore(conn, n.bucket, timeout)
+	if err != nil {
+		return nil, err
+	}
+	n.store = store
+
+	return n.store, nil
+}
+
 func (n *natsRegistry) register(s *registry.Service) error {
 	conn, err := n.getConn()
 	if err != nil {
@@ -313,6 +351,22 @@ func (n *natsRegistry) Options() registry.Options {
 }
 
 func (n *natsRegistry) Register(s *registry.Service, opts ...registry.RegisterOption) error {
+	var options registry.RegisterOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	store, err := n.getStore()
+	if err != nil {
+		return err
+	}
+	if store != nil {
+		if err := store.register(s, options.TTL); err != nil {
+			return err
+		}
+	}
+
+	// registries not using the bucket still query and watch the service
 	if err := n.register(s); err != nil {
 		return err
 	}
@@ -331,6 +385,16 @@ func (n *natsRegistry) Register(s *registry.Service, opts ...registry.RegisterOp
 }
 
 func (n *natsRegistry) Deregister(s *registry.Service, opts ...registry.DeregisterOption) error {
+	store, err := n.getStore()
+	if err != nil {
+		return err
+	}
+	if store != nil {
+		if err := store.deregister(s); err != nil {
+			return err
+		}
+	}
+
 	if err := n.deregister(s); err != nil {
 		return err
 	}
@@ -348,6 +412,14 @@ func (n *natsRegistry) Deregister(s *registry.Service, opts ...registry.Deregist
 }
 
 func (n *natsRegistry) GetService(s string, opts ...registry.GetOption) ([]*registry.Service, error) {
+	store, err := n.getStore()
+	if err != nil {
+		return nil, err
+	}
+	if store != nil {
+		return store.getService(s)
+	}
+
 	services, err := n.query(s, getQuorum(n.opts))
 	if err != nil {
 		return nil, err
@@ -356,6 +428,14 @@ func (n *natsRegistry) GetService(s string, opts ...registry.GetOption) ([]*regi
 }
 
 func (n *natsRegistry) ListServices(opts ...registry.ListOption) ([]*registry.Service, error) {
+	store, err := n.getStore()
+	if err != nil {
+		return nil, err
+	}
+	if store != nil {
+		return store.listServices()
+	}
+
 	s, err := n.query("", 0)
 	if err != nil {
 		return nil, err
@@ -376,19 +456,27 @@ func (n *natsRegistry) ListServices(opts ...registry.ListOption) ([]*registry.Se
 }
 
 func (n *natsRegistry) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
-	conn, err := n.getConn()
+	var wo registry.WatchOptions
+	for _, o := range opts {
+		o(&wo)
+	}
+
+	store, err := n.getStore()
 	if err != nil {
 		return nil, err
 	}
+	if store != nil {
+		return store.watch(wo)
+	}
 
-	sub, err := conn.SubscribeSync(n.watchTopic)
+	conn, err := n.getConn()
 	if err != nil {
 		return nil, err
 	}
 
-	var wo registry.WatchOptions
-	for _, o := range opts {
-		o(&wo)
+	sub, err := conn.SubscribeSync(n.watchTopic)
+	if err != nil {
+		return nil, err
 	}
 
 	return &natsWatcher{sub, wo}, nil
@@ -11,9 +11,12 @@ type contextQuorumKey struct{}
 type optionsKey struct{}
 type watchTopicKey struct{}
 type queryTopicKey struct{}
+type bucketKey struct{}
 
 var (
 	DefaultQuorum = 0
+	// DefaultBucket is the key value bucket of the registry
+	DefaultBucket = "micro-registry"
 )
 
 func getQuorum(o registry.Options) int {
@@ -70,3 +73,21 @@ func WatchTopic(s string) registry.Option {
 		o.Context = context.WithValue(o.Context, watchTopicKey{}, s)
 	}
 }
+
+// KeyValue stores registrations in a JetStream key value bucket rather
+// than querying the other registries. Lookups read the bucket directly,
+// registrations expire with their RegisterTTL and watchers are driven by
+// watches of the bucket. Requires nats-server 2.11 or later with JetStream
+// enabled. The per key ttls are why the module needs nats.go v1.42.0 and
+// so go 1.23. An empty bucket uses DefaultBucket.
+func KeyValue(bucket string) registry.Option {
+	return func(o *registry.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		if len(bucket) == 0 {
+			bucket = DefaultBucket
+		}
+		o.Context = context.WithValue(o.Context, bucketKey{}, bucket)
+	}
+}
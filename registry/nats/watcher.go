@@ -6,6 +6,7 @@ import (
 
 	"github.com/micro/go-micro/v2/registry"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 )
 
 type natsWatcher struct {
@@ -37,3 +38,72 @@ func (n *natsWatcher) Next() (*registry.Result, error) {
 func (n *natsWatcher) Stop() {
 	n.sub.Unsubscribe()
 }
+
+// kvWatcher watches the key value bucket, the last value of each key is
+// kept to return the service of deleted and expired nodes
+type kvWatcher struct {
+	w        jetstream.KeyWatcher
+	wo       registry.WatchOptions
+	synced   bool
+	services map[string]*registry.Service
+}
+
+func (k *kvWatcher) Next() (*registry.Result, error) {
+	for entry := range k.w.Updates() {
+		// the current values are followed by nil
+		if entry == nil {
+			k.synced = true
+			continue
+		}
+
+		key := entry.Key()
+
+		switch entry.Operation() {
+		case jetstream.KeyValuePut:
+			var service *registry.Service
+			if err := json.Unmarshal(entry.Value(), &service); err != nil {
+				continue
+			}
+
+			_, ok := k.services[key]
+			k.services[key] = service
+
+			if !k.synced {
+				continue
+			}
+
+			action := "create"
+			if ok {
+				action = "update"
+			}
+
+			return &registry.Result{Action: action, Service: service}, nil
+		default:
+			service, ok := k.services[key]
+			delete(k.services, key)
+
+			if !k.synced {
+				continue
+			}
+
+			if !ok {
+				name, version, id, err := decodeNodeKey(key)
+				if err != nil {
+					continue
+				}
+				service = &registry.Service{Name: name, Version: version}
+				if len(id) > 0 {
+					service.Nodes = []*registry.Node{{Id: id}}
+				}
+			}
+
+			return &registry.Result{Action: "delete", Service: service}, nil
+		}
+	}
+
+	return nil, registry.ErrWatcherStopped
+}
+
+func (k *kvWatcher) Stop() {
+	k.w.Stop()
+}
@@ -0,0 +1,86 @@
+package query
+
+import (
+	"context"
+)
+
+// Options of a query
+type Options struct {
+	// Service name, all services if blank
+	Service string
+	// Version of the services
+	Version string
+	// Metadata the nodes must have
+	Metadata map[string]string
+	// Endpoint the services must serve
+	Endpoint string
+	// Limit the number of services returned, 0 for no limit
+	Limit int
+	// Token of the page to return from a previous result
+	Token string
+
+	// Other options for implementations of the interface
+	// can be stored in a context
+	Context context.Context
+}
+
+// Option sets an option of a query
+type Option func(*Options)
+
+// NewOptions returns the options of a query
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Metadata: make(map[string]string),
+		Context:  context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
+
+// Service limits the query to a service
+func Service(name string) Option {
+	return func(o *Options) {
+		o.Service = name
+	}
+}
+
+// Version limits the query to a version of the services
+func Version(v string) Option {
+	return func(o *Options) {
+		o.Version = v
+	}
+}
+
+// Metadata selects the nodes with the metadata key set to value,
+// services without any matching nodes aren't returned
+func Metadata(key, value string) Option {
+	return func(o *Options) {
+		if o.Metadata == nil {
+			o.Metadata = make(map[string]string)
+		}
+		o.Metadata[key] = value
+	}
+}
+
+// Endpoint limits the query to services serving an endpoint
+func Endpoint(name string) Option {
+	return func(o *Options) {
+		o.Endpoint = name
+	}
+}
+
+// Limit sets the maximum number of services returned
+func Limit(n int) Option {
+	return func(o *Options) {
+		o.Limit = n
+	}
+}
+
+// Token returns the page after a previous result
+func Token(t string) Option {
+	return func(o *Options) {
+		o.Token = t
+	}
+}
@@ -0,0 +1,258 @@
+// Package query provides filtered and paginated service queries over the registry plugins
+package query
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/micro/go-micro/v2/registry"
+)
+
+var (
+	// ErrInvalidToken is returned for a page token which can't be decoded
+	ErrInvalidToken = errors.New("invalid page token")
+)
+
+// Querier is implemented by registries which push a query down to their
+// backend. QueryServices returns the services of the name, all services if
+// the name is blank, and may leave out the services without the version and
+// the nodes without the metadata. The services returned are filtered and
+// paged in process so only what the backend selects on needs pushing down.
+// The interface only uses go-micro types so registries implement it without
+// importing this package.
+type Querier interface {
+	QueryServices(name, version string, metadata map[string]string) ([]*registry.Service, error)
+}
+
+// NameQuerier is implemented by registries which list the names of the
+// services with the version and metadata without getting the services.
+// When a query isn't limited to a service, the services of each name are
+// got in order until there's more than a page.
+type NameQuerier interface {
+	Querier
+	QueryNames(version string, metadata map[string]string) ([]string, error)
+}
+
+// Result is a page of services
+type Result struct {
+	// Services ordered by name and version
+	Services []*registry.Service
+	// Token of the next page, blank on the last page
+	Token string
+}
+
+// Query queries a registry. The query is pushed down to the backend if the
+// registry is a Querier, otherwise the services are filtered in process.
+func Query(r registry.Registry, opts ...Option) (*Result, error) {
+	options := NewOptions(opts...)
+
+	if q, ok := r.(NameQuerier); ok && len(options.Service) == 0 {
+		names, err := q.QueryNames(options.Version, options.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		return Collect(names, options, func(name string) ([]*registry.Service, error) {
+			return q.QueryServices(name, options.Version, options.Metadata)
+		})
+	}
+
+	if q, ok := r.(Querier); ok {
+		services, err := q.QueryServices(options.Service, options.Version, options.Metadata)
+		if err == registry.ErrNotFound {
+			return &Result{}, nil
+		} else if err != nil {
+			return nil, err
+		}
+		return Page(Filter(services, options), options)
+	}
+
+	if len(options.Service) > 0 {
+		services, err := r.GetService(options.Service)
+		if err == registry.ErrNotFound {
+			return &Result{}, nil
+		} else if err != nil {
+			return nil, err
+		}
+		return Page(Filter(services, options), options)
+	}
+
+	list, err := r.ListServices()
+	if err != nil {
+		return nil, err
+	}
+
+	// the list may only contain names
+	names := make([]string, 0, len(list))
+	for _, s := range list {
+		names = append(names, s.Name)
+	}
+
+	return Collect(names, options, func(name string) ([]*registry.Service, error) {
+		return r.GetService(name)
+	})
+}
+
+// Collect gets the services of each name in order until there's more than
+// a page, for backends which list names and then get each service. Names
+// before the page token are skipped and services not found are ignored.
+func Collect(names []string, options Options, get func(name string) ([]*registry.Service, error)) (*Result, error) {
+	after, err := decodeToken(options.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+
+	var services []*registry.Service
+	for i, name := range names {
+		if (i > 0 && name == names[i-1]) || name < after.name {
+			continue
+		}
+		if options.Limit > 0 && len(services) > options.Limit {
+			break
+		}
+
+		srvs, err := get(name)
+		if err == registry.ErrNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		for _, s := range Filter(srvs, options) {
+			if key(s).after(after) {
+				services = append(services, s)
+			}
+		}
+	}
+
+	return Page(services, options)
+}
+
+// Match returns true if a node has the metadata of a query
+func Match(node *registry.Node, options Options) bool {
+	for k, v := range options.Metadata {
+		if val, ok := node.Metadata[k]; !ok || val != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns the services matching a query. Services are copied
+// when nodes are removed so the services passed in aren't changed.
+func Filter(services []*registry.Service, options Options) []*registry.Service {
+	var filtered []*registry.Service
+
+	for _, s := range services {
+		if len(options.Service) > 0 && s.Name != options.Service {
+			continue
+		}
+		if len(options.Version) > 0 && s.Version != options.Version {
+			continue
+		}
+		if len(options.Endpoint) > 0 && !serves(s, options.Endpoint) {
+			continue
+		}
+
+		if len(options.Metadata) == 0 {
+			filtered = append(filtered, s)
+			continue
+		}
+
+		var nodes []*registry.Node
+		for _, node := range s.Nodes {
+			if Match(node, options) {
+				nodes = append(nodes, node)
+			}
+		}
+
+		if len(nodes) == 0 {
+			continue
+		}
+
+		if len(nodes) < len(s.Nodes) {
+			srv := *s
+			srv.Nodes = nodes
+			s = &srv
+		}
+
+		filtered = append(filtered, s)
+	}
+
+	return filtered
+}
+
+// Page orders services by name and version and returns the page after
+// the token of a query
+func Page(services []*registry.Service, options Options) (*Result, error) {
+	after, err := decodeToken(options.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(services, func(i, j int) bool {
+		return key(services[j]).after(key(services[i]))
+	})
+
+	i := sort.Search(len(services), func(i int) bool {
+		return key(services[i]).after(after)
+	})
+	services = services[i:]
+
+	result := &Result{Services: services}
+
+	if options.Limit > 0 && len(services) > options.Limit {
+		result.Services = services[:options.Limit]
+		result.Token = encodeToken(key(result.Services[options.Limit-1]))
+	}
+
+	return result, nil
+}
+
+func serves(s *registry.Service, endpoint string) bool {
+	for _, ep := range s.Endpoints {
+		if ep.Name == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// pageKey orders services
+type pageKey struct {
+	name    string
+	version string
+}
+
+func key(s *registry.Service) pageKey {
+	return pageKey{name: s.Name, version: s.Version}
+}
+
+func (k pageKey) after(o pageKey) bool {
+	if k.name != o.name {
+		return k.name > o.name
+	}
+	return k.version > o.version
+}
+
+func encodeToken(k pageKey) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(k.name + "\n" + k.version))
+}
+
+func decodeToken(t string) (pageKey, error) {
+	if len(t) == 0 {
+		return pageKey{}, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(t)
+	if err != nil {
+		return pageKey{}, ErrInvalidToken
+	}
+	parts := strings.SplitN(string(b), "\n", 2)
+	if len(parts) != 2 {
+		return pageKey{}, ErrInvalidToken
+	}
+	return pageKey{name: parts[0], version: parts[1]}, nil
+}
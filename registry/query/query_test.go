@@ -0,0 +1,204 @@
+package query
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/micro/go-micro/v2/registry"
+	"github.com/micro/go-micro/v2/registry/memory"
+)
+
+func testRegistry(t *testing.T) registry.Registry {
+	r := memory.NewRegistry()
+
+	for i := 0; i < 5; i++ {
+		for _, version := range []string{"1", "2"} {
+			s := &registry.Service{
+				Name:    fmt.Sprintf("service.%d", i),
+				Version: version,
+				Nodes: []*registry.Node{
+					{
+						Id:       fmt.Sprintf("node-%d-%s-eu", i, version),
+						Address:  "10.0.0.1:8080",
+						Metadata: map[string]string{"zone": "eu"},
+					},
+					{
+						Id:       fmt.Sprintf("node-%d-%s-us", i, version),
+						Address:  "10.0.0.2:8080",
+						Metadata: map[string]string{"zone": "us"},
+					},
+				},
+				Endpoints: []*registry.Endpoint{
+					{Name: fmt.Sprintf("Service%d.Call", i)},
+				},
+			}
+			if err := r.Register(s); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	return r
+}
+
+func TestQuery(t *testing.T) {
+	r := testRegistry(t)
+
+	testData := []struct {
+		opts     []Option
+		services []string
+	}{
+		{nil, []string{"service.0:1", "service.0:2", "service.1:1", "service.1:2", "service.2:1", "service.2:2", "service.3:1", "service.3:2", "service.4:1", "service.4:2"}},
+		{[]Option{Service("service.1")}, []string{"service.1:1", "service.1:2"}},
+		{[]Option{Service("service.9")}, nil},
+		{[]Option{Version("2")}, []string{"service.0:2", "service.1:2", "service.2:2", "service.3:2", "service.4:2"}},
+		{[]Option{Endpoint("Service3.Call")}, []string{"service.3:1", "service.3:2"}},
+		{[]Option{Metadata("zone", "ap")}, nil},
+	}
+
+	for _, d := range testData {
+		rsp, err := Query(r, d.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var services []string
+		for _, s := range rsp.Services {
+			services = append(services, s.Name+":"+s.Version)
+		}
+		if fmt.Sprint(services) != fmt.Sprint(d.services) {
+			t.Fatalf("expected %v got %v", d.services, services)
+		}
+		if len(rsp.Token) > 0 {
+			t.Fatalf("expected no next page got %s", rsp.Token)
+		}
+	}
+}
+
+func TestQueryMetadata(t *testing.T) {
+	r := testRegistry(t)
+
+	rsp, err := Query(r, Service("service.1"), Metadata("zone", "us"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rsp.Services) != 2 {
+		t.Fatalf("expected 2 services got %d", len(rsp.Services))
+	}
+	for _, s := range rsp.Services {
+		if len(s.Nodes) != 1 || s.Nodes[0].Metadata["zone"] != "us" {
+			t.Fatalf("expected the us node got %+v", s.Nodes)
+		}
+	}
+
+	// the registry isn't changed
+	services, err := r.GetService("service.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range services {
+		if len(s.Nodes) != 2 {
+			t.Fatalf("expected 2 nodes got %d", len(s.Nodes))
+		}
+	}
+}
+
+func TestQueryPages(t *testing.T) {
+	r := testRegistry(t)
+
+	var token string
+	var pages [][]string
+
+	for {
+		rsp, err := Query(r, Version("1"), Limit(2), Token(token))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var page []string
+		for _, s := range rsp.Services {
+			page = append(page, s.Name)
+		}
+		pages = append(pages, page)
+
+		if token = rsp.Token; len(token) == 0 {
+			break
+		}
+	}
+
+	expected := "[[service.0 service.1] [service.2 service.3] [service.4]]"
+	if fmt.Sprint(pages) != expected {
+		t.Fatalf("expected %s got %v", expected, pages)
+	}
+
+	if _, err := Query(r, Token("!")); err != ErrInvalidToken {
+		t.Fatalf("expected invalid token got %v", err)
+	}
+}
+
+// pushDown is a registry which selects the services on their version
+type pushDown struct {
+	registry.Registry
+	names []string
+	got   []string
+}
+
+func (p *pushDown) QueryServices(name, version string, metadata map[string]string) ([]*registry.Service, error) {
+	p.got = append(p.got, name)
+
+	var services []*registry.Service
+	if len(name) > 0 {
+		services, _ = p.GetService(name)
+	} else {
+		services, _ = p.ListServices()
+	}
+
+	var selected []*registry.Service
+	for _, s := range services {
+		if len(version) == 0 || s.Version == version {
+			selected = append(selected, s)
+		}
+	}
+	return selected, nil
+}
+
+// namePushDown is a registry which lists the names of the services
+type namePushDown struct {
+	*pushDown
+}
+
+func (n *namePushDown) QueryNames(version string, metadata map[string]string) ([]string, error) {
+	return n.names, nil
+}
+
+func TestQueryPushDown(t *testing.T) {
+	r := &pushDown{Registry: testRegistry(t)}
+
+	rsp, err := Query(r, Version("2"), Metadata("zone", "eu"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rsp.Services) != 5 || len(r.got) != 1 || len(r.got[0]) > 0 {
+		t.Fatalf("expected 5 services from a single query got %d from %q", len(rsp.Services), r.got)
+	}
+	for _, s := range rsp.Services {
+		if s.Version != "2" || len(s.Nodes) != 1 {
+			t.Fatalf("expected version 2 with the eu node got %s %+v", s.Version, s.Nodes)
+		}
+	}
+
+	// the services of each name are got until there's more than a page
+	n := &namePushDown{&pushDown{
+		Registry: testRegistry(t),
+		names:    []string{"service.3", "service.1", "service.2"},
+	}}
+
+	rsp, err = Query(n, Version("1"), Limit(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rsp.Services) != 1 || rsp.Services[0].Name != "service.1" || len(rsp.Token) == 0 {
+		t.Fatalf("expected the first page with service.1 got %+v", rsp.Services)
+	}
+	if fmt.Sprint(n.got) != "[service.1 service.2]" {
+		t.Fatalf("expected service.1 and service.2 to be got, got %v", n.got)
+	}
+}
@@ -0,0 +1,54 @@
+package zookeeper
+
+import (
+	"context"
+
+	"github.com/micro/go-micro/v2/registry"
+	"github.com/samuel/go-zookeeper/zk"
+)
+
+type aclKey struct{}
+type authKey struct{}
+
+// auth is a credential added to the session
+type auth struct {
+	scheme string
+	auth   []byte
+}
+
+// ACL sets the acls of the znodes created by the registry, the
+// default allows anyone to do anything. Use the sasl scheme to grant
+// access to clients authenticated with SASL e.g
+//
+//	zk.ACL{Perms: zk.PermRead, Scheme: "sasl", ID: "reader"}
+func ACL(acls ...zk.ACL) registry.Option {
+	return func(o *registry.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		v, _ := o.Context.Value(aclKey{}).([]zk.ACL)
+		o.Context = context.WithValue(o.Context, aclKey{}, append(v[:len(v):len(v)], acls...))
+	}
+}
+
+// Auth adds credentials to the session, they're sent again when
+// reconnecting
+func Auth(scheme string, credentials []byte) registry.Option {
+	return func(o *registry.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		v, _ := o.Context.Value(authKey{}).([]auth)
+		o.Context = context.WithValue(o.Context, authKey{}, append(v[:len(v):len(v)], auth{scheme, credentials}))
+	}
+}
+
+// Digest authenticates the session with a digest user and password.
+// Only the user can change the registry and anyone can read it.
+func Digest(user, password string) registry.Option {
+	return func(o *registry.Options) {
+		Auth("digest", []byte(user+":"+password))(o)
+		ACL(zk.DigestACL(zk.PermAll, user, password)...)(o)
+		ACL(zk.WorldACL(zk.PermRead)...)(o)
+	}
+}
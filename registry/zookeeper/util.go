@@ -33,7 +33,9 @@ func servicePath(s string) string {
 	return path.Join(prefix, strings.Replace(s, "/", "-", -1))
 }
 
-func createPath(path string, data []byte, client *zk.Conn) error {
+// createPath creates a znode with the given flags and its parents as
+// persistent znodes, all with the acl
+func createPath(path string, data []byte, flags int32, acl []zk.ACL, client *zk.Conn) error {
 	exists, _, err := client.Exists(path)
 	if err != nil {
 		return err
@@ -50,15 +52,15 @@ func createPath(path string, data []byte, client *zk.Conn) error {
 		name += v
 		e, _, _ := client.Exists(name)
 		if !e {
-			_, err = client.Create(name, []byte{}, int32(0), zk.WorldACL(zk.PermAll))
-			if err != nil {
+			_, err = client.Create(name, []byte{}, int32(0), acl)
+			if err != nil && err != zk.ErrNodeExists {
 				return err
 			}
 		}
 		name += "/"
 	}
 
-	_, err = client.Create(path, data, int32(0), zk.WorldACL(zk.PermAll))
+	_, err = client.Create(path, data, flags, acl)
 	return err
 }
 
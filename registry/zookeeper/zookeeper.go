@@ -3,6 +3,7 @@ package zookeeper
 
 import (
 	"errors"
+	"reflect"
 	"sync"
 	"time"
 
@@ -23,6 +24,16 @@ type zookeeperRegistry struct {
 	options registry.Options
 	sync.Mutex
 	register map[string]uint64
+	// services registered with their nodes by id, registered
+	// again when the session expires
+	services map[string]*registry.Service
+	// acl of the znodes
+	acl []zk.ACL
+	// addrs and auths the client connected with
+	addrs []string
+	auths []auth
+	// set when the session expired until it's re-established
+	expired bool
 }
 
 func init() {
@@ -30,8 +41,6 @@ func init() {
 }
 
 func configure(z *zookeeperRegistry, opts ...registry.Option) error {
-	cAddrs := z.options.Addrs
-
 	for _, o := range opts {
 		o(&z.options)
 	}
@@ -40,13 +49,7 @@ func configure(z *zookeeperRegistry, opts ...registry.Option) error {
 		z.options.Timeout = 5
 	}
 
-	// already set
-	if z.client != nil && len(z.options.Addrs) == len(cAddrs) {
-		return nil
-	}
-
-	// reset
-	cAddrs = nil
+	var cAddrs []string
 
 	for _, addr := range z.options.Addrs {
 		if len(addr) == 0 {
@@ -59,23 +62,115 @@ func configure(z *zookeeperRegistry, opts ...registry.Option) error {
 		cAddrs = []string{"127.0.0.1:2181"}
 	}
 
+	acl := zk.WorldACL(zk.PermAll)
+	var auths []auth
+
+	if z.options.Context != nil {
+		if v, ok := z.options.Context.Value(aclKey{}).([]zk.ACL); ok && len(v) > 0 {
+			acl = v
+		}
+		if v, ok := z.options.Context.Value(authKey{}).([]auth); ok {
+			auths = v
+		}
+	}
+
+	// already connected, the acl only applies to new znodes
+	if z.connected(cAddrs, auths) {
+		z.Lock()
+		z.acl = acl
+		z.Unlock()
+		return nil
+	}
+
 	// connect to zookeeper
-	c, _, err := zk.Connect(cAddrs, time.Second*z.options.Timeout)
+	c, _, err := zk.Connect(cAddrs, time.Second*z.options.Timeout, zk.WithEventCallback(z.event))
 	if err != nil {
 		log.Error(err.Error())
 		return err
 	}
 
+	// authenticate the session
+	for _, a := range auths {
+		if err := c.AddAuth(a.scheme, a.auth); err != nil {
+			log.Error(err.Error())
+			c.Close()
+			return err
+		}
+	}
+
 	// create our prefix path
-	if err := createPath(prefix, []byte{}, c); err != nil {
+	if err := createPath(prefix, []byte{}, 0, acl, c); err != nil {
 		log.Error(err.Error())
+		c.Close()
 		return err
 	}
 
+	z.Lock()
+	old := z.client
 	z.client = c
+	z.acl = acl
+	z.addrs = cAddrs
+	z.auths = auths
+	z.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+
 	return nil
 }
 
+// connected returns true if the client is connected to the addrs with the
+// auths. Credentials can't be removed from a session so a change of auths
+// needs a new connection.
+func (z *zookeeperRegistry) connected(addrs []string, auths []auth) bool {
+	z.Lock()
+	defer z.Unlock()
+	return z.client != nil && reflect.DeepEqual(z.addrs, addrs) && reflect.DeepEqual(z.auths, auths)
+}
+
+// event is called for the session events of the connection. Ephemeral
+// znodes are deleted by zookeeper when the session expires so services
+// are registered again once there's a new session.
+func (z *zookeeperRegistry) event(ev zk.Event) {
+	if ev.Type != zk.EventSession {
+		return
+	}
+
+	z.Lock()
+	defer z.Unlock()
+
+	switch ev.State {
+	case zk.StateExpired:
+		z.expired = true
+	case zk.StateHasSession:
+		if !z.expired {
+			return
+		}
+		z.expired = false
+		// the callback must not block
+		go z.reregister()
+	}
+}
+
+// reregister registers the services again after the session expired
+func (z *zookeeperRegistry) reregister() {
+	z.Lock()
+	services := make([]*registry.Service, 0, len(z.services))
+	for _, s := range z.services {
+		services = append(services, s)
+	}
+	// the cached hashes are of znodes which were deleted
+	z.register = make(map[string]uint64)
+	z.Unlock()
+
+	for _, s := range services {
+		if err := z.Register(s); err != nil {
+			log.Errorf("[zookeeper] Registry failed to register %s again: %v", s.Name, err)
+		}
+	}
+}
+
 func (z *zookeeperRegistry) Init(opts ...registry.Option) error {
 	return configure(z, opts...)
 }
@@ -92,11 +187,14 @@ func (z *zookeeperRegistry) Deregister(s *registry.Service, opts ...registry.Der
 	// delete our hash of the service
 	z.Lock()
 	delete(z.register, s.Name)
+	for _, node := range s.Nodes {
+		delete(z.services, node.Id)
+	}
 	z.Unlock()
 
 	for _, node := range s.Nodes {
 		err := z.client.Delete(nodePath(s.Name, node.Id), -1)
-		if err != nil {
+		if err != nil && err != zk.ErrNoNode {
 			return err
 		}
 	}
@@ -104,6 +202,8 @@ func (z *zookeeperRegistry) Deregister(s *registry.Service, opts ...registry.Der
 	return nil
 }
 
+// Register creates an ephemeral znode for each node of the service,
+// the znodes are deleted by zookeeper when the session ends
 func (z *zookeeperRegistry) Register(s *registry.Service, opts ...registry.RegisterOption) error {
 	if len(s.Nodes) == 0 {
 		return errors.New("Require at least one node")
@@ -123,10 +223,11 @@ func (z *zookeeperRegistry) Register(s *registry.Service, opts ...registry.Regis
 	// get existing hash
 	z.Lock()
 	v, ok := z.register[s.Name]
+	acl := z.acl
 	z.Unlock()
 
-	// the service is unchanged, skip registering
-	if ok && v == h {
+	// the service is unchanged and its znodes still exist, skip registering
+	if ok && v == h && z.registered(s) {
 		return nil
 	}
 
@@ -139,37 +240,70 @@ func (z *zookeeperRegistry) Register(s *registry.Service, opts ...registry.Regis
 
 	for _, node := range s.Nodes {
 		service.Nodes = []*registry.Node{node}
-		exists, _, err := z.client.Exists(nodePath(service.Name, node.Id))
+
+		srv, err := encode(service)
 		if err != nil {
 			return err
 		}
 
-		srv, err := encode(service)
+		path := nodePath(service.Name, node.Id)
+
+		exists, stat, err := z.client.Exists(path)
 		if err != nil {
 			return err
 		}
 
+		// a znode left by a previous session is deleted with that
+		// session, so replace it with one owned by this session
+		if exists && stat.EphemeralOwner != z.client.SessionID() {
+			if err := z.client.Delete(path, -1); err != nil && err != zk.ErrNoNode {
+				return err
+			}
+			exists = false
+		}
+
 		if exists {
-			_, err := z.client.Set(nodePath(service.Name, node.Id), srv, -1)
+			_, err := z.client.Set(path, srv, -1)
 			if err != nil {
 				return err
 			}
 		} else {
-			err := createPath(nodePath(service.Name, node.Id), srv, z.client)
+			err := createPath(path, srv, zk.FlagEphemeral, acl, z.client)
 			if err != nil {
 				return err
 			}
 		}
 	}
 
-	// save our hash of the service
+	// save our hash of the service and the nodes to register again
 	z.Lock()
 	z.register[s.Name] = h
+	for _, node := range s.Nodes {
+		z.services[node.Id] = &registry.Service{
+			Name:      s.Name,
+			Version:   s.Version,
+			Metadata:  s.Metadata,
+			Endpoints: s.Endpoints,
+			Nodes:     []*registry.Node{node},
+		}
+	}
 	z.Unlock()
 
 	return nil
 }
 
+// registered returns true if the znodes of a service exist and are
+// owned by this session
+func (z *zookeeperRegistry) registered(s *registry.Service) bool {
+	for _, node := range s.Nodes {
+		exists, stat, err := z.client.Exists(nodePath(s.Name, node.Id))
+		if err != nil || !exists || stat.EphemeralOwner != z.client.SessionID() {
+			return false
+		}
+	}
+	return true
+}
+
 func (z *zookeeperRegistry) GetService(name string, opts ...registry.GetOption) ([]*registry.Service, error) {
 	l, _, err := z.client.Children(servicePath(name))
 	if err != nil {
@@ -276,43 +410,14 @@ func (z *zookeeperRegistry) Watch(opts ...registry.WatchOption) (registry.Watche
 }
 
 func NewRegistry(opts ...registry.Option) registry.Registry {
-	var options registry.Options
-	for _, o := range opts {
-		o(&options)
-	}
-
-	if options.Timeout == 0 {
-		options.Timeout = 5
-	}
-
-	cAddrs := make([]string, 0, len(options.Addrs))
-	for _, addr := range options.Addrs {
-		if len(addr) == 0 {
-			continue
-		}
-		cAddrs = append(cAddrs, addr)
-	}
-
-	if len(cAddrs) == 0 {
-		cAddrs = []string{"127.0.0.1:2181"}
-	}
-
-	// connect to zookeeper
-	c, _, err := zk.Connect(cAddrs, time.Second*options.Timeout)
-	if err != nil {
-		log.Error(err.Error())
-		return nil
+	z := &zookeeperRegistry{
+		register: make(map[string]uint64),
+		services: make(map[string]*registry.Service),
 	}
 
-	// create our prefix path
-	if err := createPath(prefix, []byte{}, c); err != nil {
-		log.Error(err.Error())
+	if err := configure(z, opts...); err != nil {
 		return nil
 	}
 
-	return &zookeeperRegistry{
-		client:   c,
-		options:  options,
-		register: make(map[string]uint64),
-	}
+	return z
 }
@@ -0,0 +1,94 @@
+package zookeeper
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v2/registry"
+	"github.com/samuel/go-zookeeper/zk"
+)
+
+// testRegistry returns a registry which appears connected to the default address
+func testRegistry() *zookeeperRegistry {
+	return &zookeeperRegistry{
+		client:   new(zk.Conn),
+		addrs:    []string{"127.0.0.1:2181"},
+		register: make(map[string]uint64),
+		services: make(map[string]*registry.Service),
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	z := testRegistry()
+	client := z.client
+
+	acl := zk.DigestACL(zk.PermAll, "user", "password")
+	if err := configure(z, ACL(acl...)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the acl is applied without reconnecting
+	if z.client != client {
+		t.Fatal("expected the connection to be kept")
+	}
+	if !reflect.DeepEqual(z.acl, acl) {
+		t.Fatalf("expected acl %v got %v", acl, z.acl)
+	}
+
+	// changing the addrs or credentials needs a new connection
+	if z.connected([]string{"127.0.0.1:2182"}, nil) {
+		t.Fatal("expected new addrs to need a new connection")
+	}
+
+	var o registry.Options
+	Digest("user", "password")(&o)
+	auths, _ := o.Context.Value(authKey{}).([]auth)
+	if len(auths) != 1 || z.connected(z.addrs, auths) {
+		t.Fatalf("expected digest auth %v to need a new connection", auths)
+	}
+}
+
+func TestEvent(t *testing.T) {
+	z := testRegistry()
+	z.register["foo"] = 1
+
+	registered := func() bool {
+		z.Lock()
+		defer z.Unlock()
+		_, ok := z.register["foo"]
+		return ok
+	}
+
+	// only session events change the state
+	z.event(zk.Event{Type: zk.EventNodeDeleted, State: zk.StateExpired})
+	if z.expired {
+		t.Fatal("expected node events to be ignored")
+	}
+
+	// a new session without expiry keeps the znodes
+	z.event(zk.Event{Type: zk.EventSession, State: zk.StateHasSession})
+	time.Sleep(time.Millisecond * 10)
+	if !registered() {
+		t.Fatal("expected the service to stay registered")
+	}
+
+	z.event(zk.Event{Type: zk.EventSession, State: zk.StateExpired})
+	if !z.expired {
+		t.Fatal("expected the session to be expired")
+	}
+
+	// the services are registered again once there's a new session
+	z.event(zk.Event{Type: zk.EventSession, State: zk.StateHasSession})
+	if z.expired {
+		t.Fatal("expected the session to be re-established")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for registered() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the registered hashes to be reset")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
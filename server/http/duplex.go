@@ -0,0 +1,13 @@
+//go:build go1.21
+// +build go1.21
+
+package http
+
+import (
+	"net/http"
+)
+
+// enableFullDuplex lets a http/1.1 handler read the request while writing the response
+func enableFullDuplex(w http.ResponseWriter) error {
+	return http.NewResponseController(w).EnableFullDuplex()
+}
@@ -0,0 +1,15 @@
+//go:build !go1.21
+// +build !go1.21
+
+package http
+
+import (
+	"errors"
+	"net/http"
+)
+
+// enableFullDuplex fails before go1.21 as the http/1.1 server may close the
+// request once the response is written, streams require http/2 instead
+func enableFullDuplex(w http.ResponseWriter) error {
+	return errors.New("http/1.1 streams require go1.21, use http/2")
+}
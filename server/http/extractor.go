@@ -45,6 +45,11 @@ func serviceDef(opts server.Options) *registry.Service {
 	node.Metadata["registry"] = opts.Registry.String()
 	node.Metadata["protocol"] = "http"
 
+	// tell clients to use https
+	if opts.TLSConfig != nil {
+		node.Metadata["secure"] = "true"
+	}
+
 	return &registry.Service{
 		Name:    opts.Name,
 		Version: opts.Version,
@@ -2,6 +2,7 @@
 package http
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
@@ -18,6 +19,8 @@ import (
 	log "github.com/micro/go-micro/v2/logger"
 	"github.com/micro/go-micro/v2/registry"
 	"github.com/micro/go-micro/v2/server"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 var (
@@ -261,7 +264,20 @@ func (h *httpServer) Start() error {
 		return err
 	}
 
-	go http.Serve(ln, handler)
+	srv := newHTTPServer(opts, handler)
+
+	go func() {
+		var err error
+		if srv.TLSConfig != nil {
+			// certificates are read from the tls config
+			err = srv.ServeTLS(ln, "", "")
+		} else {
+			err = srv.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Errorf("Server serve error: %v", err)
+		}
+	}()
 
 	go func() {
 		t := new(time.Ticker)
@@ -289,17 +305,71 @@ func (h *httpServer) Start() error {
 			}
 		}
 
-		ch <- ln.Close()
-
-		// deregister
+		// deregister so no new requests are sent our way
 		h.Deregister()
 
+		// wait for in-flight requests to complete
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout(opts))
+		err := srv.Shutdown(ctx)
+		cancel()
+		if err != nil {
+			// timed out, cut off whatever is left
+			log.Errorf("Server shutdown error: %v", err)
+			srv.Close()
+		}
+
 		opts.Broker.Disconnect()
+
+		ch <- err
 	}()
 
 	return nil
 }
 
+// newHTTPServer returns the http server for the options
+func newHTTPServer(opts server.Options, handler http.Handler) *http.Server {
+	srv := &http.Server{
+		Handler:   handler,
+		TLSConfig: opts.TLSConfig,
+	}
+
+	if opts.Context == nil {
+		return srv
+	}
+
+	if d, ok := opts.Context.Value(readTimeoutKey{}).(time.Duration); ok {
+		srv.ReadTimeout = d
+	}
+	if d, ok := opts.Context.Value(readHeaderTimeoutKey{}).(time.Duration); ok {
+		srv.ReadHeaderTimeout = d
+	}
+	if d, ok := opts.Context.Value(writeTimeoutKey{}).(time.Duration); ok {
+		srv.WriteTimeout = d
+	}
+	if d, ok := opts.Context.Value(idleTimeoutKey{}).(time.Duration); ok {
+		srv.IdleTimeout = d
+	}
+	if b, ok := opts.Context.Value(h2cKey{}).(bool); ok && b && srv.TLSConfig == nil {
+		srv.Handler = h2c.NewHandler(handler, &http2.Server{
+			IdleTimeout: srv.IdleTimeout,
+		})
+	}
+
+	return srv
+}
+
+// shutdownTimeout returns how long to wait for in-flight requests
+func shutdownTimeout(opts server.Options) time.Duration {
+	if opts.Context != nil {
+		if d, ok := opts.Context.Value(shutdownTimeoutKey{}).(time.Duration); ok && d > 0 {
+			return d
+		}
+	}
+	return DefaultShutdownTimeout
+}
+
+// Stop deregisters the server then waits for in-flight requests
+// to complete, up to the shutdown timeout
 func (h *httpServer) Stop() error {
 	ch := make(chan error)
 	h.exit <- ch
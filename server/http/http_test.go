@@ -1,13 +1,22 @@
 package http
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/micro/go-micro/v2/codec/json"
+	"github.com/micro/go-micro/v2/errors"
 	"github.com/micro/go-micro/v2/registry/memory"
 	"github.com/micro/go-micro/v2/server"
+	"github.com/micro/go-plugins/client/http/v2/frame"
 )
 
 func TestHTTPServer(t *testing.T) {
@@ -70,3 +79,214 @@ func TestHTTPServer(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestStreamHandler(t *testing.T) {
+	type message struct {
+		Seq int
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/Test.Stream", StreamHandler(func(ctx context.Context, stream server.Stream) error {
+		if ep := stream.Request().Endpoint(); ep != "/Test.Stream" {
+			return fmt.Errorf("unexpected endpoint %s", ep)
+		}
+
+		var count int
+		for {
+			var msg message
+			if err := stream.Recv(&msg); err == io.EOF {
+				// client is done sending
+				return errors.New("test.service", fmt.Sprintf("received %d", count), 499)
+			} else if err != nil {
+				return err
+			}
+			count++
+			if err := stream.Send(&msg); err != nil {
+				return err
+			}
+		}
+	}))
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	pr, pw := io.Pipe()
+	req, err := http.NewRequest("POST", srv.URL+"/Test.Stream", pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != 200 {
+		t.Fatalf("Expected status 200 got %d", rsp.StatusCode)
+	}
+
+	cf := json.Marshaler{}
+
+	for i := 0; i < 3; i++ {
+		b, _ := cf.Marshal(&message{Seq: i})
+		if err := frame.Write(pw, frame.Message, b); err != nil {
+			t.Fatal(err)
+		}
+
+		typ, b, err := frame.Read(rsp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msg message
+		if err := cf.Unmarshal(b, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if typ != frame.Message || msg.Seq != i {
+			t.Fatalf("Expected message %d got %d", i, msg.Seq)
+		}
+	}
+
+	// half close
+	pw.Close()
+
+	typ, b, err := frame.Read(rsp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ != frame.Error {
+		t.Fatalf("Expected error frame got %d", typ)
+	}
+	if merr := errors.Parse(string(b)); merr.Code != 499 || merr.Detail != "received 3" {
+		t.Fatalf("Unexpected error %v", merr)
+	}
+
+	if _, _, err := frame.Read(rsp.Body); err != io.EOF {
+		t.Fatalf("Expected EOF got %v", err)
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	reg := memory.NewRegistry()
+
+	srv := NewServer(server.Registry(reg), ShutdownTimeout(time.Second*5))
+
+	started := make(chan bool)
+	release := make(chan bool)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte(`done`))
+	})
+
+	if err := srv.Handle(srv.NewHandler(mux)); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	address := srv.Options().Address
+
+	result := make(chan string, 1)
+	go func() {
+		rsp, err := http.Get(fmt.Sprintf("http://%s", address))
+		if err != nil {
+			result <- err.Error()
+			return
+		}
+		defer rsp.Body.Close()
+		b, _ := ioutil.ReadAll(rsp.Body)
+		result <- string(b)
+	}()
+
+	<-started
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- srv.Stop()
+	}()
+
+	select {
+	case err := <-stopped:
+		t.Fatalf("Stop returned before the request completed: %v", err)
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	// deregistered while draining
+	if _, err := reg.GetService(server.DefaultName); err == nil {
+		t.Fatal("Expected the service to be deregistered")
+	}
+
+	close(release)
+
+	if s := <-result; s != "done" {
+		t.Fatalf("Expected the in-flight request to complete got %s", s)
+	}
+	if err := <-stopped; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLS(t *testing.T) {
+	// borrow a certificate for 127.0.0.1
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	cert := ts.TLS.Certificates[0]
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	ts.Close()
+
+	reg := memory.NewRegistry()
+
+	srv := NewServer(
+		server.Registry(reg),
+		server.Address("127.0.0.1:0"),
+		server.TLSConfig(&tls.Config{Certificates: []tls.Certificate{cert}}),
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+
+	if err := srv.Handle(srv.NewHandler(mux)); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+
+	service, err := reg.GetService(server.DefaultName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := service[0].Nodes[0]
+	if node.Metadata["secure"] != "true" {
+		t.Fatalf("Expected the node to be marked secure: %+v", node.Metadata)
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: pool},
+			ForceAttemptHTTP2: true,
+		},
+	}
+
+	rsp, err := client.Get(fmt.Sprintf("https://%s", srv.Options().Address))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+
+	b, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); s != "HTTP/2.0" {
+		t.Fatalf("Expected HTTP/2.0 got %s", s)
+	}
+}
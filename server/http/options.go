@@ -2,6 +2,7 @@ package http
 
 import (
 	"context"
+	"time"
 
 	"github.com/micro/go-micro/v2/broker"
 	"github.com/micro/go-micro/v2/codec"
@@ -9,6 +10,60 @@ import (
 	"github.com/micro/go-micro/v2/server"
 )
 
+var (
+	// DefaultShutdownTimeout is how long Stop waits for in-flight requests
+	DefaultShutdownTimeout = time.Second * 30
+)
+
+type shutdownTimeoutKey struct{}
+type readTimeoutKey struct{}
+type readHeaderTimeoutKey struct{}
+type writeTimeoutKey struct{}
+type idleTimeoutKey struct{}
+type h2cKey struct{}
+
+// ShutdownTimeout sets how long Stop waits for in-flight requests
+// to complete before closing their connections
+func ShutdownTimeout(d time.Duration) server.Option {
+	return setServerOption(shutdownTimeoutKey{}, d)
+}
+
+// ReadTimeout sets the maximum duration for reading a request, including the body
+func ReadTimeout(d time.Duration) server.Option {
+	return setServerOption(readTimeoutKey{}, d)
+}
+
+// ReadHeaderTimeout sets the maximum duration for reading request headers
+func ReadHeaderTimeout(d time.Duration) server.Option {
+	return setServerOption(readHeaderTimeoutKey{}, d)
+}
+
+// WriteTimeout sets the maximum duration before timing out writes of the
+// response. Leave it unset when serving long lived streams.
+func WriteTimeout(d time.Duration) server.Option {
+	return setServerOption(writeTimeoutKey{}, d)
+}
+
+// IdleTimeout sets how long to wait for the next request on a keep-alive connection
+func IdleTimeout(d time.Duration) server.Option {
+	return setServerOption(idleTimeoutKey{}, d)
+}
+
+// H2C serves http/2 without tls as well as http/1.1
+func H2C(b bool) server.Option {
+	return setServerOption(h2cKey{}, b)
+}
+
+// setServerOption returns a function to setup a context with given value
+func setServerOption(k, v interface{}) server.Option {
+	return func(o *server.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
+
 func newOptions(opt ...server.Option) server.Options {
 	opts := server.Options{
 		Codecs:   make(map[string]codec.NewCodec),
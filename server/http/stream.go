@@ -0,0 +1,283 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/micro/go-micro/v2/codec"
+	"github.com/micro/go-micro/v2/codec/json"
+	"github.com/micro/go-micro/v2/codec/proto"
+	merrors "github.com/micro/go-micro/v2/errors"
+	"github.com/micro/go-micro/v2/metadata"
+	"github.com/micro/go-micro/v2/server"
+	"github.com/micro/go-plugins/client/http/v2/frame"
+)
+
+var (
+	// defaultMarshalers encode stream messages by content type
+	defaultMarshalers = map[string]codec.Marshaler{
+		"application/json":         json.Marshaler{},
+		"application/proto":        proto.Marshaler{},
+		"application/protobuf":     proto.Marshaler{},
+		"application/octet-stream": proto.Marshaler{},
+	}
+
+	errShutdown = errors.New("stream is closed")
+)
+
+// StreamFunc handles a bidirectional stream
+type StreamFunc func(ctx context.Context, stream server.Stream) error
+
+type streamHandler struct {
+	fn StreamFunc
+}
+
+// StreamHandler returns a http.Handler which serves a bidirectional stream
+// to fn. Messages are framed in the request and response bodies as sent by
+// the http client plugin, over http/2 or chunked http/1.1. If fn returns an
+// error it's sent to the client as a go-micro error.
+func StreamHandler(fn StreamFunc) http.Handler {
+	return &streamHandler{fn: fn}
+}
+
+func (s *streamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "stream requires post", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ct, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		ct = "application/proto"
+	}
+
+	cf, ok := defaultMarshalers[ct]
+	if !ok {
+		err := merrors.New("go.micro.server", fmt.Sprintf("Unsupported Content-Type: %s", ct), http.StatusUnsupportedMediaType)
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	// http/1.1 needs to read the request while writing the response
+	if r.ProtoMajor < 2 {
+		if err := enableFullDuplex(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", ct)
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	header := make(map[string]string, len(r.Header))
+	for k, v := range r.Header {
+		header[k] = strings.Join(v, ",")
+	}
+
+	// the request context is cancelled when the client goes away
+	ctx := metadata.NewContext(r.Context(), header)
+
+	stream := &httpStream{
+		context: ctx,
+		codec:   cf,
+		reader:  r.Body,
+		writer:  w,
+		flusher: flusher,
+		closed:  make(chan bool),
+		request: &streamRequest{
+			service:     r.Header.Get("Micro-Service"),
+			endpoint:    r.URL.Path,
+			contentType: ct,
+			header:      header,
+		},
+	}
+
+	if err := s.fn(ctx, stream); err != nil {
+		stream.sendError(err)
+	}
+
+	stream.Close()
+}
+
+// httpStream implements server.Stream over a http request and response
+type httpStream struct {
+	sync.RWMutex
+	context context.Context
+	codec   codec.Marshaler
+	request *streamRequest
+	err     error
+	closed  chan bool
+
+	// serialise concurrent senders and receivers
+	send sync.Mutex
+	recv sync.Mutex
+
+	reader  io.Reader
+	writer  io.Writer
+	flusher http.Flusher
+}
+
+func (h *httpStream) setError(err error) {
+	h.Lock()
+	if h.err == nil {
+		h.err = err
+	}
+	h.Unlock()
+}
+
+func (h *httpStream) isClosed() bool {
+	select {
+	case <-h.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (h *httpStream) Context() context.Context {
+	return h.context
+}
+
+func (h *httpStream) Request() server.Request {
+	return h.request
+}
+
+func (h *httpStream) Send(msg interface{}) error {
+	h.send.Lock()
+	defer h.send.Unlock()
+
+	if h.isClosed() {
+		return errShutdown
+	}
+
+	b, err := h.codec.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if err := frame.Write(h.writer, frame.Message, b); err != nil {
+		h.setError(err)
+		return err
+	}
+	h.flusher.Flush()
+
+	return nil
+}
+
+// sendError sends an error frame ending the stream
+func (h *httpStream) sendError(err error) {
+	h.send.Lock()
+	defer h.send.Unlock()
+
+	if h.isClosed() {
+		return
+	}
+
+	merr, ok := err.(*merrors.Error)
+	if !ok {
+		merr = merrors.InternalServerError("go.micro.server", err.Error()).(*merrors.Error)
+	}
+
+	if err := frame.Write(h.writer, frame.Error, []byte(merr.Error())); err != nil {
+		h.setError(err)
+		return
+	}
+	h.flusher.Flush()
+}
+
+// Recv returns io.EOF once the client has closed its side of the stream
+func (h *httpStream) Recv(msg interface{}) error {
+	h.recv.Lock()
+	defer h.recv.Unlock()
+
+	if h.isClosed() {
+		return errShutdown
+	}
+
+	_, b, err := frame.Read(h.reader)
+	if err != nil {
+		if err != io.EOF {
+			h.setError(err)
+		}
+		return err
+	}
+
+	return h.codec.Unmarshal(b, msg)
+}
+
+func (h *httpStream) Error() error {
+	h.RLock()
+	defer h.RUnlock()
+	return h.err
+}
+
+// Close stops sending, the response ends when the handler returns
+func (h *httpStream) Close() error {
+	h.send.Lock()
+	defer h.send.Unlock()
+
+	select {
+	case <-h.closed:
+	default:
+		close(h.closed)
+	}
+	return nil
+}
+
+// streamRequest implements server.Request for a stream
+type streamRequest struct {
+	service     string
+	endpoint    string
+	contentType string
+	header      map[string]string
+}
+
+func (r *streamRequest) Service() string {
+	return r.service
+}
+
+func (r *streamRequest) Method() string {
+	return r.endpoint
+}
+
+func (r *streamRequest) Endpoint() string {
+	return r.endpoint
+}
+
+func (r *streamRequest) ContentType() string {
+	return r.contentType
+}
+
+func (r *streamRequest) Header() map[string]string {
+	return r.header
+}
+
+// Body is nil, messages are read with Recv
+func (r *streamRequest) Body() interface{} {
+	return nil
+}
+
+func (r *streamRequest) Read() ([]byte, error) {
+	return nil, errors.New("stream messages are read with Recv")
+}
+
+func (r *streamRequest) Codec() codec.Reader {
+	return nil
+}
+
+func (r *streamRequest) Stream() bool {
+	return true
+}
@@ -5,5 +5,7 @@ go 1.13
 require (
 	github.com/go-sql-driver/mysql v1.5.0
 	github.com/micro/go-micro/v2 v2.9.1
-	github.com/pkg/errors v0.9.1
+	github.com/micro/go-plugins/store/sql/v2 v2.9.1
 )
+
+replace github.com/micro/go-plugins/store/sql/v2 => ../sql
@@ -64,3 +64,95 @@ func TestList(t *testing.T) {
 		t.Log(string(beauty))
 	}
 }
+
+func TestReadPrefix(t *testing.T) {
+	for _, key := range []string{"config/foo", "config/bar", "config/baz", "other"} {
+		if err := sqlStoreT.Write(&store.Record{Key: key, Value: []byte(key), Expiry: time.Minute}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := sqlStoreT.Read("config/", store.ReadPrefix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records got %d", len(records))
+	}
+
+	records, err = sqlStoreT.Read("config/", store.ReadPrefix(), store.ReadLimit(1), store.ReadOffset(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Key != "config/baz" {
+		t.Fatalf("Expected config/baz got %+v", records)
+	}
+
+	records, err = sqlStoreT.Read("/foo", store.ReadSuffix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Key != "config/foo" {
+		t.Fatalf("Expected config/foo got %+v", records)
+	}
+}
+
+func TestListOptions(t *testing.T) {
+	if err := sqlStoreT.Write(&store.Record{Key: "config/foo", Value: []byte("foo"), Expiry: time.Minute}, store.WriteTo("testMicro", "other")); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := sqlStoreT.List(store.ListPrefix("config/"), store.ListLimit(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] != "config/bar" || keys[1] != "config/baz" {
+		t.Fatalf("Unexpected keys %v", keys)
+	}
+
+	keys, err = sqlStoreT.List(store.ListFrom("testMicro", "other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0] != "config/foo" {
+		t.Fatalf("Unexpected keys %v", keys)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	s := NewStore(
+		store.Database("testMicro"),
+		store.Table("sweep"),
+		store.Nodes("root:123@(127.0.0.1:3306)/test?charset=utf8&parseTime=true&loc=Asia%2FShanghai"),
+		SweepInterval(time.Millisecond*100),
+		SweepBatchSize(1),
+	)
+	defer s.Close()
+
+	if err := s.Write(&store.Record{Key: "forever", Value: []byte("foo")}); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"short1", "short2"} {
+		if err := s.Write(&store.Record{Key: key, Value: []byte("bar"), Expiry: time.Millisecond * 50}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	time.Sleep(time.Millisecond * 300)
+
+	records, err := s.Read("forever")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records[0].Expiry != 0 {
+		t.Fatalf("Expected no expiry got %v", records[0].Expiry)
+	}
+
+	keys, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0] != "forever" {
+		t.Fatalf("Expected expired records to be swept got %v", keys)
+	}
+}
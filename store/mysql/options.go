@@ -0,0 +1,19 @@
+package mysql
+
+import (
+	"time"
+
+	"github.com/micro/go-micro/v2/store"
+	"github.com/micro/go-plugins/store/sql/v2"
+)
+
+// SweepInterval starts a background sweeper deleting expired records at the given interval.
+// Without it expired records are only deleted when they're read.
+func SweepInterval(d time.Duration) store.Option {
+	return sql.SweepInterval(d)
+}
+
+// SweepBatchSize sets the number of expired records the sweeper deletes per statement
+func SweepBatchSize(n int) store.Option {
+	return sql.SweepBatchSize(n)
+}
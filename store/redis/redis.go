@@ -2,12 +2,22 @@ package redis
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/go-redis/redis/v7"
 	log "github.com/micro/go-micro/v2/logger"
 	"github.com/micro/go-micro/v2/store"
 )
 
+var (
+	// DefaultScanCount is the number of keys requested per SCAN and fetched per MGET
+	DefaultScanCount = 1000
+
+	globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+)
+
 type rkv struct {
 	options store.Options
 	Client  *redis.Client
@@ -33,53 +43,129 @@ func (r *rkv) Read(key string, opts ...store.ReadOption) ([]*store.Record, error
 		o(&options)
 	}
 
-	var keys []string
+	if options.Prefix || options.Suffix {
+		return r.read(key, options)
+	}
 
 	rkey := fmt.Sprintf("%s%s", options.Table, key)
-	// Handle Prefix
-	// TODO suffix
-	if options.Prefix {
-		prefixKey := fmt.Sprintf("%s*", rkey)
-		fkeys, err := r.Client.Keys(prefixKey).Result()
-		if err != nil {
-			return nil, err
-		}
-		// TODO Limit Offset
 
-		keys = append(keys, fkeys...)
+	pipe := r.Client.Pipeline()
+	get := pipe.Get(rkey)
+	ttl := pipe.TTL(rkey)
+	if _, err := pipe.Exec(); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	val, err := get.Bytes()
+	if err != nil && err == redis.Nil {
+		return nil, store.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
 
-	} else {
-		keys = []string{rkey}
+	if val == nil {
+		return nil, store.ErrNotFound
 	}
 
+	return []*store.Record{{
+		Key:    key,
+		Value:  val,
+		Expiry: expiry(ttl.Val()),
+	}}, nil
+}
+
+// read returns the records matching a prefix and/or suffix
+func (r *rkv) read(key string, options store.ReadOptions) ([]*store.Record, error) {
+	var prefix, suffix string
+	if options.Prefix {
+		prefix = key
+	}
+	if options.Suffix {
+		suffix = key
+	}
+
+	keys, err := r.scan(options.Table, prefix, suffix)
+	if err != nil {
+		return nil, err
+	}
+
+	keys = paginate(keys, options.Limit, options.Offset)
 	records := make([]*store.Record, 0, len(keys))
 
-	for _, rkey = range keys {
-		val, err := r.Client.Get(rkey).Bytes()
+	for i := 0; i < len(keys); i += DefaultScanCount {
+		batch := keys[i:min(i+DefaultScanCount, len(keys))]
 
-		if err != nil && err == redis.Nil {
-			return nil, store.ErrNotFound
-		} else if err != nil {
+		rkeys := make([]string, len(batch))
+		for j, k := range batch {
+			rkeys[j] = options.Table + k
+		}
+
+		// fetch the values and ttls in one round trip
+		pipe := r.Client.Pipeline()
+		mget := pipe.MGet(rkeys...)
+		ttls := make([]*redis.DurationCmd, len(rkeys))
+		for j, rkey := range rkeys {
+			ttls[j] = pipe.TTL(rkey)
+		}
+		if _, err := pipe.Exec(); err != nil {
 			return nil, err
 		}
 
-		if val == nil {
-			return nil, store.ErrNotFound
+		for j, val := range mget.Val() {
+			v, ok := val.(string)
+			if !ok {
+				// the key expired or was deleted since the scan
+				continue
+			}
+
+			records = append(records, &store.Record{
+				Key:    batch[j],
+				Value:  []byte(v),
+				Expiry: expiry(ttls[j].Val()),
+			})
 		}
+	}
+
+	return records, nil
+}
 
-		d, err := r.Client.TTL(rkey).Result()
+// scan returns the sorted keys in a table matching the prefix and suffix,
+// without the table prefix. SCAN is used rather than KEYS so that large
+// keyspaces don't block redis.
+func (r *rkv) scan(table, prefix, suffix string) ([]string, error) {
+	pattern := escape(table) + escape(prefix) + "*"
+	if len(prefix) == 0 && len(suffix) > 0 {
+		pattern = escape(table) + "*" + escape(suffix)
+	}
+
+	// SCAN may return a key more than once
+	seen := make(map[string]bool)
+	var keys []string
+	var cursor uint64
+
+	for {
+		rkeys, next, err := r.Client.Scan(cursor, pattern, int64(DefaultScanCount)).Result()
 		if err != nil {
 			return nil, err
 		}
 
-		records = append(records, &store.Record{
-			Key:    key,
-			Value:  val,
-			Expiry: d,
-		})
+		for _, rkey := range rkeys {
+			key := strings.TrimPrefix(rkey, table)
+			if seen[key] || !strings.HasSuffix(key, suffix) {
+				continue
+			}
+			seen[key] = true
+			keys = append(keys, key)
+		}
+
+		if next == 0 {
+			break
+		}
+		cursor = next
 	}
 
-	return records, nil
+	sort.Strings(keys)
+	return keys, nil
 }
 
 func (r *rkv) Delete(key string, opts ...store.DeleteOption) error {
@@ -114,12 +200,12 @@ func (r *rkv) List(opts ...store.ListOption) ([]string, error) {
 		o(&options)
 	}
 
-	keys, err := r.Client.Keys("*").Result()
+	keys, err := r.scan(options.Table, options.Prefix, options.Suffix)
 	if err != nil {
 		return nil, err
 	}
 
-	return keys, nil
+	return paginate(keys, options.Limit, options.Offset), nil
 }
 
 func (r *rkv) Options() store.Options {
@@ -168,3 +254,35 @@ func (r *rkv) configure() error {
 
 	return nil
 }
+
+// escape escapes the glob characters in a key for use in a MATCH pattern
+func escape(key string) string {
+	return globEscaper.Replace(key)
+}
+
+// expiry converts a TTL to a record expiry, keys without a TTL don't expire
+func expiry(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// paginate applies the limit and offset to the keys
+func paginate(keys []string, limit, offset uint) []string {
+	if offset >= uint(len(keys)) {
+		return nil
+	}
+	keys = keys[offset:]
+	if limit > 0 && limit < uint(len(keys)) {
+		keys = keys[:limit]
+	}
+	return keys
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
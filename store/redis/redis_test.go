@@ -120,3 +120,39 @@ func Test_Store(t *testing.T) {
 		t.Errorf("listing error %v\n", err)
 	}
 }
+
+func Test_Store_Prefix(t *testing.T) {
+	if tr := os.Getenv("TRAVIS"); len(tr) > 0 {
+		t.Skip()
+	}
+	r := new(rkv)
+	r.options = store.Options{Nodes: []string{"redis://127.0.0.1:6379"}, Table: "micro:test:"}
+
+	if err := r.configure(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, key := range []string{"config/a", "config/b", "config/c", "other/a"} {
+		if err := r.Write(&store.Record{Key: key, Value: []byte(key), Expiry: time.Minute}); err != nil {
+			t.Fatalf("Write Error. Error: %v", err)
+		}
+		defer r.Delete(key)
+	}
+
+	records, err := r.Read("config/", store.ReadPrefix(), store.ReadLimit(2), store.ReadOffset(1))
+	if err != nil {
+		t.Fatalf("Read Error. Error: %v", err)
+	}
+	if len(records) != 2 || records[0].Key != "config/b" || records[1].Key != "config/c" {
+		t.Errorf("Unexpected records %+v", records)
+	}
+
+	keys, err := r.List(store.ListSuffix("/a"))
+	if err != nil {
+		t.Fatalf("listing error %v", err)
+	}
+	if len(keys) != 2 || keys[0] != "config/a" || keys[1] != "other/a" {
+		t.Errorf("Unexpected keys %v", keys)
+	}
+}
@@ -0,0 +1,31 @@
+package sql
+
+import (
+	"database/sql"
+)
+
+// Dialect adapts the sql store to a specific database
+type Dialect interface {
+	// Driver is the database/sql driver name
+	Driver() string
+	// Init creates the database and table if they don't exist
+	Init(db *sql.DB, database, table string) error
+	// Table returns the quoted table name for a database and table
+	Table(database, table string) string
+	// Quote quotes an identifier
+	Quote(ident string) string
+	// Placeholder returns the nth bind parameter, starting at 1
+	Placeholder(n int) string
+	// Like returns the LIKE expression for a column and placeholder.
+	// Patterns are escaped with a backslash.
+	Like(column, placeholder string) string
+	// Upsert returns the statement writing the key, value and expiry of a record
+	Upsert(table string) string
+	// Limit returns the LIMIT and OFFSET clause
+	Limit(limit, offset uint) string
+	// DeleteExpired returns the statement deleting up to batch records
+	// that expired before the time passed as the first parameter
+	DeleteExpired(table string, batch int) string
+	// String is the name of the dialect
+	String() string
+}
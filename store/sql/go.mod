@@ -0,0 +1,11 @@
+module github.com/micro/go-plugins/store/sql/v2
+
+go 1.13
+
+require (
+	github.com/go-sql-driver/mysql v1.5.0
+	github.com/lib/pq v1.3.0
+	github.com/mattn/go-sqlite3 v1.14.6
+	github.com/micro/go-micro/v2 v2.9.1
+	github.com/pkg/errors v0.9.1
+)
@@ -0,0 +1,100 @@
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// MySQL is the dialect for MySQL and MariaDB
+var MySQL Dialect = mysqlDialect{}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Driver() string {
+	return "mysql"
+}
+
+func (d mysqlDialect) Init(db *sql.DB, database, table string) error {
+	// Create the namespace's database
+	_, err := db.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s ;", d.Quote(database)))
+	if err != nil {
+		return err
+	}
+
+	// Create a table for the namespace's prefix
+	createSQL := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (`key` varchar(255) primary key, value blob null, expiry timestamp null, index expiry_index (expiry));", d.Table(database, table))
+	_, err = db.Exec(createSQL)
+	if err != nil {
+		return errors.Wrap(err, "Couldn't create table")
+	}
+
+	// Tables created by older versions have a non null expiry and no index,
+	// they're migrated once
+	var nullable string
+	row := db.QueryRow("SELECT is_nullable FROM information_schema.columns WHERE table_schema = ? AND table_name = ? AND column_name = 'expiry';", database, table)
+	if err := row.Scan(&nullable); err != nil {
+		return errors.Wrap(err, "Couldn't check columns")
+	}
+	if nullable == "NO" {
+		_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s MODIFY expiry timestamp null;", d.Table(database, table)))
+		if err != nil {
+			return errors.Wrap(err, "Couldn't alter table")
+		}
+	}
+
+	var indexes int
+	row = db.QueryRow("SELECT COUNT(1) FROM information_schema.statistics WHERE table_schema = ? AND table_name = ? AND index_name = 'expiry_index';", database, table)
+	if err := row.Scan(&indexes); err != nil {
+		return errors.Wrap(err, "Couldn't check indexes")
+	}
+	if indexes == 0 {
+		_, err = db.Exec(fmt.Sprintf("CREATE INDEX expiry_index ON %s (expiry);", d.Table(database, table)))
+		if err != nil {
+			return errors.Wrap(err, "Couldn't create index")
+		}
+	}
+
+	return nil
+}
+
+func (d mysqlDialect) Table(database, table string) string {
+	return d.Quote(database) + "." + d.Quote(table)
+}
+
+func (mysqlDialect) Quote(ident string) string {
+	return "`" + ident + "`"
+}
+
+func (mysqlDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (mysqlDialect) Like(column, placeholder string) string {
+	// backslash is the default escape character
+	return column + " LIKE " + placeholder
+}
+
+func (mysqlDialect) Upsert(table string) string {
+	return fmt.Sprintf("INSERT INTO %s (`key`, value, expiry) VALUES(?, ?, ?) ON DUPLICATE KEY UPDATE `value` = VALUES(`value`), `expiry` = VALUES(`expiry`);", table)
+}
+
+func (mysqlDialect) Limit(limit, offset uint) string {
+	if limit == 0 && offset == 0 {
+		return ""
+	}
+	if limit == 0 {
+		// mysql requires a limit when using an offset
+		return fmt.Sprintf(" LIMIT 18446744073709551615 OFFSET %d", offset)
+	}
+	return fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+}
+
+func (mysqlDialect) DeleteExpired(table string, batch int) string {
+	return fmt.Sprintf("DELETE FROM %s WHERE expiry < ? LIMIT %d;", table, batch)
+}
+
+func (mysqlDialect) String() string {
+	return "mysql"
+}
@@ -0,0 +1,46 @@
+package sql
+
+import (
+	"context"
+	"time"
+
+	"github.com/micro/go-micro/v2/store"
+)
+
+// DefaultSweepBatchSize is the number of expired records deleted per statement by the sweeper
+var DefaultSweepBatchSize = 1000
+
+type dialectKey struct{}
+type sweepIntervalKey struct{}
+type sweepBatchSizeKey struct{}
+
+// WithDialect sets the database dialect, defaults to MySQL
+func WithDialect(d Dialect) store.Option {
+	return func(o *store.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, dialectKey{}, d)
+	}
+}
+
+// SweepInterval starts a background sweeper deleting expired records at the given interval.
+// Without it expired records are only deleted when they're read.
+func SweepInterval(d time.Duration) store.Option {
+	return func(o *store.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, sweepIntervalKey{}, d)
+	}
+}
+
+// SweepBatchSize sets the number of expired records the sweeper deletes per statement
+func SweepBatchSize(n int) store.Option {
+	return func(o *store.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, sweepBatchSizeKey{}, n)
+	}
+}
@@ -0,0 +1,81 @@
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Postgres is the dialect for PostgreSQL. Databases are mapped onto schemas
+// since postgres can't query across databases on one connection.
+var Postgres Dialect = postgresDialect{}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Driver() string {
+	return "postgres"
+}
+
+func (d postgresDialect) Init(db *sql.DB, database, table string) error {
+	// Create the namespace's schema
+	_, err := db.Exec(fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s;", d.Quote(database)))
+	if err != nil {
+		return err
+	}
+
+	// Create a table for the namespace's prefix
+	createSQL := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (key text primary key, value bytea, expiry timestamp with time zone);", d.Table(database, table))
+	_, err = db.Exec(createSQL)
+	if err != nil {
+		return errors.Wrap(err, "Couldn't create table")
+	}
+
+	_, err = db.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (expiry);", d.Quote(table+"_expiry_index"), d.Table(database, table)))
+	if err != nil {
+		return errors.Wrap(err, "Couldn't create index")
+	}
+
+	return nil
+}
+
+func (d postgresDialect) Table(database, table string) string {
+	return d.Quote(database) + "." + d.Quote(table)
+}
+
+func (postgresDialect) Quote(ident string) string {
+	return `"` + ident + `"`
+}
+
+func (postgresDialect) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (postgresDialect) Like(column, placeholder string) string {
+	// backslash is the default escape character
+	return column + " LIKE " + placeholder
+}
+
+func (postgresDialect) Upsert(table string) string {
+	return fmt.Sprintf("INSERT INTO %s (key, value, expiry) VALUES ($1, $2, $3) ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, expiry = EXCLUDED.expiry;", table)
+}
+
+func (postgresDialect) Limit(limit, offset uint) string {
+	var clause string
+	if limit > 0 {
+		clause += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	if offset > 0 {
+		clause += fmt.Sprintf(" OFFSET %d", offset)
+	}
+	return clause
+}
+
+func (postgresDialect) DeleteExpired(table string, batch int) string {
+	return fmt.Sprintf("DELETE FROM %s WHERE key IN (SELECT key FROM %s WHERE expiry < $1 LIMIT %d);", table, table, batch)
+}
+
+func (postgresDialect) String() string {
+	return "postgres"
+}
@@ -0,0 +1,533 @@
+// Package sql is a store backed by a SQL database, with dialects for MySQL, PostgreSQL and SQLite.
+// The database/sql driver for the dialect must be imported separately.
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	log "github.com/micro/go-micro/v2/logger"
+	"github.com/micro/go-micro/v2/store"
+	"github.com/pkg/errors"
+)
+
+var (
+	// DefaultDatabase is the database that the sql store will use if no database is provided.
+	DefaultDatabase = "micro"
+	// DefaultTable is the table that the sql store will use if no table is provided.
+	DefaultTable = "micro"
+)
+
+var (
+	re = regexp.MustCompile("[^a-zA-Z0-9_]+")
+
+	// likeEscaper escapes the characters LIKE treats as wildcards
+	likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+	// default nodes for the builtin dialects
+	defaultNodes = map[string]string{
+		"mysql":    "localhost:3306",
+		"postgres": "postgresql://localhost:5432?sslmode=disable",
+		"sqlite":   ":memory:",
+	}
+)
+
+type sqlStore struct {
+	db      *sql.DB
+	dialect Dialect
+
+	database string
+	table    string
+
+	options store.Options
+
+	sync.RWMutex
+	// known databases
+	databases map[string]bool
+	// prepared statements
+	statements map[string]*sql.Stmt
+
+	// expired records sweeper
+	sweepInterval  time.Duration
+	sweepBatchSize int
+	exit           chan bool
+}
+
+func (s *sqlStore) Init(opts ...store.Option) error {
+	for _, o := range opts {
+		o(&s.options)
+	}
+	// reconfigure
+	return s.configure()
+}
+
+func (s *sqlStore) Options() store.Options {
+	return s.options
+}
+
+func (s *sqlStore) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.exit != nil {
+		close(s.exit)
+		s.exit = nil
+	}
+	for _, st := range s.statements {
+		st.Close()
+	}
+	s.statements = make(map[string]*sql.Stmt)
+
+	return s.db.Close()
+}
+
+// getDB returns the database and table to use, falling back to the store defaults
+func (s *sqlStore) getDB(database, table string) (string, string) {
+	if len(database) == 0 {
+		database = s.database
+	}
+	if len(table) == 0 {
+		table = s.table
+	}
+
+	// database and table names must only contain letters, numbers and underscores
+	database = re.ReplaceAllString(database, "_")
+	table = re.ReplaceAllString(table, "_")
+
+	return database, table
+}
+
+// createDB creates the database and table if it hasn't been seen before
+// and returns the quoted table name
+func (s *sqlStore) createDB(database, table string) (string, error) {
+	database, table = s.getDB(database, table)
+
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.databases[database+":"+table]; ok {
+		return s.dialect.Table(database, table), nil
+	}
+
+	if err := s.dialect.Init(s.db, database, table); err != nil {
+		return "", err
+	}
+
+	s.databases[database+":"+table] = true
+	return s.dialect.Table(database, table), nil
+}
+
+// prepare returns a cached prepared statement for the query
+func (s *sqlStore) prepare(query string) (*sql.Stmt, error) {
+	s.RLock()
+	st, ok := s.statements[query]
+	s.RUnlock()
+	if ok {
+		return st, nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if st, ok := s.statements[query]; ok {
+		return st, nil
+	}
+
+	st, err := s.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	s.statements[query] = st
+	return st, nil
+}
+
+// List all the known records
+func (s *sqlStore) List(opts ...store.ListOption) ([]string, error) {
+	var options store.ListOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// create the db if not exists
+	table, err := s.createDB(options.Database, options.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	key := s.dialect.Quote("key")
+	where, args := s.where(options.Prefix, options.Suffix)
+	query := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s%s;", key, table, where, key, s.dialect.Limit(options.Limit, options.Offset))
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	rowErr := rows.Close()
+	if rowErr != nil {
+		// transaction rollback or something
+		return keys, rowErr
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Read all records with keys
+func (s *sqlStore) Read(key string, opts ...store.ReadOption) ([]*store.Record, error) {
+	var options store.ReadOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if options.Prefix || options.Suffix {
+		return s.read(key, options)
+	}
+
+	// create the db if not exists
+	table, err := s.createDB(options.Database, options.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	st, err := s.prepare(fmt.Sprintf("SELECT %s, value, expiry FROM %s WHERE %s = %s;",
+		s.dialect.Quote("key"), table, s.dialect.Quote("key"), s.dialect.Placeholder(1)))
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*store.Record
+	record := &store.Record{}
+	var cachedTime sql.NullTime
+
+	if err := st.QueryRow(key).Scan(&record.Key, &record.Value, &cachedTime); err != nil {
+		if err == sql.ErrNoRows {
+			return records, store.ErrNotFound
+		}
+		return records, err
+	}
+	if cachedTime.Valid {
+		if cachedTime.Time.Before(time.Now()) {
+			// record has expired, leave it to the sweeper if there is one
+			if s.sweepInterval == 0 {
+				s.Delete(key, store.DeleteFrom(options.Database, options.Table))
+			}
+			return records, store.ErrNotFound
+		}
+		record.Expiry = time.Until(cachedTime.Time)
+	}
+	records = append(records, record)
+
+	return records, nil
+}
+
+// read returns the records matching a prefix and/or suffix
+func (s *sqlStore) read(key string, options store.ReadOptions) ([]*store.Record, error) {
+	// create the db if not exists
+	table, err := s.createDB(options.Database, options.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	var prefix, suffix string
+	if options.Prefix {
+		prefix = key
+	}
+	if options.Suffix {
+		suffix = key
+	}
+
+	column := s.dialect.Quote("key")
+	where, args := s.where(prefix, suffix)
+	query := fmt.Sprintf("SELECT %s, value, expiry FROM %s%s ORDER BY %s%s;", column, table, where, column, s.dialect.Limit(options.Limit, options.Offset))
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "sqlStore.read failed")
+	}
+	defer rows.Close()
+
+	var records []*store.Record
+	var cachedTime sql.NullTime
+
+	for rows.Next() {
+		record := &store.Record{}
+		if err := rows.Scan(&record.Key, &record.Value, &cachedTime); err != nil {
+			return records, err
+		}
+		if cachedTime.Valid {
+			record.Expiry = time.Until(cachedTime.Time)
+		}
+		records = append(records, record)
+	}
+	rowErr := rows.Close()
+	if rowErr != nil {
+		// transaction rollback or something
+		return records, rowErr
+	}
+	if err := rows.Err(); err != nil {
+		return records, err
+	}
+
+	return records, nil
+}
+
+// where builds the WHERE clause matching the key prefix and suffix of unexpired records
+func (s *sqlStore) where(prefix, suffix string) (string, []interface{}) {
+	clause := fmt.Sprintf(" WHERE (expiry IS NULL OR expiry > %s)", s.dialect.Placeholder(1))
+	args := []interface{}{time.Now().UTC()}
+
+	key := s.dialect.Quote("key")
+	if len(prefix) > 0 {
+		args = append(args, escape(prefix)+"%")
+		clause += " AND " + s.dialect.Like(key, s.dialect.Placeholder(len(args)))
+	}
+	if len(suffix) > 0 {
+		args = append(args, "%"+escape(suffix))
+		clause += " AND " + s.dialect.Like(key, s.dialect.Placeholder(len(args)))
+	}
+
+	return clause, args
+}
+
+// Write records
+func (s *sqlStore) Write(r *store.Record, opts ...store.WriteOption) error {
+	var options store.WriteOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// create the db if not exists
+	table, err := s.createDB(options.Database, options.Table)
+	if err != nil {
+		return err
+	}
+
+	st, err := s.prepare(s.dialect.Upsert(table))
+	if err != nil {
+		return err
+	}
+
+	// records without an expiry never expire
+	var timeCached interface{}
+	if r.Expiry > 0 {
+		timeCached = time.Now().Add(r.Expiry).UTC()
+	}
+
+	if _, err := st.Exec(r.Key, r.Value, timeCached); err != nil {
+		return errors.Wrap(err, "Couldn't insert record "+r.Key)
+	}
+
+	return nil
+}
+
+// Delete records with keys
+func (s *sqlStore) Delete(key string, opts ...store.DeleteOption) error {
+	var options store.DeleteOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// create the db if not exists
+	table, err := s.createDB(options.Database, options.Table)
+	if err != nil {
+		return err
+	}
+
+	st, err := s.prepare(fmt.Sprintf("DELETE FROM %s WHERE %s = %s;", table, s.dialect.Quote("key"), s.dialect.Placeholder(1)))
+	if err != nil {
+		return err
+	}
+
+	result, err := st.Exec(key)
+	if err != nil {
+		return err
+	}
+	_, err = result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// sweep periodically deletes expired records from every known table
+func (s *sqlStore) sweep(exit chan bool) {
+	t := time.NewTicker(s.sweepInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-exit:
+			return
+		case <-t.C:
+			s.RLock()
+			tables := make([]string, 0, len(s.databases))
+			for k := range s.databases {
+				tables = append(tables, k)
+			}
+			s.RUnlock()
+
+			for _, k := range tables {
+				parts := strings.SplitN(k, ":", 2)
+				if err := s.deleteExpired(s.dialect.Table(parts[0], parts[1])); err != nil {
+					log.Errorf("Error deleting expired records from %s: %v", k, err)
+				}
+			}
+		}
+	}
+}
+
+// deleteExpired deletes the expired records from a table in batches
+func (s *sqlStore) deleteExpired(table string) error {
+	st, err := s.prepare(s.dialect.DeleteExpired(table, s.sweepBatchSize))
+	if err != nil {
+		return err
+	}
+
+	for {
+		result, err := st.Exec(time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n < int64(s.sweepBatchSize) {
+			return nil
+		}
+	}
+}
+
+func (s *sqlStore) configure() error {
+	dialect := MySQL
+	if s.options.Context != nil {
+		if d, ok := s.options.Context.Value(dialectKey{}).(Dialect); ok {
+			dialect = d
+		}
+	}
+
+	nodes := s.options.Nodes
+	if len(nodes) == 0 {
+		node, ok := defaultNodes[dialect.String()]
+		if !ok {
+			return errors.New("no nodes provided for " + dialect.String())
+		}
+		nodes = []string{node}
+	}
+
+	database := s.options.Database
+	if len(database) == 0 {
+		database = DefaultDatabase
+	}
+
+	table := s.options.Table
+	if len(table) == 0 {
+		table = DefaultTable
+	}
+
+	for _, r := range database {
+		if !unicode.IsLetter(r) {
+			return errors.New("store.namespace must only contain letters")
+		}
+	}
+
+	source := nodes[0]
+	// create source from first node
+	db, err := sql.Open(dialect.Driver(), source)
+	if err != nil {
+		return err
+	}
+
+	if err := db.Ping(); err != nil {
+		return err
+	}
+
+	if s.db != nil {
+		s.Close()
+	}
+
+	// save the values
+	s.db = db
+	s.dialect = dialect
+	s.database = database
+	s.table = table
+	s.databases = make(map[string]bool)
+	s.statements = make(map[string]*sql.Stmt)
+	s.sweepInterval = 0
+	s.sweepBatchSize = DefaultSweepBatchSize
+
+	if s.options.Context != nil {
+		if d, ok := s.options.Context.Value(sweepIntervalKey{}).(time.Duration); ok {
+			s.sweepInterval = d
+		}
+		if n, ok := s.options.Context.Value(sweepBatchSizeKey{}).(int); ok && n > 0 {
+			s.sweepBatchSize = n
+		}
+	}
+
+	// initialise the database
+	if _, err := s.createDB(database, table); err != nil {
+		return err
+	}
+
+	if s.sweepInterval > 0 {
+		s.exit = make(chan bool)
+		go s.sweep(s.exit)
+	}
+
+	return nil
+}
+
+// escape escapes the LIKE wildcards in a key
+func escape(key string) string {
+	return likeEscaper.Replace(key)
+}
+
+func (s *sqlStore) String() string {
+	return s.dialect.String()
+}
+
+// NewStore returns a new micro Store backed by sql
+func NewStore(opts ...store.Option) store.Store {
+	var options store.Options
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// new store
+	s := new(sqlStore)
+	// set the options
+	s.options = options
+
+	// configure the store
+	if err := s.configure(); err != nil {
+		log.Fatal(err)
+	}
+
+	// return store
+	return s
+}
@@ -0,0 +1,215 @@
+package sql
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/micro/go-micro/v2/store"
+)
+
+func TestSQLite(t *testing.T) {
+	testStore(t, NewStore(
+		WithDialect(SQLite),
+		store.Nodes("file::memory:"),
+		store.Database("testMicro"),
+	))
+}
+
+func TestMySQL(t *testing.T) {
+	source := os.Getenv("MYSQL_SOURCE")
+	if len(source) == 0 {
+		t.Skip("MYSQL_SOURCE not set")
+	}
+
+	testStore(t, NewStore(
+		WithDialect(MySQL),
+		store.Nodes(source),
+		store.Database("testMicro"),
+	))
+}
+
+func TestPostgres(t *testing.T) {
+	source := os.Getenv("POSTGRES_SOURCE")
+	if len(source) == 0 {
+		t.Skip("POSTGRES_SOURCE not set")
+	}
+
+	testStore(t, NewStore(
+		WithDialect(Postgres),
+		store.Nodes(source),
+		store.Database("testMicro"),
+	))
+}
+
+// testStore is the conformance suite every dialect must pass
+func testStore(t *testing.T, s store.Store) {
+	defer s.Close()
+
+	// start from empty tables
+	for _, table := range []string{"", "other"} {
+		keys, err := s.List(store.ListFrom("", table))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range keys {
+			if err := s.Delete(key, store.DeleteFrom("", table)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	records := []*store.Record{
+		{Key: "config/bar", Value: []byte("bar")},
+		{Key: "config/baz", Value: []byte("baz"), Expiry: time.Minute},
+		{Key: "config/foo", Value: []byte("foo")},
+		{Key: "config_foo", Value: []byte("underscore")},
+		{Key: "other/foo", Value: []byte("other")},
+		{Key: "expired", Value: []byte("expired"), Expiry: time.Millisecond},
+	}
+	for _, r := range records {
+		if err := s.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Write(&store.Record{Key: "config/foo", Value: []byte("table")}, store.WriteTo("", "other")); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Millisecond * 10)
+
+	t.Run("Read", func(t *testing.T) {
+		r, err := s.Read("config/foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(r) != 1 || string(r[0].Value) != "foo" || r[0].Expiry != 0 {
+			t.Fatalf("Unexpected record %+v", r)
+		}
+
+		r, err = s.Read("config/baz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r[0].Expiry <= 0 || r[0].Expiry > time.Minute {
+			t.Fatalf("Unexpected expiry %v", r[0].Expiry)
+		}
+
+		if _, err := s.Read("expired"); err != store.ErrNotFound {
+			t.Fatalf("Expected %v got %v", store.ErrNotFound, err)
+		}
+		if _, err := s.Read("missing"); err != store.ErrNotFound {
+			t.Fatalf("Expected %v got %v", store.ErrNotFound, err)
+		}
+
+		r, err = s.Read("config/foo", store.ReadFrom("", "other"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(r[0].Value) != "table" {
+			t.Fatalf("Expected value from other table got %s", r[0].Value)
+		}
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		if err := s.Write(&store.Record{Key: "config/bar", Value: []byte("bar2")}); err != nil {
+			t.Fatal(err)
+		}
+		r, err := s.Read("config/bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(r[0].Value) != "bar2" {
+			t.Fatalf("Expected bar2 got %s", r[0].Value)
+		}
+	})
+
+	t.Run("ReadPrefix", func(t *testing.T) {
+		testKeys := func(opts []store.ReadOption, key string, expected []string) {
+			t.Helper()
+			r, err := s.Read(key, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var keys []string
+			for _, record := range r {
+				keys = append(keys, record.Key)
+			}
+			if !reflect.DeepEqual(keys, expected) {
+				t.Fatalf("Expected %v got %v", expected, keys)
+			}
+		}
+
+		testKeys([]store.ReadOption{store.ReadPrefix()}, "config/", []string{"config/bar", "config/baz", "config/foo"})
+		testKeys([]store.ReadOption{store.ReadPrefix(), store.ReadLimit(2)}, "config/", []string{"config/bar", "config/baz"})
+		testKeys([]store.ReadOption{store.ReadPrefix(), store.ReadLimit(2), store.ReadOffset(2)}, "config/", []string{"config/foo"})
+		testKeys([]store.ReadOption{store.ReadPrefix(), store.ReadOffset(1)}, "config/", []string{"config/baz", "config/foo"})
+		testKeys([]store.ReadOption{store.ReadSuffix()}, "/foo", []string{"config/foo", "other/foo"})
+		testKeys([]store.ReadOption{store.ReadPrefix(), store.ReadSuffix()}, "e", nil)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		testKeys := func(opts []store.ListOption, expected []string) {
+			t.Helper()
+			keys, err := s.List(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(keys, expected) {
+				t.Fatalf("Expected %v got %v", expected, keys)
+			}
+		}
+
+		testKeys(nil, []string{"config/bar", "config/baz", "config/foo", "config_foo", "other/foo"})
+		testKeys([]store.ListOption{store.ListPrefix("config_")}, []string{"config_foo"})
+		testKeys([]store.ListOption{store.ListPrefix("config"), store.ListSuffix("foo")}, []string{"config/foo", "config_foo"})
+		testKeys([]store.ListOption{store.ListLimit(2), store.ListOffset(1)}, []string{"config/baz", "config/foo"})
+		testKeys([]store.ListOption{store.ListFrom("", "other")}, []string{"config/foo"})
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := s.Delete("config/foo"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Read("config/foo"); err != store.ErrNotFound {
+			t.Fatalf("Expected %v got %v", store.ErrNotFound, err)
+		}
+		if _, err := s.Read("config/foo", store.ReadFrom("", "other")); err != nil {
+			t.Fatalf("Expected record in other table to remain, got %v", err)
+		}
+	})
+}
+
+func TestSweep(t *testing.T) {
+	s := NewStore(
+		WithDialect(SQLite),
+		store.Nodes("file::memory:"),
+		SweepInterval(time.Millisecond*20),
+		SweepBatchSize(2),
+	)
+	defer s.Close()
+
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		if err := s.Write(&store.Record{Key: key, Value: []byte(key), Expiry: time.Millisecond}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Write(&store.Record{Key: "forever", Value: []byte("forever")}); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Millisecond * 100)
+
+	var count int
+	row := s.(*sqlStore).db.QueryRow(`SELECT COUNT(1) FROM "micro_micro";`)
+	if err := row.Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected expired records to be swept, %d remain", count)
+	}
+}
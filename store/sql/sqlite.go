@@ -0,0 +1,78 @@
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// SQLite is the dialect for SQLite. Databases are mapped onto a table name prefix
+// since a SQLite file only holds one database.
+var SQLite Dialect = sqliteDialect{}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Driver() string {
+	return "sqlite3"
+}
+
+func (d sqliteDialect) Init(db *sql.DB, database, table string) error {
+	// sqlite only allows a single writer, and an in memory database
+	// is private to the connection that opened it
+	db.SetMaxOpenConns(1)
+
+	// Create a table for the namespace's prefix
+	createSQL := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (key text primary key, value blob, expiry timestamp);", d.Table(database, table))
+	_, err := db.Exec(createSQL)
+	if err != nil {
+		return errors.Wrap(err, "Couldn't create table")
+	}
+
+	_, err = db.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (expiry);", d.Quote(database+"_"+table+"_expiry_index"), d.Table(database, table)))
+	if err != nil {
+		return errors.Wrap(err, "Couldn't create index")
+	}
+
+	return nil
+}
+
+func (d sqliteDialect) Table(database, table string) string {
+	return d.Quote(database + "_" + table)
+}
+
+func (sqliteDialect) Quote(ident string) string {
+	return `"` + ident + `"`
+}
+
+func (sqliteDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (sqliteDialect) Like(column, placeholder string) string {
+	// sqlite has no default escape character
+	return column + " LIKE " + placeholder + ` ESCAPE '\'`
+}
+
+func (sqliteDialect) Upsert(table string) string {
+	return fmt.Sprintf("INSERT INTO %s (key, value, expiry) VALUES (?, ?, ?) ON CONFLICT (key) DO UPDATE SET value = excluded.value, expiry = excluded.expiry;", table)
+}
+
+func (sqliteDialect) Limit(limit, offset uint) string {
+	if limit == 0 && offset == 0 {
+		return ""
+	}
+	if limit == 0 {
+		// sqlite requires a limit when using an offset
+		return fmt.Sprintf(" LIMIT -1 OFFSET %d", offset)
+	}
+	return fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+}
+
+func (sqliteDialect) DeleteExpired(table string, batch int) string {
+	return fmt.Sprintf("DELETE FROM %s WHERE key IN (SELECT key FROM %s WHERE expiry < ? LIMIT %d);", table, table, batch)
+}
+
+func (sqliteDialect) String() string {
+	return "sqlite"
+}